@@ -0,0 +1,50 @@
+package oidcstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/in4it/go-devops-platform/auth/oidc"
+)
+
+// Reload reads the oidc store again when it was changed outside of this store (e.g. by another instance).
+// Returns true when the store was reloaded.
+func (store *Store) Reload() (bool, error) {
+	filename := store.storage.ConfigPath(DEFAULT_PATH)
+	if !store.storage.FileExists(filename) {
+		return false, nil
+	}
+	data, err := store.storage.ReadFile(filename)
+	if err != nil {
+		return false, fmt.Errorf("config read error: %s", err)
+	}
+	hash := sha256.Sum256(data)
+	store.Mu.Lock()
+	defer store.Mu.Unlock()
+	if hash == store.hash {
+		return false, nil
+	}
+	var newStore struct {
+		OAuth2Data     map[string]oidc.OAuthData      `json:"oauth2Data"`
+		DiscoveryCache map[string]oidc.DiscoveryCache `json:"discoveryCache"`
+		JwksCache      map[string]oidc.JwksCache      `json:"jwksCache"`
+	}
+	err = json.NewDecoder(bytes.NewBuffer(data)).Decode(&newStore)
+	if err != nil {
+		return false, fmt.Errorf("decode input error: %s", err)
+	}
+	store.OAuth2Data = newStore.OAuth2Data
+	if store.OAuth2Data == nil {
+		store.OAuth2Data = make(map[string]oidc.OAuthData)
+	}
+	if newStore.DiscoveryCache != nil {
+		store.DiscoveryCache = newStore.DiscoveryCache
+	}
+	if newStore.JwksCache != nil {
+		store.JwksCache = newStore.JwksCache
+	}
+	store.hash = hash
+	return true, nil
+}
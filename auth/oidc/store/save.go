@@ -1,30 +1,45 @@
 package oidcstore
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 
 	"github.com/in4it/go-devops-platform/auth/oidc"
+	"github.com/in4it/go-devops-platform/storage"
 )
 
 func (store *Store) SaveOIDCStore() error {
+	return storage.WithLock(store.storage, store.storage.ConfigPath(DEFAULT_PATH), store.saveOIDCStore)
+}
+
+func (store *Store) saveOIDCStore() error {
 	store.Mu.Lock()
 	defer store.Mu.Unlock()
 	out, err := json.Marshal(store)
 	if err != nil {
 		return fmt.Errorf("oidc store marshal error: %s", err)
 	}
-	filename := store.storage.ConfigPath("oidcstore.json")
+	filename := store.storage.ConfigPath(DEFAULT_PATH)
 	err = store.storage.WriteFile(filename, out)
 	if err != nil {
 		return fmt.Errorf("oidcstore write error: %s", err)
 	}
+	store.hash = sha256.Sum256(out)
 	return nil
 }
 
+// SaveOAuth2Data reloads the store (it might have been changed by another instance), adds the entry and saves the store,
+// while holding the storage lock
 func (store *Store) SaveOAuth2Data(oauth2Data oidc.OAuthData, key string) error {
-	store.Mu.Lock()
-	store.OAuth2Data[key] = oauth2Data
-	store.Mu.Unlock()
-	return store.SaveOIDCStore()
+	return storage.WithLock(store.storage, store.storage.ConfigPath(DEFAULT_PATH), func() error {
+		_, err := store.Reload()
+		if err != nil {
+			return fmt.Errorf("reload error: %s", err)
+		}
+		store.Mu.Lock()
+		store.OAuth2Data[key] = oauth2Data
+		store.Mu.Unlock()
+		return store.saveOIDCStore()
+	})
 }
@@ -40,3 +40,31 @@ func TestSave(t *testing.T) {
 		t.Fatalf("expected testissuer. Got: %s", discovery.Discovery.Issuer)
 	}
 }
+
+func TestSaveWriteError(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	store, err := NewStore(storage)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	storage.InjectFault(memorystorage.Fault{Op: memorystorage.OpWrite, Err: memorystorage.ErrInjectedFault})
+	err = store.SaveOAuth2Data(oidc.OAuthData{ID: "1"}, "key")
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if storage.FileExists(storage.ConfigPath(DEFAULT_PATH)) {
+		t.Fatalf("expected store not to be written")
+	}
+	storage.ClearFaults()
+	err = store.SaveOIDCStore()
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	store2, err := NewStore(storage)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if _, ok := store2.OAuth2Data["key"]; !ok {
+		t.Fatalf("oauth2 data not saved")
+	}
+}
@@ -2,6 +2,7 @@ package oidcstore
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"sync"
@@ -40,25 +41,28 @@ func (store *Store) getDiscoveryCache(key string) (oidc.DiscoveryCache, bool) {
 	return discovery, ok
 }
 
-func NewStore(storage storage.Iface) (*Store, error) {
+func NewStore(storageClient storage.Iface) (*Store, error) {
 	var store *Store
 
-	filename := storage.ConfigPath(DEFAULT_PATH)
+	filename := storageClient.ConfigPath(DEFAULT_PATH)
 
 	// check if oidc.Store exists
-	if !storage.FileExists(filename) {
-		return getEmptyOIDCStore(storage)
+	if !storageClient.FileExists(filename) {
+		return getEmptyOIDCStore(storageClient)
 	}
 
-	data, err := storage.ReadFile(filename)
+	// falls back to the last good snapshot if the oidc store is corrupt
+	err := storage.ReadFileWithFallback(storageClient, filename, func(data []byte) error {
+		store = nil
+		err := json.NewDecoder(bytes.NewBuffer(data)).Decode(&store)
+		if err == nil && store != nil {
+			store.hash = sha256.Sum256(data)
+		}
+		return err
+	})
 	if err != nil {
 		return store, fmt.Errorf("config read error: %s", err)
 	}
-	decoder := json.NewDecoder(bytes.NewBuffer(data))
-	err = decoder.Decode(&store)
-	if err != nil {
-		return store, fmt.Errorf("decode input error: %s", err)
-	}
 	if store.DiscoveryCache == nil {
 		store.DiscoveryCache = make(map[string]oidc.DiscoveryCache)
 	}
@@ -69,7 +73,7 @@ func NewStore(storage storage.Iface) (*Store, error) {
 		store.OAuth2Data = make(map[string]oidc.OAuthData)
 	}
 
-	store.storage = storage
+	store.storage = storageClient
 
 	return store, nil
 }
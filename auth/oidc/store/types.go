@@ -1,6 +1,7 @@
 package oidcstore
 
 import (
+	"crypto/sha256"
 	"sync"
 
 	"github.com/in4it/go-devops-platform/auth/oidc"
@@ -13,4 +14,5 @@ type Store struct {
 	DiscoveryCache map[string]oidc.DiscoveryCache `json:"discoveryCache"`
 	JwksCache      map[string]oidc.JwksCache      `json:"jwksCache"`
 	storage        storage.Iface
+	hash           [sha256.Size]byte // hash of the last loaded or saved oidc store
 }
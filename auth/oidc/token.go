@@ -107,9 +107,19 @@ func UpdateOAuth2DataWithToken(jwks Jwks, discovery Discovery, clientID, clientS
 	newOAuthData.Subject = subject.(string)
 	newOAuthData.Issuer = issuer.(string)
 	newOAuthData.UserInfo.Email = validEmail
+	newOAuthData.UserInfo.Name = stringClaim(claims, "name")
+	newOAuthData.UserInfo.GivenName = stringClaim(claims, "given_name")
+	newOAuthData.UserInfo.FamilyName = stringClaim(claims, "family_name")
+	newOAuthData.UserInfo.Locale = stringClaim(claims, "locale")
 	return newOAuthData, nil
 }
 
+// stringClaim returns the claim when it's a string, otherwise an empty string
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
 func GetPublicKeyForToken(allJwks []Jwks, discoveryProviders []Discovery, token *jwt.Token) (any, error) {
 	kid, ok := token.Header["kid"]
 	if !ok {
@@ -74,5 +74,9 @@ type OAuthData struct {
 }
 
 type UserInfo struct {
-	Email string `json:"email"`
+	Email      string `json:"email"`
+	Name       string `json:"name,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	Locale     string `json:"locale,omitempty"`
 }
@@ -0,0 +1,44 @@
+package scim
+
+import (
+	"fmt"
+
+	"github.com/in4it/go-devops-platform/users"
+)
+
+// setUserGroups sets the groups of the user to the groups in the request. Groups that don't exist yet are created.
+func (s *Scim) setUserGroups(userID string, references []GroupReference) error {
+	groupIDs := []string{}
+	for _, reference := range references {
+		group, err := s.getOrCreateGroup(reference)
+		if err != nil {
+			return err
+		}
+		groupIDs = append(groupIDs, group.ID)
+	}
+	return s.UserStore.SetUserGroups(userID, groupIDs)
+}
+
+func (s *Scim) getOrCreateGroup(reference GroupReference) (users.Group, error) {
+	groupStore := s.UserStore.Groups()
+	if reference.Value != "" {
+		if group, err := groupStore.GetGroupByExternalID(reference.Value); err == nil {
+			return group, nil
+		}
+	}
+	name := reference.Display
+	if name == "" {
+		name = reference.Value
+	}
+	if name == "" {
+		return users.Group{}, fmt.Errorf("group without value and display name")
+	}
+	if group, err := groupStore.GetGroupByName(name); err == nil {
+		return group, nil
+	}
+	group, err := groupStore.AddGroup(users.Group{Name: name, ExternalID: reference.Value})
+	if err != nil {
+		return group, fmt.Errorf("add group error: %s", err)
+	}
+	return group, nil
+}
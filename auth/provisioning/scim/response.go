@@ -41,6 +41,16 @@ func userResponse(user users.User) ([]byte, error) {
 		Id:       user.ID,
 		UserName: user.Login,
 		Active:   !user.Suspended,
+		Name: Name{
+			GivenName:  user.GivenName,
+			FamilyName: user.FamilyName,
+		},
+		DisplayName: user.DisplayName,
+		Locale:      user.Locale,
+		ExternalID:  user.ExternalID,
+	}
+	if user.Email != "" {
+		response.Emails = []Emails{{Primary: true, Value: user.Email, Type: "work"}}
 	}
 	out, err := json.Marshal(response)
 	if err != nil {
@@ -31,17 +31,21 @@ type UserResource struct {
 }
 
 type PostUserRequest struct {
-	Schemas     []string `json:"schemas"`
-	UserName    string   `json:"userName"`
-	Id          string   `json:"id,omitempty"`
-	Name        Name     `json:"name"`
-	Emails      []Emails `json:"emails"`
-	DisplayName string   `json:"displayName"`
-	Locale      string   `json:"locale"`
-	ExternalID  string   `json:"externalId"`
-	Groups      []any    `json:"groups"`
-	Password    string   `json:"password"`
-	Active      bool     `json:"active"`
+	Schemas     []string         `json:"schemas"`
+	UserName    string           `json:"userName"`
+	Id          string           `json:"id,omitempty"`
+	Name        Name             `json:"name"`
+	Emails      []Emails         `json:"emails"`
+	DisplayName string           `json:"displayName"`
+	Locale      string           `json:"locale"`
+	ExternalID  string           `json:"externalId"`
+	Groups      []GroupReference `json:"groups"`
+	Password    string           `json:"password"`
+	Active      bool             `json:"active"`
+}
+type GroupReference struct {
+	Value   string `json:"value"`   // id of the group in the identity provider
+	Display string `json:"display"` // name of the group
 }
 type Name struct {
 	GivenName  string `json:"givenName"`
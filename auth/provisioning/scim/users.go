@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -93,6 +94,10 @@ func (s *Scim) PutUserHandler(w http.ResponseWriter, r *http.Request) {
 		returnError(w, fmt.Errorf("get user by id error: %s", err), http.StatusBadRequest)
 		return
 	}
+	if user.Deleted() {
+		returnError(w, fmt.Errorf("user not found"), http.StatusNotFound)
+		return
+	}
 
 	var putUserRequest PostUserRequest
 	err = json.NewDecoder(r.Body).Decode(&putUserRequest)
@@ -101,22 +106,9 @@ func (s *Scim) PutUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !putUserRequest.Active && !user.Suspended { // user is suspended
-		err = s.UserStore.UserHooks.DisableFunc(s.storage, user)
-		if err != nil {
-			returnError(w, fmt.Errorf("could not delete all clients for user %s: %s", user.ID, err), http.StatusBadRequest)
-			return
-		}
-	}
-	if putUserRequest.Active && user.Suspended { // user is unsuspended
-		err := s.UserStore.UserHooks.ReactivateFunc(s.storage, user)
-		if err != nil {
-			returnError(w, fmt.Errorf("could not reactivate all clients for user %s: %s", user.ID, err), http.StatusBadRequest)
-			return
-		}
-	}
-
+	suspendedChanged := user.Suspended == putUserRequest.Active
 	user.Suspended = !putUserRequest.Active
+	user.UpdateProfile(getProfile(putUserRequest))
 	username := getUsername(putUserRequest)
 	if user.Login != username {
 		if !s.UserStore.LoginExists(username) {
@@ -130,6 +122,26 @@ func (s *Scim) PutUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if suspendedChanged { // the hooks are executed by the outbox worker
+		event := users.Event{Type: users.EVENT_USER_REACTIVATED, User: user}
+		if user.Suspended {
+			event.Type = users.EVENT_USER_DISABLED
+		}
+		err = s.UserStore.Events().Dispatch(event)
+		if err != nil {
+			returnError(w, fmt.Errorf("could not queue %s event for user %s: %s", event.Type, user.ID, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if putUserRequest.Groups != nil { // groups are only changed when they're in the request
+		err = s.setUserGroups(user.ID, putUserRequest.Groups)
+		if err != nil {
+			returnError(w, fmt.Errorf("user groups update error: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
 	response, err := userResponse(user)
 	if err != nil {
 		returnError(w, fmt.Errorf("user response error: %s", err), http.StatusBadRequest)
@@ -141,20 +153,14 @@ func (s *Scim) PutUserHandler(w http.ResponseWriter, r *http.Request) {
 
 func (s *Scim) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := s.UserStore.GetUserByID(r.PathValue("id"))
-	if err != nil {
-		returnError(w, fmt.Errorf("get user by id error: %s", err), http.StatusBadRequest)
+	if err != nil || user.Deleted() {
+		returnError(w, fmt.Errorf("user not found"), http.StatusNotFound)
 		return
 	}
 
-	err = s.UserStore.UserHooks.DeleteFunc(s.storage, user)
+	err = s.UserStore.DeleteUser(user.ID) // soft delete when a deletion grace period is configured
 	if err != nil {
-		returnError(w, fmt.Errorf("could not delete all clients for user %s: %s", user.ID, err), http.StatusBadRequest)
-		return
-	}
-
-	err = s.UserStore.DeleteUserByID(user.ID)
-	if err != nil {
-		returnError(w, fmt.Errorf("user update error: %s", err), http.StatusBadRequest)
+		returnError(w, fmt.Errorf("user delete error: %s", err), http.StatusBadRequest)
 		return
 	}
 
@@ -172,6 +178,11 @@ func (s *Scim) PostUsersHandler(w http.ResponseWriter, r *http.Request) {
 	username := getUsername(postUserRequest)
 
 	if s.UserStore.LoginExists(username) {
+		existingUser, err := s.UserStore.GetUserByLogin(username)
+		if err == nil && existingUser.Deleted() && existingUser.Provisioned { // provisioned again within the deletion grace period
+			s.restoreUser(w, existingUser, postUserRequest)
+			return
+		}
 		writeWithStatus(w, []byte("user already exists"), http.StatusConflict)
 		return
 	}
@@ -186,11 +197,19 @@ func (s *Scim) PostUsersHandler(w http.ResponseWriter, r *http.Request) {
 		Role:        "user",
 		Provisioned: true,
 		ExternalID:  postUserRequest.ExternalID,
+		Profile:     getProfile(postUserRequest),
 	})
 	if err != nil {
 		returnError(w, fmt.Errorf("unable to add user: %s", err), http.StatusBadRequest)
 		return
 	}
+	if len(postUserRequest.Groups) > 0 {
+		err = s.setUserGroups(user.ID, postUserRequest.Groups)
+		if err != nil {
+			returnError(w, fmt.Errorf("unable to set user groups: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
 	response, err := userResponse(user)
 	if err != nil {
 		returnError(w, fmt.Errorf("unable to generate user response: %s", err), http.StatusBadRequest)
@@ -211,6 +230,21 @@ func getUsername(postUserRequest PostUserRequest) string {
 	return username
 }
 
+func getProfile(postUserRequest PostUserRequest) users.Profile {
+	profile := users.Profile{
+		DisplayName: postUserRequest.DisplayName,
+		GivenName:   postUserRequest.Name.GivenName,
+		FamilyName:  postUserRequest.Name.FamilyName,
+		Locale:      postUserRequest.Locale,
+	}
+	for _, email := range postUserRequest.Emails {
+		if email.Primary || profile.Email == "" {
+			profile.Email = email.Value
+		}
+	}
+	return profile
+}
+
 func getUsersWithFilter(userStore *users.UserStore, attributes, filter string) ([]byte, error) {
 	filterSplit := strings.Split(filter, " ")
 	if len(filterSplit) != 3 {
@@ -223,6 +257,9 @@ func getUsersWithFilter(userStore *users.UserStore, attributes, filter string) (
 				if err != nil {
 					return []byte{}, fmt.Errorf("get user by login error: %s", err)
 				}
+				if user.Deleted() {
+					return listUserResponse([]users.User{}, attributes, -1, -1)
+				}
 				response, err := listUserResponse([]users.User{user}, attributes, -1, -1)
 				if err != nil {
 					return []byte{}, fmt.Errorf("userResponse error: %s", err)
@@ -239,10 +276,39 @@ func getUsersWithFilter(userStore *users.UserStore, attributes, filter string) (
 }
 
 func getUsersWithoutFilter(userStore *users.UserStore, attributes string, count, start int) ([]byte, error) {
-	users := userStore.ListUsers()
+	users := slices.DeleteFunc(userStore.ListUsers(), func(user users.User) bool { return user.Deleted() })
 	response, err := listUserResponse(users, attributes, count, start)
 	if err != nil {
 		return []byte{}, fmt.Errorf("userResponse error: %s", err)
 	}
 	return response, nil
 }
+
+// restoreUser restores a deleted user that is provisioned again, and updates the profile
+func (s *Scim) restoreUser(w http.ResponseWriter, user users.User, postUserRequest PostUserRequest) {
+	user, err := s.UserStore.RestoreUser(user.ID)
+	if err != nil {
+		returnError(w, fmt.Errorf("unable to restore user: %s", err), http.StatusBadRequest)
+		return
+	}
+	user.ExternalID = postUserRequest.ExternalID
+	user.UpdateProfile(getProfile(postUserRequest))
+	err = s.UserStore.UpdateUser(user)
+	if err != nil {
+		returnError(w, fmt.Errorf("user update error: %s", err), http.StatusBadRequest)
+		return
+	}
+	if len(postUserRequest.Groups) > 0 {
+		err = s.setUserGroups(user.ID, postUserRequest.Groups)
+		if err != nil {
+			returnError(w, fmt.Errorf("unable to set user groups: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+	response, err := userResponse(user)
+	if err != nil {
+		returnError(w, fmt.Errorf("unable to generate user response: %s", err), http.StatusBadRequest)
+		return
+	}
+	writeWithStatus(w, response, http.StatusCreated)
+}
@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
 	"github.com/in4it/go-devops-platform/users"
@@ -202,3 +205,119 @@ func TestAddUser(t *testing.T) {
 	}
 
 }
+
+func TestPostUserWithGroups(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	userStore, err := users.NewUserStore(storage, USERSTORE_MAX_USERS)
+	if err != nil {
+		t.Fatalf("cannot create new user store")
+	}
+	s := New(storage, userStore, "token")
+	payload := []byte(`{"userName": "john@domain.inv", "active": true, "groups": [{"value": "ext-1", "display": "developers"}]}`)
+	req := httptest.NewRequest("POST", "http://example.com/api/scim/v2/Users", bytes.NewBuffer(payload))
+	w := httptest.NewRecorder()
+	s.PostUsersHandler(w, req)
+	if w.Code != 201 {
+		t.Fatalf("expected 201, got: %d (%s)", w.Code, w.Body.String())
+	}
+	user, err := userStore.GetUserByLogin("john@domain.inv")
+	if err != nil {
+		t.Fatalf("get user error: %s", err)
+	}
+	if !userStore.IsMemberOf(user, "developers") {
+		t.Fatalf("expected user to be member of developers")
+	}
+	group, err := userStore.Groups().GetGroupByExternalID("ext-1")
+	if err != nil || group.Name != "developers" {
+		t.Fatalf("expected group with external id, got: %+v (err: %v)", group, err)
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	userStore, err := users.NewUserStore(storage, USERSTORE_MAX_USERS)
+	if err != nil {
+		t.Fatalf("cannot create new user store")
+	}
+	s := New(storage, userStore, "token")
+	payload := []byte(`{"userName": "john@domain.inv", "active": true, "displayName": "John Doe", "locale": "en-US", "name": {"givenName": "John", "familyName": "Doe"}, "emails": [{"primary": true, "value": "john.doe@domain.inv", "type": "work"}]}`)
+	req := httptest.NewRequest("POST", "http://example.com/api/scim/v2/Users", bytes.NewBuffer(payload))
+	w := httptest.NewRecorder()
+	s.PostUsersHandler(w, req)
+	if w.Code != 201 {
+		t.Fatalf("expected 201, got: %d (%s)", w.Code, w.Body.String())
+	}
+	user, err := userStore.GetUserByLogin("john@domain.inv")
+	if err != nil {
+		t.Fatalf("get user error: %s", err)
+	}
+	expected := users.Profile{DisplayName: "John Doe", GivenName: "John", FamilyName: "Doe", Email: "john.doe@domain.inv", Locale: "en-US"}
+	if fmt.Sprintf("%+v", user.Profile) != fmt.Sprintf("%+v", expected) {
+		t.Fatalf("unexpected profile: %+v", user.Profile)
+	}
+
+	payload = []byte(`{"userName": "john@domain.inv", "active": true, "displayName": "Johnny", "locale": "nl-BE"}`)
+	req = httptest.NewRequest("PUT", "http://example.com/api/scim/v2/Users/"+user.ID, bytes.NewBuffer(payload))
+	req.SetPathValue("id", user.ID)
+	w = httptest.NewRecorder()
+	s.PutUserHandler(w, req)
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	var putUserResponse PostUserRequest
+	err = json.NewDecoder(w.Body).Decode(&putUserResponse)
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if putUserResponse.DisplayName != "Johnny" || putUserResponse.Locale != "nl-BE" || putUserResponse.Name.GivenName != "John" {
+		t.Fatalf("unexpected response: %+v", putUserResponse)
+	}
+	if len(putUserResponse.Emails) != 1 || putUserResponse.Emails[0].Value != "john.doe@domain.inv" {
+		t.Fatalf("unexpected emails: %+v", putUserResponse.Emails)
+	}
+}
+
+func TestDeleteUserWithGracePeriod(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	userStore, err := users.NewUserStore(storage, USERSTORE_MAX_USERS)
+	if err != nil {
+		t.Fatalf("cannot create new user store")
+	}
+	userStore.SetDeletionGracePeriod(time.Hour)
+	s := New(storage, userStore, "token")
+	payload := []byte(`{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "john@example.inv", "active": true}`)
+	w := httptest.NewRecorder()
+	s.PostUsersHandler(w, httptest.NewRequest("POST", "http://example.com/api/scim/v2/Users", bytes.NewBuffer(payload)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got: %d (%s)", w.Code, w.Body.String())
+	}
+	user, err := userStore.GetUserByLogin("john@example.inv")
+	if err != nil {
+		t.Fatalf("get user error: %s", err)
+	}
+	req := httptest.NewRequest("DELETE", "http://example.com/api/scim/v2/Users/"+user.ID, nil)
+	req.SetPathValue("id", user.ID)
+	w = httptest.NewRecorder()
+	s.DeleteUserHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	s.GetUsersHandler(w, httptest.NewRequest("GET", "http://example.com/api/scim/v2/Users?filter=userName+eq+%22john%40example.inv%22", nil))
+	if strings.Contains(w.Body.String(), user.ID) {
+		t.Fatalf("deleted user shouldn't be returned: %s", w.Body.String())
+	}
+	if deletedUser, _ := userStore.GetUserByID(user.ID); !deletedUser.Deleted() || !deletedUser.Suspended {
+		t.Fatalf("expected user to be soft deleted")
+	}
+
+	// provisioning the user again restores the user
+	w = httptest.NewRecorder()
+	s.PostUsersHandler(w, httptest.NewRequest("POST", "http://example.com/api/scim/v2/Users", bytes.NewBuffer(payload)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got: %d (%s)", w.Code, w.Body.String())
+	}
+	if restoredUser, _ := userStore.GetUserByID(user.ID); restoredUser.Deleted() || restoredUser.Suspended {
+		t.Fatalf("expected user to be restored: %+v", restoredUser)
+	}
+}
@@ -0,0 +1,47 @@
+package saml
+
+import (
+	"github.com/in4it/go-devops-platform/users"
+	saml2 "github.com/russellhaering/gosaml2"
+)
+
+// attribute names used by the identity providers for the profile fields (e.g. Azure AD / ADFS claims, LDAP names, Okta / Google defaults)
+var profileAttributes = map[string][]string{
+	"displayName": {"displayName", "http://schemas.microsoft.com/identity/claims/displayname", "urn:oid:2.16.840.1.113730.3.1.241"},
+	"givenName":   {"givenName", "firstName", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname", "urn:oid:2.5.4.42"},
+	"familyName":  {"sn", "surname", "lastName", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname", "urn:oid:2.5.4.4"},
+	"email":       {"email", "mail", "emailAddress", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress", "urn:oid:0.9.2342.19200300.100.1.3"},
+	"locale":      {"locale", "preferredLanguage", "urn:oid:2.16.840.1.113730.3.1.39"},
+}
+
+// getProfile maps the attributes of the assertion to the profile. Attributes that are not a profile field are kept as custom attributes.
+func getProfile(values saml2.Values) users.Profile {
+	profile := users.Profile{
+		Attributes: map[string]string{},
+	}
+	fields := map[string]*string{
+		"displayName": &profile.DisplayName,
+		"givenName":   &profile.GivenName,
+		"familyName":  &profile.FamilyName,
+		"email":       &profile.Email,
+		"locale":      &profile.Locale,
+	}
+	known := map[string]bool{}
+	for field, names := range profileAttributes {
+		for _, name := range names {
+			known[name] = true
+			if *fields[field] == "" {
+				*fields[field] = values.Get(name)
+			}
+		}
+	}
+	for name := range values {
+		if !known[name] && values.Get(name) != "" {
+			profile.Attributes[name] = values.Get(name)
+		}
+	}
+	if len(profile.Attributes) == 0 {
+		profile.Attributes = nil
+	}
+	return profile
+}
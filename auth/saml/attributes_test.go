@@ -0,0 +1,30 @@
+package saml
+
+import (
+	"testing"
+
+	saml2 "github.com/russellhaering/gosaml2"
+	"github.com/russellhaering/gosaml2/types"
+)
+
+func TestGetProfile(t *testing.T) {
+	values := saml2.Values{}
+	for name, value := range map[string]string{
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname": "John",
+		"sn":         "Doe",
+		"mail":       "john@example.inv",
+		"department": "engineering",
+	} {
+		values[name] = types.Attribute{Name: name, Values: []types.AttributeValue{{Value: value}}}
+	}
+	profile := getProfile(values)
+	if profile.GivenName != "John" || profile.FamilyName != "Doe" || profile.Email != "john@example.inv" {
+		t.Fatalf("unexpected profile: %+v", profile)
+	}
+	if len(profile.Attributes) != 1 || profile.Attributes["department"] != "engineering" {
+		t.Fatalf("unexpected custom attributes: %+v", profile.Attributes)
+	}
+	if getProfile(nil).Attributes != nil {
+		t.Fatalf("expected no custom attributes")
+	}
+}
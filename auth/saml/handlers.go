@@ -89,6 +89,7 @@ func (s *saml) samlHandler(w http.ResponseWriter, r *http.Request) {
 		ID:        uuid.New().String(),
 		Login:     login,
 		ExpiresAt: notAfter,
+		Profile:   getProfile(assertionInfo.Values),
 	})
 	w.Header().Add("Location", fmt.Sprintf("/callback/saml/%s?code=%s", providerID, sessionKey.SessionID))
 	w.WriteHeader(http.StatusFound)
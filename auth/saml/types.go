@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/in4it/go-devops-platform/storage"
+	"github.com/in4it/go-devops-platform/users"
 	saml2 "github.com/russellhaering/gosaml2"
 )
 
@@ -33,6 +34,7 @@ type AuthenticatedUser struct {
 	ID        string
 	Login     string
 	ExpiresAt time.Time
+	Profile   users.Profile
 }
 type SessionKey struct {
 	ProviderID string
@@ -0,0 +1,88 @@
+// storage-migrate copies the config and stats of an install from one storage backend to another.
+//
+//	storage-migrate -from local -local-path /opt/app -to s3 -s3-bucket mybucket -s3-prefix app -dry-run
+//
+// Files that already exist in the destination with the same checksum are skipped, so an interrupted
+// migration can be resumed by running the same command again.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/in4it/go-devops-platform/storage"
+	localstorage "github.com/in4it/go-devops-platform/storage/local"
+	"github.com/in4it/go-devops-platform/storage/migrate"
+	s3storage "github.com/in4it/go-devops-platform/storage/s3"
+)
+
+func main() {
+	var (
+		from      string
+		to        string
+		localPath string
+		s3Bucket  string
+		s3Prefix  string
+		dryRun    bool
+	)
+	flag.StringVar(&from, "from", "", "source storage (local or s3)")
+	flag.StringVar(&to, "to", "", "destination storage (local or s3)")
+	flag.StringVar(&localPath, "local-path", "", "path of the local storage (defaults to the directory of this binary)")
+	flag.StringVar(&s3Bucket, "s3-bucket", "", "bucket name of the s3 storage")
+	flag.StringVar(&s3Prefix, "s3-prefix", "", "prefix of the s3 storage")
+	flag.BoolVar(&dryRun, "dry-run", false, "only show the files that would be copied")
+	flag.Parse()
+
+	if from == to {
+		fmt.Fprintf(os.Stderr, "source and destination storage must be different (local or s3)\n")
+		os.Exit(1)
+	}
+	src, err := newStorage(from, localPath, s3Bucket, s3Prefix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "source storage error: %s\n", err)
+		os.Exit(1)
+	}
+	dst, err := newStorage(to, localPath, s3Bucket, s3Prefix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "destination storage error: %s\n", err)
+		os.Exit(1)
+	}
+
+	// stop after the current file on ctrl-c, the migration can be resumed later
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	result, err := migrate.MigrateContext(ctx, src, dst, migrate.Options{DryRun: dryRun})
+	for _, name := range result.Copied {
+		if dryRun {
+			fmt.Printf("would copy: %s\n", name)
+		} else {
+			fmt.Printf("copied: %s\n", name)
+		}
+	}
+	for _, name := range result.Skipped {
+		fmt.Printf("skipped (already up to date): %s\n", name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migration error: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("done: %d copied, %d skipped\n", len(result.Copied), len(result.Skipped))
+}
+
+func newStorage(storageType, localPath, s3Bucket, s3Prefix string) (storage.Iface, error) {
+	switch storageType {
+	case "local":
+		if localPath == "" {
+			return localstorage.New()
+		}
+		return localstorage.NewWithPath(localPath)
+	case "s3":
+		return s3storage.New(s3Bucket, s3Prefix)
+	default:
+		return nil, fmt.Errorf("unknown storage type: %q (expected local or s3)", storageType)
+	}
+}
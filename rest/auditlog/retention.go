@@ -0,0 +1,137 @@
+package auditlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/in4it/go-devops-platform/logging"
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+const COMPRESSED_SUFFIX = storage.COMPRESSED_SUFFIX
+const RETENTION_TIMEOUT = 1 * time.Hour
+
+var dayFileRegex = regexp.MustCompile(`-(\d{4}-\d{2}-\d{2})\.log(\.gz)?$`)
+
+// RetentionPolicy applies to the day files (name-YYYY-MM-DD.log) in Dir.
+// A value of 0 or less disables compression or removal, so nothing happens without opting in.
+type RetentionPolicy struct {
+	Dir               string
+	CompressAfterDays int
+	RetentionDays     int
+}
+
+func (p RetentionPolicy) Enabled() bool {
+	return p.CompressAfterDays > 0 || p.RetentionDays > 0
+}
+
+type RetentionResult struct {
+	Compressed []string
+	Removed    []string
+}
+
+// ApplyRetention gzips day files older than CompressAfterDays and removes day files older than RetentionDays
+func ApplyRetention(storage storage.Iface, policy RetentionPolicy, now time.Time) (RetentionResult, error) {
+	return ApplyRetentionContext(context.Background(), storage, policy, now)
+}
+
+// ApplyRetentionContext is ApplyRetention with a context. When the context is done, the remaining files are left for the next run.
+func ApplyRetentionContext(ctx context.Context, storageClient storage.Iface, policy RetentionPolicy, now time.Time) (RetentionResult, error) {
+	storage := storage.WithContext(storageClient)
+	result := RetentionResult{Compressed: []string{}, Removed: []string{}}
+	dir := policy.Dir
+	if dir == "" {
+		dir = AUDITLOG_STATS_DIR
+	}
+	if !policy.Enabled() {
+		return result, nil
+	}
+	compressAfterDays := policy.CompressAfterDays
+	retentionDays := policy.RetentionDays
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	entries, err := storage.ReadDirContext(ctx, dir)
+	if err != nil {
+		if !storage.FileExistsContext(ctx, dir) {
+			return result, nil // nothing logged yet
+		}
+		return result, fmt.Errorf("readdir error: %s", err)
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		match := dayFileRegex.FindStringSubmatch(entry)
+		if match == nil {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", match[1])
+		if err != nil {
+			continue
+		}
+		age := int(today.Sub(day).Hours() / 24)
+		name := path.Join(dir, entry)
+		compressed := match[2] != ""
+		if retentionDays > 0 && age > retentionDays {
+			err = storage.RemoveContext(ctx, name)
+			if err != nil {
+				return result, fmt.Errorf("remove error (%s): %s", name, err)
+			}
+			result.Removed = append(result.Removed, name)
+			continue
+		}
+		if !compressed && compressAfterDays > 0 && age > compressAfterDays {
+			err = compressFile(ctx, storage, name)
+			if err != nil {
+				return result, fmt.Errorf("compress error (%s): %s", name, err)
+			}
+			result.Compressed = append(result.Compressed, name)
+		}
+	}
+	return result, nil
+}
+
+// compressFile writes name.gz and removes the original file. If this is interrupted, the original file is still there
+// and will be compressed again.
+func compressFile(ctx context.Context, storage storage.ContextIface, name string) error {
+	data, err := storage.ReadFileContext(ctx, name)
+	if err != nil {
+		return fmt.Errorf("read error: %s", err)
+	}
+	out := bytes.NewBuffer([]byte{})
+	gzipWriter := gzip.NewWriter(out)
+	if _, err := gzipWriter.Write(data); err != nil {
+		return fmt.Errorf("gzip error: %s", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("gzip error: %s", err)
+	}
+	err = storage.WriteFileContext(ctx, name+COMPRESSED_SUFFIX, out.Bytes())
+	if err != nil {
+		return fmt.Errorf("write error: %s", err)
+	}
+	return storage.RemoveContext(ctx, name)
+}
+
+// RetentionWorker applies the retention policy once a day. The policy is retrieved every run, so config changes are picked up.
+// Runs with a disabled policy don't change anything.
+func RetentionWorker(storage storage.Iface, policy func() RetentionPolicy) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), RETENTION_TIMEOUT)
+		result, err := ApplyRetentionContext(ctx, storage, policy(), time.Now())
+		cancel()
+		if err != nil {
+			logging.ErrorLog(fmt.Errorf("audit log retention error: %s", err))
+		}
+		if len(result.Compressed) > 0 || len(result.Removed) > 0 {
+			logging.DebugLog(fmt.Errorf("audit log retention: compressed %s, removed %s", strings.Join(result.Compressed, ","), strings.Join(result.Removed, ",")))
+		}
+		time.Sleep(time.Hour * 24)
+	}
+}
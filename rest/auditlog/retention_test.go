@@ -0,0 +1,117 @@
+package auditlog
+
+import (
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	storagepkg "github.com/in4it/go-devops-platform/storage"
+	localstorage "github.com/in4it/go-devops-platform/storage/local"
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestApplyRetention(t *testing.T) {
+	storage, err := localstorage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("new storage error: %s", err)
+	}
+	err = storage.EnsurePath(AUDITLOG_STATS_DIR)
+	if err != nil {
+		t.Fatalf("ensure path error: %s", err)
+	}
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+	files := map[string]string{
+		"logins-2024-06-29.log": "recent\n",
+		"logins-2024-06-01.log": "old\n",
+		"logins-2024-06-02.log": "old2\n",
+		"logins-2023-01-01.log": "expired\n",
+		"other.txt":             "not a day file",
+	}
+	for name, contents := range files {
+		err = storage.WriteFile(path.Join(AUDITLOG_STATS_DIR, name), []byte(contents))
+		if err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	// without a policy, nothing is compressed or removed
+	result, err := ApplyRetention(storage, RetentionPolicy{}, now)
+	if err != nil || len(result.Compressed) != 0 || len(result.Removed) != 0 {
+		t.Fatalf("expected no changes without a policy: %+v (%v)", result, err)
+	}
+	result, err = ApplyRetention(storage, RetentionPolicy{CompressAfterDays: 7, RetentionDays: 90}, now)
+	if err != nil {
+		t.Fatalf("apply retention error: %s", err)
+	}
+	slices.Sort(result.Compressed)
+	if !slices.Equal(result.Compressed, []string{"stats/logins-2024-06-01.log", "stats/logins-2024-06-02.log"}) {
+		t.Fatalf("unexpected compressed files: %v", result.Compressed)
+	}
+	if !slices.Equal(result.Removed, []string{"stats/logins-2023-01-01.log"}) {
+		t.Fatalf("unexpected removed files: %v", result.Removed)
+	}
+	if !storage.FileExists("stats/logins-2024-06-01.log.gz") || storage.FileExists("stats/logins-2024-06-01.log") {
+		t.Fatalf("file not compressed")
+	}
+	if !storage.FileExists("stats/other.txt") || !storage.FileExists("stats/logins-2024-06-29.log") {
+		t.Fatalf("file removed that should be kept")
+	}
+
+	// compressed files are removed after the retention period
+	result, err = ApplyRetention(storage, RetentionPolicy{CompressAfterDays: 7, RetentionDays: 90}, now.AddDate(0, 6, 0))
+	if err != nil {
+		t.Fatalf("apply retention error: %s", err)
+	}
+	if storage.FileExists("stats/logins-2024-06-01.log.gz") {
+		t.Fatalf("compressed file not removed: %v", result.Removed)
+	}
+}
+
+func TestOpenFilesFromPos(t *testing.T) {
+	localStorage, err := localstorage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("new storage error: %s", err)
+	}
+	for _, storage := range []storagepkg.Iface{localStorage, &memorystorage.MockMemoryStorage{}} {
+		err = storage.EnsurePath(AUDITLOG_STATS_DIR)
+		if err != nil {
+			t.Fatalf("ensure path error: %s", err)
+		}
+		names := []string{"stats/logins-2024-06-01.log", "stats/logins-2024-06-02.log", "stats/logins-2024-06-03.log"}
+		contents := []string{"first day\n", "second day\n", "third day\n"}
+		for k, name := range names {
+			err = storage.WriteFile(name, []byte(contents[k]))
+			if err != nil {
+				t.Fatalf("write file error: %s", err)
+			}
+		}
+		_, err = ApplyRetention(storage, RetentionPolicy{CompressAfterDays: 1}, time.Date(2024, 6, 4, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("apply retention error: %s", err)
+		}
+		if !storage.FileExists(names[0]+COMPRESSED_SUFFIX) || !storage.FileExists(names[2]) {
+			t.Fatalf("expected only the first two files to be compressed")
+		}
+		all := strings.Join(contents, "")
+		for _, pos := range []int64{0, 3, int64(len(contents[0])), int64(len(contents[0]) + len(contents[1]) + 2), int64(len(all))} {
+			files, err := storage.OpenFilesFromPos(names, pos)
+			if err != nil {
+				t.Fatalf("open files error: %s", err)
+			}
+			out := ""
+			for _, file := range files {
+				body, err := io.ReadAll(file)
+				if err != nil {
+					t.Fatalf("read error: %s", err)
+				}
+				file.Close()
+				out += string(body)
+			}
+			if out != all[pos:] {
+				t.Fatalf("unexpected output (pos %d): %q", pos, out)
+			}
+		}
+	}
+}
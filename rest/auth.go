@@ -35,18 +35,25 @@ func (c *Context) authHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// check login attempts
-	tooManyLogins := login.CheckTooManyLogins(c.LoginAttempts, loginReq.Login)
-	if tooManyLogins {
+	// check login attempts, for the account and the ip address. Unknown logins only count for the ip address, so they don't fill the lockout file.
+	lockoutKeys := []string{login.IPKey(clientIP(r))}
+	if c.UserStore.LoginExists(loginReq.Login) {
+		lockoutKeys = append(lockoutKeys, login.AccountKey(loginReq.Login))
+	}
+	if c.loginLocked(lockoutKeys...) {
 		c.returnError(w, fmt.Errorf("too many login failures, try again later"), http.StatusTooManyRequests)
 		return
 	}
 
 	loginResponse, user, err := login.Authenticate(loginReq, c.UserStore, c.JWTKeys.PrivateKey, c.JWTKeysKID)
 	if err != nil {
+		c.recordLoginFailure(lockoutKeys...)
 		c.returnError(w, fmt.Errorf("authentication error: %s", err), http.StatusBadRequest)
 		return
 	}
+	if loginResponse.Authenticated {
+		loginResponse.PasswordExpired = c.UserStore.PasswordExpired(user.ID)
+	}
 	out, err := json.Marshal(loginResponse)
 	if err != nil {
 		c.returnError(w, fmt.Errorf("unable to marshal response: %s", err), http.StatusBadRequest)
@@ -56,16 +63,15 @@ func (c *Context) authHandler(w http.ResponseWriter, r *http.Request) {
 		c.write(w, out) // status ok, but unauthorized, because we need a second call with MFA code
 		return
 	} else if loginResponse.Authenticated {
-		login.ClearAttemptsForLogin(c.LoginAttempts, loginReq.Login)
-		user.LastLogin = users.TimeOrEmpty(time.Now())
-		err = c.UserStore.UpdateUser(user)
+		c.clearLoginFailures(login.AccountKey(loginReq.Login))
+		err = c.UserStore.RecordLogin(user.ID, "local")
 		if err != nil {
 			logging.ErrorLog(fmt.Errorf("last login update error: %s", err))
 		}
 		c.write(w, out)
 	} else {
-		// log login attempts
-		login.RecordAttempt(c.LoginAttempts, loginReq.Login)
+		// log login attempts (wrong password or wrong MFA code)
+		c.recordLoginFailure(lockoutKeys...)
 		// return Unauthorized
 		c.writeWithStatus(w, out, http.StatusUnauthorized)
 	}
@@ -212,7 +218,7 @@ func (c *Context) authMethodsByID(w http.ResponseWriter, r *http.Request) {
 			}
 
 			// add user to the user database (or modify existing one)
-			user, err := addOrModifyExternalUser(c.Storage.Client, c.UserStore, samlSession.Login, "saml", samlSession.ID)
+			user, err := addOrModifyExternalUser(c.UserStore, samlSession.Login, "saml", samlSession.ID, samlSession.Profile)
 			if err != nil {
 				c.returnError(w, fmt.Errorf("couldn't add/modify user in database: %s", err), http.StatusBadRequest)
 				return
@@ -289,7 +295,7 @@ func (c *Context) authMethodsByID(w http.ResponseWriter, r *http.Request) {
 						return
 					}
 					// add user to the user database (or modify existing one)
-					user, err := addOrModifyExternalUser(c.Storage.Client, c.UserStore, updatedOauth2data.UserInfo.Email, "oidc", updatedOauth2data.ID)
+					user, err := addOrModifyExternalUser(c.UserStore, updatedOauth2data.UserInfo.Email, "oidc", updatedOauth2data.ID, oidcProfile(updatedOauth2data.UserInfo))
 					if err != nil {
 						c.returnError(w, fmt.Errorf("couldn't add/modify user in database: %s", err), http.StatusBadRequest)
 						return
@@ -477,15 +483,15 @@ func (c *Context) oidcRenewTokensHandler(w http.ResponseWriter, r *http.Request)
 		for _, user := range disabledUsers {
 			logging.DebugLog(fmt.Errorf("disable user with oidc id %s", user.ID))
 
-			err := c.UserStore.UserHooks.DisableFunc(c.Storage.Client, user)
+			user.ConnectionsDisabledOnAuthFailure = true
+			err := c.UserStore.UpdateUser(user)
 			if err != nil {
-				c.returnError(w, fmt.Errorf("DisableAllClientConfigs error for userID %s: %s", user.ID, err), http.StatusBadRequest)
+				c.returnError(w, fmt.Errorf("could not update connectionsDisabledOnAuthFailure user with userID %s: %s", user.ID, err), http.StatusBadRequest)
 				return
 			}
-			user.ConnectionsDisabledOnAuthFailure = true
-			err = c.UserStore.UpdateUser(user)
+			err = c.UserStore.Events().Dispatch(users.Event{Type: users.EVENT_USER_DISABLED, User: user}) // the hooks are executed by the outbox worker
 			if err != nil {
-				c.returnError(w, fmt.Errorf("could not update connectionsDisabledOnAuthFailure user with userID %s: %s", user.ID, err), http.StatusBadRequest)
+				c.returnError(w, fmt.Errorf("could not queue disabled event for userID %s: %s", user.ID, err), http.StatusBadRequest)
 				return
 			}
 		}
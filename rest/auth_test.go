@@ -92,6 +92,11 @@ func TestAuthHandler(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Cannot create user")
 	}
+	logins := []string{}
+	c.UserStore.Events().Subscribe("test", func(event users.Event) error {
+		logins = append(logins, event.User.Login+":"+event.Method)
+		return nil
+	}, users.EVENT_USER_LOGIN)
 
 	loginReq := login.LoginRequest{
 		Login:    "john",
@@ -125,7 +130,9 @@ func TestAuthHandler(t *testing.T) {
 	if !loginResponse.Authenticated {
 		t.Fatalf("expected to be authenticated")
 	}
-
+	if len(logins) != 1 || logins[0] != "john:local" {
+		t.Fatalf("expected login event, got: %v", logins)
+	}
 }
 
 func TestNewSAMLConnection(t *testing.T) {
@@ -650,7 +657,7 @@ func TestSAMLCallback(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	c.SAML.Client.CreateSession(saml.SessionKey{ProviderID: samlProvider.ID, SessionID: "abc"}, saml.AuthenticatedUser{ID: "123", Login: "john@example.com", ExpiresAt: time.Now().AddDate(0, 0, 1)})
+	c.SAML.Client.CreateSession(saml.SessionKey{ProviderID: samlProvider.ID, SessionID: "abc"}, saml.AuthenticatedUser{ID: "123", Login: "john@example.com", ExpiresAt: time.Now().AddDate(0, 0, 1), Profile: users.Profile{GivenName: "John", Attributes: map[string]string{"department": "engineering"}}})
 
 	req = httptest.NewRequest("POST", "http://example.com/api/authmethods/saml/"+samlProvider.ID, bytes.NewBuffer(payload))
 	req.SetPathValue("method", "saml")
@@ -676,6 +683,13 @@ func TestSAMLCallback(t *testing.T) {
 		t.Fatalf("Expected to be authenticated")
 	}
 
+	user, err := c.UserStore.GetUserByLogin("john@example.com")
+	if err != nil {
+		t.Fatalf("get user error: %s", err)
+	}
+	if user.GivenName != "John" || user.Attributes["department"] != "engineering" {
+		t.Fatalf("profile not stored: %+v", user.Profile)
+	}
 }
 
 func TestOIDCFlow(t *testing.T) {
@@ -799,6 +813,7 @@ func TestOIDCFlow(t *testing.T) {
 					"iss":   "test-server",
 					"sub":   "john",
 					"email": "john@example.inv",
+					"name":  "John Doe",
 					"role":  "user",
 					"exp":   time.Now().AddDate(0, 0, 1).Unix(),
 					"iat":   time.Now().Unix(),
@@ -943,6 +958,13 @@ func TestOIDCFlow(t *testing.T) {
 	if loginResponse.Token == "" {
 		t.Fatalf("no token received: %+v", loginResponse)
 	}
+	user, err := c.UserStore.GetUserByLogin("john@example.inv")
+	if err != nil {
+		t.Fatalf("get user error: %s", err)
+	}
+	if user.DisplayName != "John Doe" || user.Email != "john@example.inv" {
+		t.Fatalf("profile not stored: %+v", user.Profile)
+	}
 }
 
 func TestOIDCRedirect(t *testing.T) {
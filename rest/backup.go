@@ -0,0 +1,422 @@
+package rest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	oidcstore "github.com/in4it/go-devops-platform/auth/oidc/store"
+	"github.com/in4it/go-devops-platform/logging"
+	"github.com/in4it/go-devops-platform/rest/login"
+	"github.com/in4it/go-devops-platform/storage"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+const BACKUP_VERSION = 1
+const BACKUP_DIR = "backups"
+const BACKUP_MANIFEST = "manifest.json"
+const BACKUP_SIGNATURE = "manifest.sig"
+const MAX_RESTORE_SIZE = 100 * 1024 * 1024
+const BACKUP_TRUSTED_KEY = "pki/backup-trusted.pem" // public key of another install, placed by the operator to restore its backups
+
+func (c *Context) backupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	files, err := c.backupFiles(r.Context())
+	if err != nil {
+		c.returnError(w, fmt.Errorf("backup error: %s", err), http.StatusBadRequest)
+		return
+	}
+	sendCorsHeaders(w, "", c.Hostname, c.Protocol)
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="backup-`+time.Now().UTC().Format("20060102-150405")+`.tar.gz"`)
+	w.WriteHeader(http.StatusOK)
+	err = c.writeBackup(r.Context(), w, files)
+	if err != nil { // headers are already sent, so we can only log
+		logging.ErrorLog(fmt.Errorf("backup error: %s", err))
+	}
+}
+
+func (c *Context) restoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	files, err := c.readBackup(http.MaxBytesReader(w, r.Body, MAX_RESTORE_SIZE))
+	if err != nil {
+		c.returnError(w, fmt.Errorf("invalid backup: %s", err), http.StatusBadRequest)
+		return
+	}
+	preRestoreBackup, err := c.writePreRestoreBackup(r.Context())
+	if err != nil {
+		c.returnError(w, fmt.Errorf("could not create pre-restore backup: %s", err), http.StatusBadRequest)
+		return
+	}
+	// don't stop halfway when the client goes away, once we start writing
+	restored, err := c.restoreBackup(context.WithoutCancel(r.Context()), files)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("restore error (pre-restore backup: %s): %s", preRestoreBackup, err), http.StatusBadRequest)
+		return
+	}
+	out, err := json.Marshal(RestoreResponse{Restored: restored, PreRestoreBackup: preRestoreBackup})
+	if err != nil {
+		c.returnError(w, fmt.Errorf("restore response marshal error: %s", err), http.StatusBadRequest)
+		return
+	}
+	c.write(w, out)
+}
+
+// isRuntimeStateFile returns true for files with state that must not be rolled back: used or revoked tokens, lockout counters and already delivered events
+func (c *Context) isRuntimeStateFile(name string) bool {
+	for _, filename := range []string{users.TOKENSTORE_FILENAME, login.LOCKOUT_FILENAME, users.OUTBOX_FILENAME} {
+		if name == c.Storage.Client.ConfigPath(filename) {
+			return true
+		}
+	}
+	return false
+}
+
+// backupFiles returns all files under the config path, without snapshots, lock files and runtime state
+func (c *Context) backupFiles(ctx context.Context) ([]string, error) {
+	files := []string{}
+	err := storage.WalkContext(ctx, c.Storage.Client, strings.TrimSuffix(c.Storage.Client.ConfigPath(""), "/"), func(name string) error {
+		if !strings.HasSuffix(name, storage.LOCK_SUFFIX) && !c.isRuntimeStateFile(name) {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return files, err
+	}
+	snapshotter, ok := c.Storage.Client.(storage.Snapshotter)
+	if !ok {
+		sort.Strings(files)
+		return files, nil
+	}
+	snapshots := make(map[string]bool)
+	for _, name := range files {
+		names, err := snapshotter.Snapshots(name)
+		if err != nil {
+			return files, fmt.Errorf("could not list snapshots: %s", err)
+		}
+		for _, snapshot := range names {
+			snapshots[snapshot] = true
+		}
+	}
+	res := []string{}
+	for _, name := range files {
+		if !snapshots[name] {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// writeBackup writes the files to a tar.gz, followed by a manifest with the checksums of the files and the signature of the manifest
+func (c *Context) writeBackup(ctx context.Context, w io.Writer, files []string) error {
+	if c.JWTKeys == nil || c.JWTKeys.PrivateKey == nil {
+		return fmt.Errorf("no key available to sign the backup")
+	}
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	manifest := BackupManifest{
+		Version:   BACKUP_VERSION,
+		CreatedAt: time.Now().UTC(),
+		Hostname:  c.Hostname,
+		Files:     make(map[string]string),
+	}
+	for _, name := range files {
+		data, err := storage.WithContext(c.Storage.Client).ReadFileContext(ctx, name)
+		if err != nil {
+			return fmt.Errorf("read error (%s): %s", name, err)
+		}
+		err = writeTarFile(tarWriter, name, data)
+		if err != nil {
+			return err
+		}
+		checksum := sha256.Sum256(data)
+		manifest.Files[name] = hex.EncodeToString(checksum[:])
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(c.JWTKeys.PublicKey)
+	if err != nil {
+		return fmt.Errorf("public key marshal error: %s", err)
+	}
+	manifest.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}))
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("manifest marshal error: %s", err)
+	}
+	hash := sha256.Sum256(manifestBytes)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.JWTKeys.PrivateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return fmt.Errorf("sign error: %s", err)
+	}
+	err = writeTarFile(tarWriter, BACKUP_MANIFEST, manifestBytes)
+	if err != nil {
+		return err
+	}
+	err = writeTarFile(tarWriter, BACKUP_SIGNATURE, signature)
+	if err != nil {
+		return err
+	}
+	err = tarWriter.Close()
+	if err != nil {
+		return fmt.Errorf("tar close error: %s", err)
+	}
+	return gzipWriter.Close()
+}
+
+func writeTarFile(tarWriter *tar.Writer, name string, data []byte) error {
+	err := tarWriter.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("tar header error (%s): %s", name, err)
+	}
+	_, err = tarWriter.Write(data)
+	if err != nil {
+		return fmt.Errorf("tar write error (%s): %s", name, err)
+	}
+	return nil
+}
+
+// readBackup reads and validates a backup: signature, checksums, filenames and schema of the config files.
+// The signature is verified with our own key, or with BACKUP_TRUSTED_KEY (e.g. to restore on a new install).
+func (c *Context) readBackup(r io.Reader) (map[string][]byte, error) {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("gzip error: %s", err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	files := make(map[string][]byte)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tar error: %s", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected entry in archive: %s", header.Name)
+		}
+		if _, ok := files[header.Name]; ok {
+			return nil, fmt.Errorf("duplicate entry in archive: %s", header.Name)
+		}
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("read error (%s): %s", header.Name, err)
+		}
+		files[header.Name] = data
+	}
+
+	manifestBytes, ok := files[BACKUP_MANIFEST]
+	if !ok {
+		return nil, fmt.Errorf("manifest not found")
+	}
+	signature, ok := files[BACKUP_SIGNATURE]
+	if !ok {
+		return nil, fmt.Errorf("manifest signature not found")
+	}
+	delete(files, BACKUP_MANIFEST)
+	delete(files, BACKUP_SIGNATURE)
+	var manifest BackupManifest
+	err = json.Unmarshal(manifestBytes, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("manifest decode error: %s", err)
+	}
+	if manifest.Version != BACKUP_VERSION {
+		return nil, fmt.Errorf("unsupported backup version: %d", manifest.Version)
+	}
+	publicKeys, err := c.backupVerificationKeys()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(manifestBytes)
+	for _, publicKey := range publicKeys {
+		err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signature verification failed (backup created by a different install? Place its public key in %s): %s", BACKUP_TRUSTED_KEY, err)
+	}
+
+	configPrefix := strings.TrimSuffix(c.Storage.Client.ConfigPath(""), "/") + "/"
+	for name, data := range files {
+		if path.Clean(name) != name || !strings.HasPrefix(name, configPrefix) {
+			return nil, fmt.Errorf("invalid filename in archive: %s", name)
+		}
+		checksum := sha256.Sum256(data)
+		if manifest.Files[name] != hex.EncodeToString(checksum[:]) {
+			return nil, fmt.Errorf("checksum mismatch: %s", name)
+		}
+	}
+	for name := range manifest.Files {
+		if _, ok := files[name]; !ok {
+			return nil, fmt.Errorf("file missing in archive: %s", name)
+		}
+	}
+	err = c.validateBackupSchema(files)
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// backupVerificationKeys returns our own public key and the trusted key configured by the operator. The key in the archive is never trusted.
+func (c *Context) backupVerificationKeys() ([]*rsa.PublicKey, error) {
+	publicKeys := []*rsa.PublicKey{}
+	if c.JWTKeys != nil && c.JWTKeys.PublicKey != nil {
+		publicKeys = append(publicKeys, c.JWTKeys.PublicKey)
+	}
+	trustedKeyPath := c.Storage.Client.ConfigPath(BACKUP_TRUSTED_KEY)
+	if c.Storage.Client.FileExists(trustedKeyPath) {
+		data, err := c.Storage.Client.ReadFile(trustedKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("can't read %s: %s", BACKUP_TRUSTED_KEY, err)
+		}
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse %s: %s", BACKUP_TRUSTED_KEY, err)
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	if len(publicKeys) == 0 {
+		return nil, fmt.Errorf("no key available to verify the backup")
+	}
+	return publicKeys, nil
+}
+
+func (c *Context) validateBackupSchema(files map[string][]byte) error {
+	configData, ok := files[c.Storage.Client.ConfigPath("config.json")]
+	if !ok {
+		return fmt.Errorf("config.json not found in archive")
+	}
+	var config *Context
+	if err := json.Unmarshal(configData, &config); err != nil || config == nil {
+		return fmt.Errorf("invalid config.json: %v", err)
+	}
+	if data, ok := files[c.Storage.Client.ConfigPath(users.USERSTORE_FILENAME)]; ok {
+		var userList []users.User
+		if err := json.Unmarshal(data, &userList); err != nil {
+			return fmt.Errorf("invalid %s: %s", users.USERSTORE_FILENAME, err)
+		}
+	}
+	if data, ok := files[c.Storage.Client.ConfigPath(users.GROUPSTORE_FILENAME)]; ok {
+		var groupList []users.Group
+		if err := json.Unmarshal(data, &groupList); err != nil {
+			return fmt.Errorf("invalid %s: %s", users.GROUPSTORE_FILENAME, err)
+		}
+	}
+	if data, ok := files[c.Storage.Client.ConfigPath(oidcstore.DEFAULT_PATH)]; ok {
+		var store oidcstore.Store
+		if err := json.Unmarshal(data, &store); err != nil {
+			return fmt.Errorf("invalid %s: %s", oidcstore.DEFAULT_PATH, err)
+		}
+	}
+	if data, ok := files[c.Storage.Client.ConfigPath("pki/private.pem")]; ok {
+		if _, err := jwt.ParseRSAPrivateKeyFromPEM(data); err != nil {
+			return fmt.Errorf("invalid private key: %s", err)
+		}
+	}
+	if data, ok := files[c.Storage.Client.ConfigPath("pki/public.pem")]; ok {
+		if _, err := jwt.ParseRSAPublicKeyFromPEM(data); err != nil {
+			return fmt.Errorf("invalid public key: %s", err)
+		}
+	}
+	return nil
+}
+
+// writePreRestoreBackup saves a backup of the current config, so a restore can be undone
+func (c *Context) writePreRestoreBackup(ctx context.Context) (string, error) {
+	files, err := c.backupFiles(ctx)
+	if err != nil {
+		return "", err
+	}
+	out := bytes.NewBuffer([]byte{})
+	err = c.writeBackup(ctx, out, files)
+	if err != nil {
+		return "", err
+	}
+	err = c.Storage.Client.EnsurePath(BACKUP_DIR)
+	if err != nil {
+		return "", fmt.Errorf("ensure path error: %s", err)
+	}
+	filename := path.Join(BACKUP_DIR, "pre-restore-"+time.Now().UTC().Format("20060102-150405.000000000")+".tar.gz")
+	err = storage.WithContext(c.Storage.Client).WriteFileContext(ctx, filename, out.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("write error: %s", err)
+	}
+	return filename, nil
+}
+
+// restoreBackup writes the files of the backup and reloads the config. Files that are not in the backup are kept.
+// Runtime state in older backups is skipped. Every file is written under its lock, so other instances don't interleave their changes.
+func (c *Context) restoreBackup(ctx context.Context, files map[string][]byte) ([]string, error) {
+	restored := []string{}
+	for name := range files {
+		if !c.isRuntimeStateFile(name) {
+			restored = append(restored, name)
+		}
+	}
+	sort.Strings(restored)
+	for _, name := range restored {
+		err := storage.EnsurePathAll(c.Storage.Client, path.Dir(name))
+		if err != nil {
+			return restored, fmt.Errorf("ensure path error (%s): %s", name, err)
+		}
+		err = storage.WithLock(c.Storage.Client, name, func() error {
+			return storage.WithContext(c.Storage.Client).WriteFileContext(ctx, name, files[name])
+		})
+		if err != nil {
+			return restored, fmt.Errorf("write error (%s): %s", name, err)
+		}
+	}
+	jwtKeys, err := getJWTKeys(c.Storage.Client)
+	if err != nil {
+		return restored, fmt.Errorf("getJWTKeys error: %s", err)
+	}
+	c.JWTKeys = jwtKeys
+	_, err = c.loadConfig() // the restore handler holds the config read lock
+	if err != nil {
+		return restored, fmt.Errorf("reload config error: %s", err)
+	}
+	_, err = c.UserStore.Reload()
+	if err != nil {
+		return restored, fmt.Errorf("reload users error: %s", err)
+	}
+	_, err = c.UserStore.Groups().Reload()
+	if err != nil {
+		return restored, fmt.Errorf("reload groups error: %s", err)
+	}
+	_, err = c.OIDCStore.Reload()
+	if err != nil {
+		return restored, fmt.Errorf("reload oidc store error: %s", err)
+	}
+	return restored, nil
+}
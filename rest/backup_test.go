@@ -0,0 +1,214 @@
+package rest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	c, err := newContext(storage, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	c.Hostname = "vpn.example.com"
+	err = SaveConfig(c)
+	if err != nil {
+		t.Fatalf("save config error: %s", err)
+	}
+	_, err = c.UserStore.AddUser(users.User{Login: "john", Password: "mypass", Role: "user"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	storage.WriteFile(storage.ConfigPath("license.key"), []byte("license"))
+
+	req := httptest.NewRequest("GET", "http://example.com/api/backup", nil)
+	w := httptest.NewRecorder()
+	c.backupHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("backup status code is not 200: %d: %s", w.Code, w.Body.String())
+	}
+	backup := w.Body.Bytes()
+
+	// change the config after the backup
+	c.Hostname = "other.example.com"
+	err = SaveConfig(c)
+	if err != nil {
+		t.Fatalf("save config error: %s", err)
+	}
+	err = c.UserStore.DeleteUserByLogin("john")
+	if err != nil {
+		t.Fatalf("delete user error: %s", err)
+	}
+	storage.Remove(storage.ConfigPath("license.key"))
+
+	req = httptest.NewRequest("POST", "http://example.com/api/restore", bytes.NewBuffer(backup))
+	w = httptest.NewRecorder()
+	c.restoreHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore status code is not 200: %d: %s", w.Code, w.Body.String())
+	}
+	var restoreResponse RestoreResponse
+	err = json.Unmarshal(w.Body.Bytes(), &restoreResponse)
+	if err != nil {
+		t.Fatalf("unmarshal error: %s", err)
+	}
+	if !storage.FileExists(restoreResponse.PreRestoreBackup) {
+		t.Fatalf("pre-restore backup not found: %s", restoreResponse.PreRestoreBackup)
+	}
+	if c.Hostname != "vpn.example.com" {
+		t.Fatalf("config not reloaded. Hostname: %s", c.Hostname)
+	}
+	if !c.UserStore.LoginExists("john") {
+		t.Fatalf("users not reloaded")
+	}
+	if !storage.FileExists(storage.ConfigPath("license.key")) {
+		t.Fatalf("license key not restored")
+	}
+
+	// backup of another install is only accepted when the operator trusts its key
+	storage2 := &memorystorage.MockMemoryStorage{}
+	c2, err := newContext(storage2, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	_, err = c2.readBackup(bytes.NewBuffer(backup))
+	if err == nil {
+		t.Fatalf("expected signature error")
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(c.JWTKeys.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key error: %s", err)
+	}
+	err = storage2.WriteFile(storage2.ConfigPath(BACKUP_TRUSTED_KEY), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}))
+	if err != nil {
+		t.Fatalf("write trusted key error: %s", err)
+	}
+	_, err = c2.readBackup(bytes.NewBuffer(backup))
+	if err != nil {
+		t.Fatalf("read backup error: %s", err)
+	}
+}
+
+func TestRestoreTampered(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	err = SaveConfig(c)
+	if err != nil {
+		t.Fatalf("save config error: %s", err)
+	}
+	files, err := c.backupFiles(context.Background())
+	if err != nil {
+		t.Fatalf("backup files error: %s", err)
+	}
+	backup := bytes.NewBuffer([]byte{})
+	err = c.writeBackup(context.Background(), backup, files)
+	if err != nil {
+		t.Fatalf("write backup error: %s", err)
+	}
+
+	// repack the archive with a modified config.json
+	gzipReader, err := gzip.NewReader(backup)
+	if err != nil {
+		t.Fatalf("gzip error: %s", err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	tampered := bytes.NewBuffer([]byte{})
+	gzipWriter := gzip.NewWriter(tampered)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar error: %s", err)
+		}
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			t.Fatalf("read error: %s", err)
+		}
+		if header.Name == c.Storage.Client.ConfigPath("config.json") {
+			data = []byte(`{"hostname": "tampered"}`)
+		}
+		err = writeTarFile(tarWriter, header.Name, data)
+		if err != nil {
+			t.Fatalf("write tar error: %s", err)
+		}
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+
+	_, err = c.readBackup(tampered)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum error, got: %v", err)
+	}
+}
+
+func TestBackupSkipsRuntimeState(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	c, err := newContext(storage, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	err = SaveConfig(c)
+	if err != nil {
+		t.Fatalf("save config error: %s", err)
+	}
+	tokensFile := storage.ConfigPath(users.TOKENSTORE_FILENAME)
+	err = storage.WriteFile(tokensFile, []byte(`{"old":"token"}`))
+	if err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	files, err := c.backupFiles(context.Background())
+	if err != nil {
+		t.Fatalf("backup files error: %s", err)
+	}
+	for _, name := range files {
+		if name == tokensFile {
+			t.Fatalf("runtime state in backup: %s", name)
+		}
+	}
+
+	// older backups can still contain runtime state
+	err = storage.WriteFile(tokensFile, []byte(`{}`))
+	if err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	configData, err := storage.ReadFile(storage.ConfigPath("config.json"))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	restored, err := c.restoreBackup(context.Background(), map[string][]byte{
+		storage.ConfigPath("config.json"): configData,
+		tokensFile:                        []byte(`{"old":"token"}`),
+	})
+	if err != nil {
+		t.Fatalf("restore error: %s", err)
+	}
+	if len(restored) != 1 || restored[0] != storage.ConfigPath("config.json") {
+		t.Fatalf("unexpected restored files: %v", restored)
+	}
+	data, err := storage.ReadFile(tokensFile)
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	if string(data) != `{}` {
+		t.Fatalf("runtime state was restored: %s", data)
+	}
+}
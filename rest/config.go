@@ -2,17 +2,40 @@ package rest
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/user"
 	"sync"
 
+	"github.com/in4it/go-devops-platform/logging"
+	"github.com/in4it/go-devops-platform/rest/auditlog"
 	"github.com/in4it/go-devops-platform/storage"
 )
 
 var mu sync.Mutex
 
+// configMu is held for reading while a request is handled, and for writing when config.json is reloaded (see configLockMiddleware).
+// Workers that read the config outside of a request take the read lock too.
+var configMu sync.RWMutex
+var auditLogRetentionWorkerOnce sync.Once
+
+var ErrConfigConflict = fmt.Errorf("config.json was changed by another instance since it was loaded, the config is reloaded: try again")
+
+// SaveConfig writes config.json. When another instance changed config.json since it was loaded or saved, nothing is written,
+// the config is reloaded (discarding the unsaved changes) and ErrConfigConflict is returned.
 func SaveConfig(c *Context) error {
+	err := saveConfig(c)
+	if errors.Is(err, ErrConfigConflict) {
+		if _, reloadErr := c.loadConfig(); reloadErr != nil { // called by handlers, which hold the read lock already
+			logging.ErrorLog(fmt.Errorf("reload config after conflict error: %s", reloadErr))
+		}
+	}
+	return err
+}
+
+func saveConfig(c *Context) error {
 	mu.Lock()
 	defer mu.Unlock()
 	cCopy := *c
@@ -27,6 +50,7 @@ func SaveConfig(c *Context) error {
 	cCopy.OIDCStore = nil     // we save this separately
 	cCopy.UserStore = nil     // we save this separately
 	cCopy.OIDCRenewal = nil   // we don't save this
+	cCopy.LoginLockout = nil  // we don't save this
 	cCopy.LoginAttempts = nil // no need to save this
 	cCopy.Apps = nil          // no need to save the app client
 	cCopy.Storage = nil       // no need to save storage
@@ -34,10 +58,20 @@ func SaveConfig(c *Context) error {
 	if err != nil {
 		return fmt.Errorf("context marshal error: %s", err)
 	}
-	err = c.Storage.Client.WriteFile(c.Storage.Client.ConfigPath("config.json"), out)
+	err = storage.WithLock(c.Storage.Client, c.Storage.Client.ConfigPath("config.json"), func() error {
+		current, err := c.Storage.Client.ReadFile(c.Storage.Client.ConfigPath("config.json"))
+		if err == nil && sha256.Sum256(current) != c.configHash && configDecodes(current) { // a corrupt config.json is overwritten
+			return ErrConfigConflict
+		}
+		return c.Storage.Client.WriteFile(c.Storage.Client.ConfigPath("config.json"), out)
+	})
+	if errors.Is(err, ErrConfigConflict) {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("config write error: %s", err)
 	}
+	c.configHash = sha256.Sum256(out)
 	// fix permissions
 	currentUser, err := user.Current()
 	if err != nil {
@@ -53,27 +87,58 @@ func SaveConfig(c *Context) error {
 	return nil
 }
 
-func GetConfig(storage storage.Iface) (*Context, error) {
+// configDecodes returns true when data is a config.json that GetConfig can load
+func configDecodes(data []byte) bool {
+	var c *Context
+	return json.NewDecoder(bytes.NewBuffer(data)).Decode(&c) == nil && c != nil
+}
+
+func GetConfig(storageClient storage.Iface) (*Context, error) {
 	var c *Context
 
-	appDir := storage.GetPath()
+	appDir := storageClient.GetPath()
 
 	// check if config exists
-	if !storage.FileExists(storage.ConfigPath("config.json")) {
+	if !storageClient.FileExists(storageClient.ConfigPath("config.json")) {
 		return getEmptyContext(appDir)
 	}
 
-	data, err := storage.ReadFile(storage.ConfigPath("config.json"))
+	// falls back to the last good snapshot if config.json is corrupt
+	err := storage.ReadFileWithFallback(storageClient, storageClient.ConfigPath("config.json"), func(data []byte) error {
+		c = nil
+		err := json.NewDecoder(bytes.NewBuffer(data)).Decode(&c)
+		if err == nil && c != nil {
+			c.configHash = sha256.Sum256(data)
+		}
+		return err
+	})
 	if err != nil {
 		return c, fmt.Errorf("config read error: %s", err)
 	}
-	decoder := json.NewDecoder(bytes.NewBuffer(data))
-	err = decoder.Decode(&c)
-	if err != nil {
-		return c, fmt.Errorf("decode input error: %s", err)
-	}
 
 	c.AppDir = appDir
 
 	return c, nil
 }
+
+// startAuditLogRetentionWorker starts the retention worker once, when a retention policy is configured
+func (c *Context) startAuditLogRetentionWorker() {
+	if !c.auditLogRetentionPolicy().Enabled() {
+		return
+	}
+	auditLogRetentionWorkerOnce.Do(func() {
+		go auditlog.RetentionWorker(c.Storage.Client, func() auditlog.RetentionPolicy {
+			configMu.RLock()
+			defer configMu.RUnlock()
+			return c.auditLogRetentionPolicy()
+		})
+	})
+}
+
+func (c *Context) auditLogRetentionPolicy() auditlog.RetentionPolicy {
+	return auditlog.RetentionPolicy{
+		Dir:               auditlog.AUDITLOG_STATS_DIR,
+		CompressAfterDays: c.AuditLogCompressDays,
+		RetentionDays:     c.AuditLogRetentionDays,
+	}
+}
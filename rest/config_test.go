@@ -0,0 +1,150 @@
+package rest
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	localstorage "github.com/in4it/go-devops-platform/storage/local"
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestSaveConfigWriteError(t *testing.T) {
+	storageClient := &memorystorage.MockMemoryStorage{}
+	c, err := newContext(storageClient, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	err = SaveConfig(c)
+	if err != nil {
+		t.Fatalf("save config error: %s", err)
+	}
+	configHash := c.configHash
+	before, err := storageClient.ReadFile(storageClient.ConfigPath("config.json"))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+
+	storageClient.InjectFault(memorystorage.Fault{Op: memorystorage.OpWrite, Prefix: storageClient.ConfigPath("config.json"), Err: memorystorage.ErrInjectedFault})
+	c.Hostname = "vpn.example.com"
+	err = SaveConfig(c)
+	if err == nil {
+		t.Fatalf("expected save config to fail")
+	}
+	if c.configHash != configHash {
+		t.Fatalf("config hash changed after failed write")
+	}
+	after, err := storageClient.ReadFile(storageClient.ConfigPath("config.json"))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	if string(before) != string(after) {
+		t.Fatalf("config changed after failed write")
+	}
+
+	// a truncated write is detected when the config is loaded again
+	storageClient.ClearFaults()
+	storageClient.InjectFault(memorystorage.Fault{Op: memorystorage.OpWrite, Prefix: storageClient.ConfigPath("config.json"), Partial: 10})
+	err = SaveConfig(c)
+	if err != nil {
+		t.Fatalf("save config error: %s", err)
+	}
+	storageClient.ClearFaults()
+	_, err = c.reloadConfig()
+	if err == nil {
+		t.Fatalf("expected reload of truncated config to fail")
+	}
+}
+
+func TestSaveConfigConflict(t *testing.T) {
+	storageClient := &memorystorage.MockMemoryStorage{}
+	c1, err := newContext(storageClient, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	c2, err := newContext(storageClient, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	c2.Hostname = "vpn.example.com"
+	if err := SaveConfig(c2); err != nil {
+		t.Fatalf("save config error: %s", err)
+	}
+	// c1 didn't reload yet, so its save would overwrite the change of c2
+	c1.LocalAuthDisabled = true
+	err = SaveConfig(c1)
+	if !errors.Is(err, ErrConfigConflict) {
+		t.Fatalf("expected conflict error, got: %v", err)
+	}
+	if c1.Hostname != "vpn.example.com" || c1.LocalAuthDisabled {
+		t.Fatalf("expected config to be reloaded after the conflict: hostname %s, local auth disabled: %v", c1.Hostname, c1.LocalAuthDisabled)
+	}
+	c1.LocalAuthDisabled = true
+	if err := SaveConfig(c1); err != nil {
+		t.Fatalf("save config error after reload: %s", err)
+	}
+	if _, err := c2.reloadConfig(); err != nil {
+		t.Fatalf("reload config error: %s", err)
+	}
+	if c2.Hostname != "vpn.example.com" || !c2.LocalAuthDisabled {
+		t.Fatalf("expected both changes to be saved")
+	}
+}
+
+// ownedLocalStorage skips the ownership change, so the tests don't need a vpn user
+type ownedLocalStorage struct {
+	*localstorage.LocalStorage
+}
+
+func (s ownedLocalStorage) EnsureOwnership(filename, login string) error {
+	return nil
+}
+
+func TestSaveConfigCorruptFile(t *testing.T) {
+	local, err := localstorage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("new storage error: %s", err)
+	}
+	storageClient := ownedLocalStorage{local}
+	err = storageClient.EnsurePath(storageClient.ConfigPath(""))
+	if err != nil {
+		t.Fatalf("ensure path error: %s", err)
+	}
+	c, err := newContext(storageClient, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	for _, hostname := range []string{"vpn1.example.com", "vpn2.example.com"} {
+		c.Hostname = hostname
+		err = SaveConfig(c)
+		if err != nil {
+			t.Fatalf("save config error: %s", err)
+		}
+	}
+	err = os.WriteFile(path.Join(local.GetPath(), storageClient.ConfigPath("config.json")), []byte(`{"hostname":`), 0600)
+	if err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+
+	// the config is loaded from the snapshot, and saving overwrites the corrupt file
+	c, err = newContext(storageClient, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	if c.Hostname != "vpn1.example.com" {
+		t.Fatalf("expected config of the snapshot, got hostname: %s", c.Hostname)
+	}
+	c.Hostname = "vpn3.example.com"
+	err = SaveConfig(c)
+	if err != nil {
+		t.Fatalf("save config error: %s", err)
+	}
+	newC, err := GetConfig(storageClient)
+	if err != nil {
+		t.Fatalf("get config error: %s", err)
+	}
+	if newC.Hostname != "vpn3.example.com" {
+		t.Fatalf("config not saved, hostname: %s", newC.Hostname)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/in4it/go-devops-platform/logging"
 	"github.com/in4it/go-devops-platform/rest/login"
 	"github.com/in4it/go-devops-platform/storage"
+	encryptedstorage "github.com/in4it/go-devops-platform/storage/encrypted"
 	"github.com/in4it/go-devops-platform/users"
 )
 
@@ -29,6 +30,9 @@ func newContext(storage storage.Iface, serverType string) (*Context, error) {
 }
 
 func newContextWithParams(storage storage.Iface, serverType string, userStore *users.UserStore, scimInstance scim.Iface, licenseUserCount int, cloudType string, apps map[string]AppClient) (*Context, error) {
+	if err := encryptedstorage.CheckWrapped(storage); err != nil {
+		return &Context{}, err
+	}
 	c, err := GetConfig(storage)
 	if err != nil {
 		return c, fmt.Errorf("getConfig error: %s", err)
@@ -67,12 +71,27 @@ func newContextWithParams(storage storage.Iface, serverType string, userStore *u
 	}()
 
 	c.UserStore = userStore
+	c.UserStore.SetPasswordPolicy(c.PasswordPolicy)
+	if c.PasswordPolicy.MaxAgeDays > 0 {
+		if err := c.UserStore.StartPasswordAging(); err != nil {
+			logging.ErrorLog(fmt.Errorf("could not set the password change dates: %s", err))
+		}
+	}
+	c.UserStore.SetDeletionGracePeriod(c.userDeletionGracePeriod())
+	if err := c.PasswordHashing.Validate(); err != nil {
+		return c, fmt.Errorf("invalid password hashing parameters: %s", err)
+	}
+	c.UserStore.SetPasswordHashParams(c.PasswordHashing)
 
 	c.OIDCRenewal, err = oidcrenewal.NewRenewal(storage, c.TokenRenewalTimeMinutes, c.LogLevel, c.EnableOIDCTokenRenewal, c.OIDCStore, c.OIDCProviders, c.UserStore)
 	if err != nil {
 		return c, fmt.Errorf("oidcrenewal init error: %s", err)
 	}
 
+	if err := c.LockoutPolicy.Validate(); err != nil {
+		return c, fmt.Errorf("invalid lockout policy: %s", err)
+	}
+	c.LoginLockout = login.NewLockout(storage, c.LockoutPolicy)
 	if c.LoginAttempts == nil {
 		c.LoginAttempts = make(login.Attempts)
 	}
@@ -0,0 +1,141 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func (c *Context) groupsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		groups := c.UserStore.Groups().ListGroups()
+		response := make([]GroupResponse, len(groups))
+		for k, group := range groups {
+			response[k] = c.groupResponse(group)
+		}
+		out, err := json.Marshal(response)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("groups marshal error: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	case http.MethodPost:
+		var groupRequest GroupRequest
+		err := json.NewDecoder(r.Body).Decode(&groupRequest)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+			return
+		}
+		group, err := c.UserStore.Groups().AddGroup(users.Group{Name: groupRequest.Name, Description: groupRequest.Description})
+		if err != nil {
+			c.returnError(w, fmt.Errorf("add group error: %s", err), http.StatusBadRequest)
+			return
+		}
+		out, err := json.Marshal(c.groupResponse(group))
+		if err != nil {
+			c.returnError(w, fmt.Errorf("group marshal error: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	default:
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+	}
+}
+
+func (c *Context) groupHandler(w http.ResponseWriter, r *http.Request) {
+	group, err := c.UserStore.Groups().GetGroupByID(r.PathValue("id"))
+	if err != nil {
+		c.returnError(w, err, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var groupRequest GroupRequest
+		err := json.NewDecoder(r.Body).Decode(&groupRequest)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+			return
+		}
+		group.Name = groupRequest.Name
+		group.Description = groupRequest.Description
+		err = c.UserStore.Groups().UpdateGroup(group)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("update group error: %s", err), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		err := c.UserStore.DeleteGroup(group.ID)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("delete group error: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, []byte(`{"deleted": "`+group.ID+`"}`))
+		return
+	default:
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	out, err := json.Marshal(c.groupResponse(group))
+	if err != nil {
+		c.returnError(w, fmt.Errorf("group marshal error: %s", err), http.StatusBadRequest)
+		return
+	}
+	c.write(w, out)
+}
+
+func (c *Context) groupMembersHandler(w http.ResponseWriter, r *http.Request) {
+	group, err := c.UserStore.Groups().GetGroupByID(r.PathValue("id"))
+	if err != nil {
+		c.returnError(w, err, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var memberRequest GroupMemberRequest
+		err := json.NewDecoder(r.Body).Decode(&memberRequest)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+			return
+		}
+		err = c.UserStore.AddUserToGroup(memberRequest.UserID, group.ID)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("add member error: %s", err), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		err := c.UserStore.RemoveUserFromGroup(r.PathValue("userID"), group.ID)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("remove member error: %s", err), http.StatusBadRequest)
+			return
+		}
+	default:
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	members := c.UserStore.ListGroupMembers(group.ID)
+	response := make([]GroupMemberResponse, len(members))
+	for k, member := range members {
+		response[k] = GroupMemberResponse{ID: member.ID, Login: member.Login}
+	}
+	out, err := json.Marshal(response)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("members marshal error: %s", err), http.StatusBadRequest)
+		return
+	}
+	c.write(w, out)
+}
+
+func (c *Context) groupResponse(group users.Group) GroupResponse {
+	return GroupResponse{
+		ID:          group.ID,
+		Name:        group.Name,
+		Description: group.Description,
+		ExternalID:  group.ExternalID,
+		MemberCount: len(c.UserStore.ListGroupMembers(group.ID)),
+	}
+}
@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func TestGroupsHandler(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	c, err := newContext(storage, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	user, err := c.UserStore.AddUser(users.User{Login: "john", Password: "mypass", Role: "user"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+
+	req := httptest.NewRequest("POST", "http://example.com/api/groups", bytes.NewBufferString(`{"name": "developers"}`))
+	w := httptest.NewRecorder()
+	c.groupsHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d", w.Code)
+	}
+	var group GroupResponse
+	if err := json.NewDecoder(w.Body).Decode(&group); err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+
+	req = httptest.NewRequest("POST", "http://example.com/api/groups/"+group.ID+"/members", bytes.NewBufferString(`{"userID": "`+user.ID+`"}`))
+	req.SetPathValue("id", group.ID)
+	w = httptest.NewRecorder()
+	c.groupMembersHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	var members []GroupMemberResponse
+	if err := json.NewDecoder(w.Body).Decode(&members); err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if len(members) != 1 || members[0].Login != "john" {
+		t.Fatalf("unexpected members: %v", members)
+	}
+
+	req = httptest.NewRequest("PUT", "http://example.com/api/groups/"+group.ID, bytes.NewBufferString(`{"name": "engineering"}`))
+	req.SetPathValue("id", group.ID)
+	w = httptest.NewRecorder()
+	c.groupHandler(w, req)
+	if err := json.NewDecoder(w.Body).Decode(&group); err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if group.Name != "engineering" || group.MemberCount != 1 {
+		t.Fatalf("unexpected group: %+v", group)
+	}
+
+	req = httptest.NewRequest("DELETE", "http://example.com/api/groups/"+group.ID, nil)
+	req.SetPathValue("id", group.ID)
+	w = httptest.NewRecorder()
+	c.groupHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d", w.Code)
+	}
+	user, _ = c.UserStore.GetUserByID(user.ID)
+	if len(user.Groups) != 0 {
+		t.Fatalf("expected membership to be removed")
+	}
+}
+
+func TestIsMemberOfGroupMiddleware(t *testing.T) {
+	handler := IsMemberOfGroupMiddleware([]string{"developers"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, test := range []struct {
+		role     string
+		groups   []string
+		expected int
+	}{
+		{role: "user", groups: []string{"developers"}, expected: http.StatusOK},
+		{role: "user", groups: []string{"operations"}, expected: http.StatusForbidden},
+		{role: "user", expected: http.StatusForbidden},
+		{role: "admin", expected: http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "http://example.com/api/app/", nil)
+		ctx := context.WithValue(req.Context(), CustomValue("user"), users.User{Login: "john", Role: test.role})
+		ctx = context.WithValue(ctx, CustomValue("groups"), test.groups)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		if w.Code != test.expected {
+			t.Fatalf("expected %d for role %s and groups %v, got: %d", test.expected, test.role, test.groups, w.Code)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/in4it/go-devops-platform/logging"
+	"github.com/in4it/go-devops-platform/storage"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+const INACTIVITY_POLICY_LOCK = "inactivity-policy"
+
+func (c *Context) inactivityPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		out, err := json.Marshal(c.InactivityPolicy)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("could not marshal inactivity policy: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	case http.MethodPost:
+		var inactivityPolicy users.InactivityPolicy
+		err := json.NewDecoder(r.Body).Decode(&inactivityPolicy)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+			return
+		}
+		err = inactivityPolicy.Validate()
+		if err == nil {
+			err = c.checkInactivityPolicyExemptions(inactivityPolicy)
+		}
+		if err != nil {
+			c.returnError(w, fmt.Errorf("invalid inactivity policy: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.InactivityPolicy = inactivityPolicy
+		err = SaveConfig(c)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("could not save config to disk: %s", err), http.StatusBadRequest)
+			return
+		}
+		out, err := json.Marshal(inactivityPolicy)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("could not marshal inactivity policy: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	default:
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+	}
+}
+
+// checkInactivityPolicyExemptions returns an error when the policy could suspend every user with all permissions, as no role is exempt by default
+func (c *Context) checkInactivityPolicyExemptions(policy users.InactivityPolicy) error {
+	if policy.InactiveDays == 0 {
+		return nil
+	}
+	for _, role := range append(users.BuiltinRoles(), c.Roles...) {
+		if slices.Contains(role.Permissions, users.PermissionAll) && slices.Contains(policy.ExemptRoles, role.Name) {
+			return nil
+		}
+	}
+	for _, login := range policy.ExemptUsers {
+		user, err := c.UserStore.GetUserByLogin(login)
+		if err == nil && !user.Suspended && c.hasPermission(user, users.PermissionAll) {
+			return nil
+		}
+	}
+	return fmt.Errorf("exempt a role with all permissions (e.g. %s) or an active user with such a role, to not suspend every administrator", users.ROLE_ADMIN)
+}
+
+// inactivityReportHandler returns the suspensions and warnings of the inactivity policy, without executing them (dry run)
+func (c *Context) inactivityReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	out, err := json.Marshal(c.UserStore.InactivityReport(c.InactivityPolicy))
+	if err != nil {
+		c.returnError(w, fmt.Errorf("could not marshal inactivity report: %s", err), http.StatusBadRequest)
+		return
+	}
+	c.write(w, out)
+}
+
+// applyInactivityPolicy suspends the inactive and expired users. Warnings are only sent when mails are enabled.
+func (c *Context) applyInactivityPolicy(policy users.InactivityPolicy) ([]users.InactivityAction, error) {
+	var warn func(user users.User, action users.InactivityAction) error
+	if c.MailSender != nil {
+		warn = func(user users.User, action users.InactivityAction) error {
+			return c.sendInactivityWarning(user, action, policy)
+		}
+	}
+	return c.UserStore.ApplyInactivityPolicy(policy, warn)
+}
+
+func (c *Context) sendInactivityWarning(user users.User, action users.InactivityAction, policy users.InactivityPolicy) error {
+	if user.Email == "" {
+		logging.DebugLog(fmt.Errorf("no inactivity warning sent to %s: user has no email address", user.Login))
+		return nil
+	}
+	suspendAt := action.SuspendAt.UTC().Format(time.RFC1123)
+	if action.Reason == users.SUSPENDED_REASON_EXPIRED {
+		return c.MailSender.SendMail(user.Email, "Account expiry", fmt.Sprintf("The account %s expires and will be suspended on %s.\n\nContact an administrator if the account needs to stay active.", user.Login, suspendAt))
+	}
+	return c.MailSender.SendMail(user.Email, "Account suspension", fmt.Sprintf("The account %s will be suspended on %s, because there was no login for %d days.\n\nLogin before that date to keep the account active.", user.Login, suspendAt, policy.InactiveDays))
+}
+
+// inactivityPolicyWorker applies the inactivity policy every hour
+func inactivityPolicyWorker(c *Context) {
+	for {
+		actions, err := c.runInactivityPolicy()
+		if err != nil {
+			logging.ErrorLog(fmt.Errorf("inactivity policy error: %s", err))
+		}
+		for _, action := range actions {
+			logging.DebugLog(fmt.Errorf("inactivity policy: %s %s (%s)", action.Action, action.Login, action.Reason))
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// runInactivityPolicy applies the inactivity policy, unless it could suspend every administrator (like the policy handler refuses).
+// The lock makes instances that share the storage take turns.
+func (c *Context) runInactivityPolicy() ([]users.InactivityAction, error) {
+	configMu.RLock()
+	policy := c.InactivityPolicy
+	exemptionsErr := c.checkInactivityPolicyExemptions(policy)
+	configMu.RUnlock()
+	if exemptionsErr != nil {
+		return nil, fmt.Errorf("policy not applied: %s", exemptionsErr)
+	}
+	var actions []users.InactivityAction
+	err := storage.WithLock(c.Storage.Client, c.Storage.Client.ConfigPath(INACTIVITY_POLICY_LOCK), func() error {
+		var err error
+		actions, err = c.applyInactivityPolicy(policy)
+		return err
+	})
+	return actions, err
+}
@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func TestInactivityPolicy(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	mailSender := &mockMailSender{}
+	c.MailSender = mailSender
+
+	req := httptest.NewRequest("POST", "http://example.com/api/setup/inactivity-policy", bytes.NewBufferString(`{"inactiveDays": -1}`))
+	w := httptest.NewRecorder()
+	c.inactivityPolicyHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid policy, got: %d", w.Code)
+	}
+	req = httptest.NewRequest("POST", "http://example.com/api/setup/inactivity-policy", bytes.NewBufferString(`{"inactiveDays": 90, "warningDays": 14}`))
+	w = httptest.NewRecorder()
+	c.inactivityPolicyHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a policy that can suspend every admin, got: %d", w.Code)
+	}
+	req = httptest.NewRequest("POST", "http://example.com/api/setup/inactivity-policy", bytes.NewBufferString(`{"inactiveDays": 90, "warningDays": 14, "exemptRoles": ["admin"]}`))
+	w = httptest.NewRecorder()
+	c.inactivityPolicyHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+
+	contractor, err := c.UserStore.AddUser(users.User{Login: "contractor", Role: users.ROLE_USER, Profile: users.Profile{Email: "contractor@example.inv"}})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	expiresAt := time.Now().AddDate(0, 0, 7).UTC().Format(time.RFC3339)
+	req = httptest.NewRequest("PATCH", "http://example.com/api/user/"+contractor.ID, bytes.NewBufferString(`{"expiresAt": "`+expiresAt+`"}`))
+	req.SetPathValue("id", contractor.ID)
+	w = httptest.NewRecorder()
+	c.userHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+
+	// dry run
+	req = httptest.NewRequest("GET", "http://example.com/api/users/inactivity-report", nil)
+	w = httptest.NewRecorder()
+	c.inactivityReportHandler(w, req)
+	var report []users.InactivityAction
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if len(report) != 1 || report[0].Login != "contractor" || report[0].Action != users.INACTIVITY_ACTION_WARN || report[0].Reason != users.SUSPENDED_REASON_EXPIRED {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if mailSender.to != "" {
+		t.Fatalf("expected no mail during a dry run")
+	}
+
+	if _, err := c.applyInactivityPolicy(c.InactivityPolicy); err != nil {
+		t.Fatalf("apply inactivity policy error: %s", err)
+	}
+	if mailSender.to != "contractor@example.inv" || !strings.Contains(mailSender.body, "expires") {
+		t.Fatalf("expected a warning mail, got: %s: %s", mailSender.to, mailSender.body)
+	}
+
+	// an empty expiry date removes the expiry
+	req = httptest.NewRequest("PATCH", "http://example.com/api/user/"+contractor.ID, bytes.NewBufferString(`{"expiresAt": ""}`))
+	req.SetPathValue("id", contractor.ID)
+	w = httptest.NewRecorder()
+	c.userHandler(w, req)
+	if user, _ := c.UserStore.GetUserByID(contractor.ID); !user.ExpiresAt.IsZero() {
+		t.Fatalf("expected the expiry date to be removed")
+	}
+}
+
+func TestRunInactivityPolicyWithoutExemptAdmin(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	_, err = c.UserStore.AddUser(users.User{Login: "admin", Role: users.ROLE_ADMIN})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	// e.g. a policy in config.json that was edited by hand
+	c.InactivityPolicy = users.InactivityPolicy{InactiveDays: 90, WarningDays: 14}
+	actions, err := c.runInactivityPolicy()
+	if err == nil || len(actions) != 0 {
+		t.Fatalf("expected the policy not to be applied, got: %v (%+v)", err, actions)
+	}
+	c.InactivityPolicy.ExemptRoles = []string{users.ROLE_ADMIN}
+	if _, err := c.runInactivityPolicy(); err != nil {
+		t.Fatalf("run inactivity policy error: %s", err)
+	}
+}
@@ -0,0 +1,268 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/in4it/go-devops-platform/mfa/totp"
+	"github.com/in4it/go-devops-platform/rest/login"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+const INVITATION_TOKEN_TTL = 7 * 24 * time.Hour
+
+// invitationsHandler lists the pending invitations, or invites a new local user
+func (c *Context) invitationsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tokens, err := c.UserStore.Tokens().List(users.TOKEN_PURPOSE_INVITATION)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("list invitations error: %s", err), http.StatusBadRequest)
+			return
+		}
+		invitedUsers := c.UserStore.ListInvitedUsers()
+		response := make([]InvitationResponse, len(invitedUsers))
+		for k, user := range invitedUsers {
+			response[k] = newInvitationResponse(user, users.Token{})
+			response[k].Expired = true // no valid token left
+			for _, token := range tokens {
+				if token.UserID == user.ID {
+					response[k] = newInvitationResponse(user, token)
+				}
+			}
+		}
+		out, err := json.Marshal(response)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("response marshal error: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	case http.MethodPost:
+		var invitation InvitationRequest
+		err := json.NewDecoder(r.Body).Decode(&invitation)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+			return
+		}
+		if invitation.Login == "" {
+			c.returnError(w, fmt.Errorf("login is empty"), http.StatusBadRequest)
+			return
+		}
+		if !isAlphaNumeric(invitation.Login) {
+			c.returnError(w, fmt.Errorf("login not valid"), http.StatusBadRequest)
+			return
+		}
+		if invitation.Role == "" {
+			invitation.Role = users.ROLE_USER
+		}
+		if err := c.canAssignRole(r, invitation.Role); err != nil {
+			c.returnError(w, err, http.StatusBadRequest)
+			return
+		}
+		if invitation.SendMail && (c.MailSender == nil || invitation.Email == "") {
+			c.returnError(w, fmt.Errorf("can't send mail: no mail sender configured or no email address supplied"), http.StatusBadRequest)
+			return
+		}
+		if c.UserStore.UserCount() >= c.LicenseUserCount {
+			c.returnError(w, fmt.Errorf("no more licenses available"), http.StatusBadRequest)
+			return
+		}
+		newUser, err := c.UserStore.InviteUser(users.User{Login: invitation.Login, Role: invitation.Role, Profile: invitation.Profile})
+		if err != nil {
+			c.returnError(w, fmt.Errorf("invite user error: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.issueInvitation(w, r, newUser, invitation.SendMail)
+	default:
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+	}
+}
+
+// invitationHandler issues a new invitation link (e.g. when the previous one expired), or revokes the invitation.
+// Revoking an invitation removes the invited user.
+func (c *Context) invitationHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := c.UserStore.GetUserByID(r.PathValue("id"))
+	if err != nil || !user.Invited {
+		c.returnError(w, fmt.Errorf("invitation not found"), http.StatusBadRequest)
+		return
+	}
+	if err := c.canChangeUser(r, user); err != nil {
+		c.returnError(w, err, http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		var invitation InvitationRequest
+		if r.ContentLength != 0 {
+			err := json.NewDecoder(r.Body).Decode(&invitation)
+			if err != nil {
+				c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+				return
+			}
+		}
+		if invitation.SendMail && (c.MailSender == nil || user.Email == "") {
+			c.returnError(w, fmt.Errorf("can't send mail: no mail sender configured or user has no email address"), http.StatusBadRequest)
+			return
+		}
+		c.issueInvitation(w, r, user, invitation.SendMail)
+	case http.MethodDelete:
+		err = c.UserStore.Tokens().Revoke(users.TOKEN_PURPOSE_INVITATION, user.ID)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("revoke invitation error: %s", err), http.StatusBadRequest)
+			return
+		}
+		err = c.UserStore.DeleteUserByID(user.ID)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("delete user error: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.audit(user.ID, "invitation-revoked")
+		c.write(w, []byte(`{"deleted": "`+user.ID+`"}`))
+	default:
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+	}
+}
+
+// issueInvitation issues an invitation token for the user and returns or mails the invitation link
+func (c *Context) issueInvitation(w http.ResponseWriter, r *http.Request, user users.User, sendMail bool) {
+	createdBy := ""
+	if admin, ok := r.Context().Value(CustomValue("user")).(users.User); ok {
+		createdBy = admin.ID
+	}
+	token, issuedToken, err := c.UserStore.Tokens().Issue(users.TOKEN_PURPOSE_INVITATION, user.ID, createdBy, INVITATION_TOKEN_TTL)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("issue token error: %s", err), http.StatusBadRequest)
+		return
+	}
+	response := newInvitationResponse(user, issuedToken)
+	if sendMail {
+		err = c.MailSender.SendMail(user.Email, "Invitation", fmt.Sprintf("You have been invited to %s with login %s. Use the following link to set your password (valid until %s):\n\n%s", c.Hostname, user.Login, issuedToken.ExpiresAt.UTC().Format(time.RFC1123), c.invitationLink(token)))
+		if err != nil {
+			c.returnError(w, fmt.Errorf("send mail error: %s", err), http.StatusBadRequest)
+			return
+		}
+		response.Mailed = true
+	} else {
+		response.Token = token
+		response.Link = c.invitationLink(token)
+	}
+	c.audit(user.ID, "invitation-issued")
+	out, err := json.Marshal(response)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("response marshal error: %s", err), http.StatusBadRequest)
+		return
+	}
+	c.write(w, out)
+}
+
+// acceptInvitationHandler is unauthenticated. GET returns the login of the invitation, POST sets the password and optionally enrolls a TOTP factor.
+func (c *Context) acceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	rateLimitKey := login.InvitationKey(clientIP(r))
+	if c.loginLocked(rateLimitKey) {
+		c.returnError(w, fmt.Errorf("too many attempts, try again later"), http.StatusTooManyRequests)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		token, err := c.UserStore.Tokens().Lookup(users.TOKEN_PURPOSE_INVITATION, r.URL.Query().Get("token"))
+		if err != nil {
+			c.recordLoginFailure(rateLimitKey)
+			c.returnError(w, err, http.StatusBadRequest)
+			return
+		}
+		user, err := c.UserStore.GetUserByID(token.UserID)
+		if err != nil || user.Suspended || user.Deleted() {
+			c.returnError(w, users.ErrTokenInvalid, http.StatusBadRequest)
+			return
+		}
+		out, err := json.Marshal(InvitationInfoResponse{Login: user.Login, ExpiresAt: token.ExpiresAt})
+		if err != nil {
+			c.returnError(w, fmt.Errorf("response marshal error: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	case http.MethodPost:
+		var acceptRequest AcceptInvitationRequest
+		err := json.NewDecoder(r.Body).Decode(&acceptRequest)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+			return
+		}
+		if acceptRequest.Password == "" {
+			c.returnError(w, fmt.Errorf("no password supplied"), http.StatusBadRequest)
+			return
+		}
+		factors := []users.Factor{}
+		if acceptRequest.Factor != nil {
+			factor, err := verifyFactor(*acceptRequest.Factor)
+			if err != nil {
+				c.returnError(w, err, http.StatusBadRequest)
+				return
+			}
+			factors = append(factors, factor)
+		}
+		var userID string
+		err = c.UserStore.Tokens().Consume(users.TOKEN_PURPOSE_INVITATION, acceptRequest.Token, func(token users.Token) error {
+			userID = token.UserID
+			user, err := c.UserStore.GetUserByID(token.UserID)
+			if err != nil || user.Suspended || user.Deleted() { // the user could have been suspended or deleted after the invitation
+				return users.ErrTokenInvalid
+			}
+			return c.UserStore.AcceptInvitation(token.UserID, acceptRequest.Password, factors)
+		})
+		if err != nil {
+			var policyErr users.PasswordPolicyError
+			if errors.As(err, &policyErr) {
+				c.returnError(w, err, http.StatusBadRequest)
+				return
+			}
+			c.recordLoginFailure(rateLimitKey)
+			if errors.Is(err, users.ErrTokenInvalid) {
+				c.returnError(w, err, http.StatusBadRequest)
+				return
+			}
+			c.returnError(w, fmt.Errorf("accept invitation error: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.audit(userID, "invitation-accepted")
+		c.write(w, []byte(`{"result": "OK"}`))
+	default:
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+	}
+}
+
+// verifyFactor checks the code of a new TOTP factor
+func verifyFactor(factor FactorRequest) (users.Factor, error) {
+	if factor.Secret == "" || factor.Name == "" || factor.Type == "" || factor.Code == "" {
+		return users.Factor{}, fmt.Errorf("factor name, type, secret and code are required")
+	}
+	if len(factor.Name) > 16 {
+		return users.Factor{}, fmt.Errorf("factor name too long")
+	}
+	ok, err := totp.VerifyMultipleIntervals(factor.Secret, factor.Code, 20)
+	if err != nil {
+		return users.Factor{}, fmt.Errorf("totp verify error: %s", err)
+	}
+	if !ok {
+		return users.Factor{}, fmt.Errorf("code doesn't match. Try entering code again or try with a new QR code")
+	}
+	return users.Factor{Name: factor.Name, Type: factor.Type, Secret: factor.Secret}, nil
+}
+
+func (c *Context) invitationLink(token string) string {
+	return fmt.Sprintf("%s://%s/invitation?token=%s", c.Protocol, c.Hostname, token)
+}
+
+func newInvitationResponse(user users.User, token users.Token) InvitationResponse {
+	return InvitationResponse{
+		UserID:    user.ID,
+		Login:     user.Login,
+		Role:      user.Role,
+		Email:     user.Email,
+		CreatedBy: token.CreatedBy,
+		ExpiresAt: token.ExpiresAt,
+	}
+}
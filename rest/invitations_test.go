@@ -0,0 +1,159 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/in4it/go-devops-platform/mfa/totp"
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func TestInvitation(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	c.LicenseUserCount = 1
+	admin := users.User{ID: "admin-id", Login: "admin", Role: users.ROLE_ADMIN}
+	req := httptest.NewRequest("POST", "http://example.com/api/invitations", bytes.NewBufferString(`{"login": "john", "email": "john@example.inv"}`))
+	w := httptest.NewRecorder()
+	c.invitationsHandler(w, req.WithContext(context.WithValue(req.Context(), CustomValue("user"), admin)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	var invitation InvitationResponse
+	err = json.NewDecoder(w.Body).Decode(&invitation)
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if invitation.Token == "" || invitation.CreatedBy != "admin-id" || invitation.Role != users.ROLE_USER {
+		t.Fatalf("unexpected invitation: %+v", invitation)
+	}
+
+	// invited users count against the license
+	req = httptest.NewRequest("POST", "http://example.com/api/invitations", bytes.NewBufferString(`{"login": "jane"}`))
+	w = httptest.NewRecorder()
+	c.invitationsHandler(w, req.WithContext(context.WithValue(req.Context(), CustomValue("user"), admin)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when no licenses are left, got: %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "http://example.com/api/invitations", nil)
+	w = httptest.NewRecorder()
+	c.invitationsHandler(w, req)
+	var pending []InvitationResponse
+	err = json.NewDecoder(w.Body).Decode(&pending)
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if len(pending) != 1 || pending[0].Login != "john" || pending[0].Expired || pending[0].Token != "" {
+		t.Fatalf("unexpected pending invitations: %+v", pending)
+	}
+
+	req = httptest.NewRequest("GET", "http://example.com/api/auth/invitation?token="+invitation.Token, nil)
+	w = httptest.NewRecorder()
+	c.acceptInvitationHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+
+	// accept with a TOTP factor
+	secret := "JBSWY3DPEHPK3PXP"
+	code, err := totp.GetToken(secret, time.Now().Unix()/totp.INTERVAL)
+	if err != nil {
+		t.Fatalf("totp error: %s", err)
+	}
+	payload := fmt.Sprintf(`{"token": "%s", "password": "mypassword", "factor": {"name": "phone", "type": "totp", "secret": "%s", "code": "%s"}}`, invitation.Token, secret, code)
+	req = httptest.NewRequest("POST", "http://example.com/api/auth/invitation", bytes.NewBufferString(payload))
+	w = httptest.NewRecorder()
+	c.acceptInvitationHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	user, ok := c.UserStore.AuthUser("john", "mypassword")
+	if !ok {
+		t.Fatalf("expected login to work after accepting invitation")
+	}
+	if len(user.Factors) != 1 || user.Factors[0].Name != "phone" {
+		t.Fatalf("expected factor to be enrolled: %+v", user.Factors)
+	}
+	req = httptest.NewRequest("POST", "http://example.com/api/auth/invitation", bytes.NewBufferString(`{"token": "`+invitation.Token+`", "password": "otherpassword"}`))
+	w = httptest.NewRecorder()
+	c.acceptInvitationHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected invitation to be single use, got: %d", w.Code)
+	}
+}
+
+func TestRevokeInvitation(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	user, err := c.UserStore.InviteUser(users.User{Login: "john", Role: users.ROLE_USER})
+	if err != nil {
+		t.Fatalf("invite user error: %s", err)
+	}
+	token, _, err := c.UserStore.Tokens().Issue(users.TOKEN_PURPOSE_INVITATION, user.ID, "", INVITATION_TOKEN_TTL)
+	if err != nil {
+		t.Fatalf("issue error: %s", err)
+	}
+	req := httptest.NewRequest("DELETE", "http://example.com/api/invitations/"+user.ID, nil)
+	req.SetPathValue("id", user.ID)
+	w := httptest.NewRecorder()
+	c.invitationHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	if c.UserStore.LoginExists("john") {
+		t.Fatalf("expected invited user to be removed")
+	}
+	if _, err := c.UserStore.Tokens().Lookup(users.TOKEN_PURPOSE_INVITATION, token); err == nil {
+		t.Fatalf("expected invitation token to be revoked")
+	}
+}
+
+func TestAcceptInvitationSuspended(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	user, err := c.UserStore.InviteUser(users.User{Login: "john", Role: users.ROLE_USER})
+	if err != nil {
+		t.Fatalf("invite user error: %s", err)
+	}
+	token, _, err := c.UserStore.Tokens().Issue(users.TOKEN_PURPOSE_INVITATION, user.ID, "", INVITATION_TOKEN_TTL)
+	if err != nil {
+		t.Fatalf("issue error: %s", err)
+	}
+	user.Suspended = true
+	err = c.UserStore.UpdateUser(user)
+	if err != nil {
+		t.Fatalf("update user error: %s", err)
+	}
+	req := httptest.NewRequest("GET", "http://example.com/api/auth/invitation?token="+token, nil)
+	w := httptest.NewRecorder()
+	c.acceptInvitationHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for suspended user, got: %d", w.Code)
+	}
+	req = httptest.NewRequest("POST", "http://example.com/api/auth/invitation", bytes.NewBufferString(`{"token": "`+token+`", "password": "mypassword"}`))
+	w = httptest.NewRecorder()
+	c.acceptInvitationHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for suspended user, got: %d", w.Code)
+	}
+	if _, ok := c.UserStore.AuthUser("john", "mypassword"); ok {
+		t.Fatalf("suspended user accepted the invitation")
+	}
+	if err := c.UserStore.AcceptInvitation(user.ID, "mypassword", nil); err == nil {
+		t.Fatalf("expected accept invitation to fail for suspended user")
+	}
+}
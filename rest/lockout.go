@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/in4it/go-devops-platform/logging"
+	"github.com/in4it/go-devops-platform/rest/login"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+// loginLocked returns true when one of the keys is locked. Storage errors are logged and don't lock the login.
+func (c *Context) loginLocked(keys ...string) bool {
+	_, locked, err := c.LoginLockout.Locked(keys...)
+	if err != nil {
+		logging.ErrorLog(fmt.Errorf("lockout check error: %s", err))
+	}
+	return locked
+}
+
+func (c *Context) recordLoginFailure(keys ...string) {
+	err := c.LoginLockout.RecordFailure(keys...)
+	if err != nil {
+		logging.ErrorLog(fmt.Errorf("lockout record error: %s", err))
+	}
+}
+
+func (c *Context) clearLoginFailures(key string) {
+	err := c.LoginLockout.Clear(key)
+	if err != nil {
+		logging.ErrorLog(fmt.Errorf("lockout clear error: %s", err))
+	}
+}
+
+// lockoutsHandler lists the locked accounts and ip addresses
+func (c *Context) lockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	counters, err := c.LoginLockout.List()
+	if err != nil {
+		c.returnError(w, fmt.Errorf("list lockouts error: %s", err), http.StatusBadRequest)
+		return
+	}
+	out, err := json.Marshal(counters)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("could not marshal lockouts: %s", err), http.StatusBadRequest)
+		return
+	}
+	c.write(w, out)
+}
+
+// lockoutHandler unlocks an account or ip address. The key is the key returned by the lockoutsHandler (e.g. account:john).
+func (c *Context) lockoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	key := r.PathValue("key")
+	err := c.LoginLockout.Clear(key)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("unlock error: %s", err), http.StatusBadRequest)
+		return
+	}
+	if admin, ok := r.Context().Value(CustomValue("user")).(users.User); ok {
+		c.audit(admin.ID, "unlock "+key)
+	}
+	c.write(w, []byte(`{"deleted": "`+key+`"}`))
+}
+
+func (c *Context) lockoutPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		out, err := json.Marshal(c.LockoutPolicy)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("could not marshal lockout policy: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	case http.MethodPost:
+		var lockoutPolicy login.LockoutPolicy
+		err := json.NewDecoder(r.Body).Decode(&lockoutPolicy)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+			return
+		}
+		err = lockoutPolicy.Validate()
+		if err != nil {
+			c.returnError(w, fmt.Errorf("invalid lockout policy: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.LockoutPolicy = lockoutPolicy
+		c.LoginLockout.SetPolicy(lockoutPolicy)
+		err = SaveConfig(c)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("could not save config to disk: %s", err), http.StatusBadRequest)
+			return
+		}
+		out, err := json.Marshal(lockoutPolicy)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("could not marshal lockout policy: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	default:
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+	}
+}
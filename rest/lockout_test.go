@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/in4it/go-devops-platform/rest/login"
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func TestLoginLockout(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	c, err := newContext(storage, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	_, err = c.UserStore.AddUser(users.User{Login: "john", Password: "password", Role: users.ROLE_USER, Factors: []users.Factor{{Name: "phone", Type: "totp", Secret: "JBSWY3DPEHPK3PXP"}}})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	// wrong MFA codes count as failures
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("POST", "http://example.com/api/auth", bytes.NewBufferString(`{"login": "john", "password": "password", "factorResponse": {"name": "phone", "code": "000000x"}}`))
+		w := httptest.NewRecorder()
+		c.authHandler(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got: %d (%s)", w.Code, w.Body.String())
+		}
+	}
+	req := httptest.NewRequest("POST", "http://example.com/api/auth", bytes.NewBufferString(`{"login": "john", "password": "password"}`))
+	w := httptest.NewRecorder()
+	c.authHandler(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got: %d", w.Code)
+	}
+
+	// the lockout survives a restart
+	c2, err := newContext(storage, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	w = httptest.NewRecorder()
+	c2.lockoutsHandler(w, httptest.NewRequest("GET", "http://example.com/api/lockouts", nil))
+	var counters []login.Counter
+	err = json.NewDecoder(w.Body).Decode(&counters)
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if len(counters) != 1 || counters[0].Key != login.AccountKey("john") {
+		t.Fatalf("unexpected lockouts: %+v", counters)
+	}
+
+	req = httptest.NewRequest("DELETE", "http://example.com/api/lockouts/account:john", nil)
+	req.SetPathValue("key", counters[0].Key)
+	w = httptest.NewRecorder()
+	c2.lockoutHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	req = httptest.NewRequest("POST", "http://example.com/api/auth", bytes.NewBufferString(`{"login": "john", "password": "password"}`))
+	w = httptest.NewRecorder()
+	c.authHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 after unlock, got: %d (%s)", w.Code, w.Body.String())
+	}
+
+	// unknown logins only count for the ip address
+	req = httptest.NewRequest("POST", "http://example.com/api/auth", bytes.NewBufferString(`{"login": "doesnotexist", "password": "password"}`))
+	w = httptest.NewRecorder()
+	c.authHandler(w, req)
+	if w.Code == http.StatusOK {
+		t.Fatalf("expected login of unknown user to fail")
+	}
+	data, err := storage.ReadFile(storage.ConfigPath(login.LOCKOUT_FILENAME))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	if strings.Contains(string(data), login.AccountKey("doesnotexist")) || !strings.Contains(string(data), "ip:") {
+		t.Fatalf("unexpected counters: %s", data)
+	}
+}
@@ -7,18 +7,22 @@ import (
 
 var mu sync.Mutex
 
+// Deprecated: the attempts are only kept in memory. Use Lockout, which keeps the counters in storage.
 type Attempts map[string][]Attempt
 
+// Deprecated: see Attempts.
 type Attempt struct {
 	Timestamp time.Time
 }
 
+// Deprecated: use Lockout.Clear.
 func ClearAttemptsForLogin(attempts Attempts, login string) {
 	mu.Lock()
 	defer mu.Unlock()
 	attempts[login] = []Attempt{}
 }
 
+// Deprecated: use Lockout.RecordFailure.
 func RecordAttempt(attempts Attempts, login string) {
 	mu.Lock()
 	defer mu.Unlock()
@@ -29,6 +33,7 @@ func RecordAttempt(attempts Attempts, login string) {
 	attempts[login] = append(attempts[login], Attempt{Timestamp: time.Now()})
 }
 
+// Deprecated: use Lockout.Locked.
 func CheckTooManyLogins(attempts Attempts, login string) bool {
 	threeMinutes := 3 * time.Minute
 	_, ok := attempts[login]
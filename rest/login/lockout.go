@@ -0,0 +1,247 @@
+package login
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+const LOCKOUT_FILENAME = "lockout.json"
+const MAX_LOCKOUT_COUNTERS = 10000 // limits the size of lockout.json, failures can come from anywhere
+
+// LockoutPolicy configures when logins are locked. Empty values are the defaults.
+type LockoutPolicy struct {
+	MaxAttempts       int `json:"maxAttempts"`       // failures within the window before an account is locked (default 3)
+	MaxAttemptsPerIP  int `json:"maxAttemptsPerIP"`  // failures within the window before an ip address is locked (default 20)
+	MaxRequestsPerIP  int `json:"maxRequestsPerIP"`  // password reset requests and invalid invitation tokens within the window before an ip address is locked (default 10)
+	WindowSeconds     int `json:"windowSeconds"`     // default 180
+	LockoutSeconds    int `json:"lockoutSeconds"`    // duration of the first lockout, doubled for every consecutive lockout (default 180)
+	MaxLockoutSeconds int `json:"maxLockoutSeconds"` // default 86400
+}
+
+func (p LockoutPolicy) withDefaults() LockoutPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = 3
+	}
+	if p.MaxAttemptsPerIP == 0 {
+		p.MaxAttemptsPerIP = 20
+	}
+	if p.MaxRequestsPerIP == 0 {
+		p.MaxRequestsPerIP = 10
+	}
+	if p.WindowSeconds == 0 {
+		p.WindowSeconds = 180
+	}
+	if p.LockoutSeconds == 0 {
+		p.LockoutSeconds = 180
+	}
+	if p.MaxLockoutSeconds == 0 {
+		p.MaxLockoutSeconds = 86400
+	}
+	return p
+}
+
+// maxAttempts returns the failures before the key is locked, depending on the kind of key
+func (p LockoutPolicy) maxAttempts(key string) int {
+	switch {
+	case strings.HasPrefix(key, "ip:"):
+		return p.MaxAttemptsPerIP
+	case strings.HasPrefix(key, "password-reset:"), strings.HasPrefix(key, "invitation:"):
+		return p.MaxRequestsPerIP
+	}
+	return p.MaxAttempts
+}
+
+func (p LockoutPolicy) Validate() error {
+	if p.MaxAttempts < 0 || p.MaxAttemptsPerIP < 0 || p.MaxRequestsPerIP < 0 || p.WindowSeconds < 0 || p.LockoutSeconds < 0 || p.MaxLockoutSeconds < 0 {
+		return fmt.Errorf("values can't be negative")
+	}
+	withDefaults := p.withDefaults()
+	if withDefaults.MaxLockoutSeconds < withDefaults.LockoutSeconds {
+		return fmt.Errorf("max lockout can't be shorter than the lockout")
+	}
+	return nil
+}
+
+// Counter keeps the failures of an account or ip address
+type Counter struct {
+	Key         string      `json:"key"`
+	Failures    []time.Time `json:"failures"`
+	Lockouts    int         `json:"lockouts"` // consecutive lockouts, for the backoff
+	LockedUntil time.Time   `json:"lockedUntil"`
+}
+
+// Lockout counts the login failures per account and per ip address. The counters are kept in storage, so they survive a restart and are shared between instances.
+type Lockout struct {
+	mu      sync.Mutex
+	storage storage.Iface
+	policy  LockoutPolicy
+	now     func() time.Time
+}
+
+func NewLockout(storageClient storage.Iface, policy LockoutPolicy) *Lockout {
+	return &Lockout{storage: storageClient, policy: policy, now: time.Now}
+}
+
+func AccountKey(login string) string {
+	return "account:" + login
+}
+
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// PasswordResetKey counts the password reset requests of an ip address
+func PasswordResetKey(ip string) string {
+	return "password-reset:" + ip
+}
+
+// InvitationKey counts the invalid invitation tokens of an ip address
+func InvitationKey(ip string) string {
+	return "invitation:" + ip
+}
+
+func (l *Lockout) SetPolicy(policy LockoutPolicy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.policy = policy
+}
+
+// Locked returns whether one of the keys is locked, and until when
+func (l *Lockout) Locked(keys ...string) (time.Time, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counters, err := l.read()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	lockedUntil := time.Time{}
+	for _, counter := range counters {
+		if slices.Contains(keys, counter.Key) && counter.LockedUntil.After(l.now()) && counter.LockedUntil.After(lockedUntil) {
+			lockedUntil = counter.LockedUntil
+		}
+	}
+	return lockedUntil, !lockedUntil.IsZero(), nil
+}
+
+// RecordFailure records a failure for every key, and locks the keys that reached the maximum attempts
+func (l *Lockout) RecordFailure(keys ...string) error {
+	return l.modify(func(counters []Counter) []Counter {
+		policy := l.policy.withDefaults()
+		now := l.now()
+		for _, key := range keys {
+			pos := slices.IndexFunc(counters, func(counter Counter) bool { return counter.Key == key })
+			if pos == -1 {
+				counters = append(counters, Counter{Key: key})
+				pos = len(counters) - 1
+			}
+			counter := &counters[pos]
+			counter.Failures = append(counter.Failures, now)
+			if len(counter.Failures) >= policy.maxAttempts(key) {
+				lockout := float64(policy.LockoutSeconds) * math.Pow(2, float64(counter.Lockouts))
+				counter.LockedUntil = now.Add(time.Duration(min(lockout, float64(policy.MaxLockoutSeconds))) * time.Second)
+				counter.Lockouts++
+				counter.Failures = []time.Time{}
+			}
+		}
+		return counters
+	})
+}
+
+// Clear removes the counter, e.g. after a successful login or when an admin unlocks an account
+func (l *Lockout) Clear(key string) error {
+	return l.modify(func(counters []Counter) []Counter {
+		return slices.DeleteFunc(counters, func(counter Counter) bool { return counter.Key == key })
+	})
+}
+
+// List returns the keys that are locked
+func (l *Lockout) List() ([]Counter, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counters, err := l.read()
+	if err != nil {
+		return counters, err
+	}
+	return slices.DeleteFunc(counters, func(counter Counter) bool { return !counter.LockedUntil.After(l.now()) }), nil
+}
+
+// read returns the counters with the failures outside of the window removed.
+// Counters without failures are removed when the lockout and the backoff (max lockout after the last lockout) are over.
+func (l *Lockout) read() ([]Counter, error) {
+	counters := []Counter{}
+	if l.storage.FileExists(l.storage.ConfigPath(LOCKOUT_FILENAME)) {
+		data, err := l.storage.ReadFile(l.storage.ConfigPath(LOCKOUT_FILENAME))
+		if err != nil {
+			return counters, fmt.Errorf("lockout read error: %s", err)
+		}
+		err = json.NewDecoder(bytes.NewBuffer(data)).Decode(&counters)
+		if err != nil {
+			return counters, fmt.Errorf("lockout decode error: %s", err)
+		}
+	}
+	policy := l.policy.withDefaults()
+	now := l.now()
+	window := time.Duration(policy.WindowSeconds) * time.Second
+	for k := range counters {
+		counters[k].Failures = slices.DeleteFunc(counters[k].Failures, func(failure time.Time) bool { return now.Sub(failure) > window })
+	}
+	return slices.DeleteFunc(counters, func(counter Counter) bool {
+		return len(counter.Failures) == 0 && now.After(counter.LockedUntil.Add(time.Duration(policy.MaxLockoutSeconds)*time.Second))
+	}), nil
+}
+
+// limitCounters removes counters when there are more than MAX_LOCKOUT_COUNTERS.
+// Counters that aren't locked are removed first, least recent failure first.
+func (l *Lockout) limitCounters(counters []Counter) []Counter {
+	if len(counters) <= MAX_LOCKOUT_COUNTERS {
+		return counters
+	}
+	now := l.now()
+	lastFailure := func(counter Counter) time.Time {
+		if len(counter.Failures) == 0 {
+			return time.Time{}
+		}
+		return counter.Failures[len(counter.Failures)-1]
+	}
+	slices.SortStableFunc(counters, func(a, b Counter) int {
+		aLocked, bLocked := a.LockedUntil.After(now), b.LockedUntil.After(now)
+		switch {
+		case aLocked && !bLocked:
+			return 1
+		case !aLocked && bLocked:
+			return -1
+		case aLocked:
+			return a.LockedUntil.Compare(b.LockedUntil)
+		}
+		return lastFailure(a).Compare(lastFailure(b))
+	})
+	return counters[len(counters)-MAX_LOCKOUT_COUNTERS:]
+}
+
+func (l *Lockout) modify(fn func(counters []Counter) []Counter) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return storage.WithLock(l.storage, l.storage.ConfigPath(LOCKOUT_FILENAME), func() error {
+		counters, err := l.read()
+		if err != nil {
+			return err
+		}
+		out, err := json.Marshal(l.limitCounters(fn(counters)))
+		if err != nil {
+			return fmt.Errorf("lockout marshal error: %s", err)
+		}
+		err = l.storage.WriteFile(l.storage.ConfigPath(LOCKOUT_FILENAME), out)
+		if err != nil {
+			return fmt.Errorf("lockout write error: %s", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,139 @@
+package login
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestLockoutBackoff(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	now := time.Now()
+	lockout := NewLockout(storage, LockoutPolicy{MaxAttempts: 2, LockoutSeconds: 60, MaxLockoutSeconds: 150})
+	lockout.now = func() time.Time { return now }
+	key := AccountKey("john")
+	for i, expected := range []time.Duration{60 * time.Second, 120 * time.Second, 150 * time.Second} {
+		for j := 0; j < 2; j++ {
+			if err := lockout.RecordFailure(key, IPKey("127.0.0.1")); err != nil {
+				t.Fatalf("record failure error: %s", err)
+			}
+		}
+		lockedUntil, locked, err := lockout.Locked(key)
+		if err != nil {
+			t.Fatalf("locked error: %s", err)
+		}
+		if !locked || !lockedUntil.Equal(now.Add(expected)) {
+			t.Fatalf("lockout %d: expected to be locked for %s, got: %s (locked: %v)", i, expected, lockedUntil.Sub(now), locked)
+		}
+		now = lockedUntil.Add(time.Second)
+		if _, locked, _ := lockout.Locked(key); locked {
+			t.Fatalf("expected lockout to be over")
+		}
+	}
+	// ip addresses have their own threshold
+	if _, locked, _ := lockout.Locked(IPKey("127.0.0.1")); locked {
+		t.Fatalf("expected ip not to be locked")
+	}
+	// the counters are shared between instances
+	lockout.RecordFailure(key)
+	lockout.RecordFailure(key)
+	lockout2 := NewLockout(storage, LockoutPolicy{MaxAttempts: 2})
+	lockout2.now = lockout.now
+	if _, locked, _ := lockout2.Locked(IPKey("10.0.0.1"), key); !locked {
+		t.Fatalf("expected account to be locked in other instance")
+	}
+	counters, err := lockout2.List()
+	if err != nil {
+		t.Fatalf("list error: %s", err)
+	}
+	if len(counters) != 1 || counters[0].Key != key {
+		t.Fatalf("unexpected locked counters: %+v", counters)
+	}
+	if err := lockout2.Clear(key); err != nil {
+		t.Fatalf("clear error: %s", err)
+	}
+	if _, locked, _ := lockout.Locked(key); locked {
+		t.Fatalf("expected account to be unlocked")
+	}
+}
+
+func TestLockoutWindow(t *testing.T) {
+	now := time.Now()
+	lockout := NewLockout(&memorystorage.MockMemoryStorage{}, LockoutPolicy{})
+	lockout.now = func() time.Time { return now }
+	lockout.RecordFailure(AccountKey("john"))
+	lockout.RecordFailure(AccountKey("john"))
+	now = now.Add(181 * time.Second) // the default window is 3 minutes
+	lockout.RecordFailure(AccountKey("john"))
+	if _, locked, _ := lockout.Locked(AccountKey("john")); locked {
+		t.Fatalf("failures outside of the window shouldn't count")
+	}
+	lockout.RecordFailure(AccountKey("john"))
+	lockout.RecordFailure(AccountKey("john"))
+	if _, locked, _ := lockout.Locked(AccountKey("john")); !locked {
+		t.Fatalf("expected account to be locked")
+	}
+}
+
+func TestLockoutPolicyValidate(t *testing.T) {
+	if err := (LockoutPolicy{}).Validate(); err != nil {
+		t.Fatalf("expected empty policy to be valid: %s", err)
+	}
+	if err := (LockoutPolicy{MaxAttempts: -1}).Validate(); err == nil {
+		t.Fatalf("expected error for negative value")
+	}
+	if err := (LockoutPolicy{LockoutSeconds: 3600, MaxLockoutSeconds: 60}).Validate(); err == nil {
+		t.Fatalf("expected error for max lockout shorter than lockout")
+	}
+}
+
+func TestLockoutLimit(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	lockout := NewLockout(storage, LockoutPolicy{MaxAttempts: 1})
+	if err := lockout.RecordFailure(AccountKey("john")); err != nil { // locked
+		t.Fatalf("record failure error: %s", err)
+	}
+	lockout.SetPolicy(LockoutPolicy{MaxAttempts: 3})
+	keys := []string{}
+	for i := 0; i <= MAX_LOCKOUT_COUNTERS; i++ {
+		keys = append(keys, IPKey(fmt.Sprintf("10.0.%d.%d", i/256, i%256)))
+	}
+	if err := lockout.RecordFailure(keys...); err != nil {
+		t.Fatalf("record failure error: %s", err)
+	}
+	lockout.mu.Lock()
+	counters, err := lockout.read()
+	lockout.mu.Unlock()
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	if len(counters) != MAX_LOCKOUT_COUNTERS {
+		t.Fatalf("expected %d counters, got %d", MAX_LOCKOUT_COUNTERS, len(counters))
+	}
+	if _, locked, _ := lockout.Locked(AccountKey("john")); !locked {
+		t.Fatalf("expected locked account to be kept")
+	}
+}
+
+func TestLockoutRequestLimit(t *testing.T) {
+	lockout := NewLockout(&memorystorage.MockMemoryStorage{}, LockoutPolicy{})
+	// password reset requests and invitation tokens have their own threshold
+	for _, key := range []string{PasswordResetKey("127.0.0.1"), InvitationKey("127.0.0.1")} {
+		for range 9 {
+			if err := lockout.RecordFailure(key); err != nil {
+				t.Fatalf("record failure error: %s", err)
+			}
+		}
+		if _, locked, _ := lockout.Locked(key); locked {
+			t.Fatalf("expected %s not to be locked before the default of 10 requests", key)
+		}
+		if err := lockout.RecordFailure(key); err != nil {
+			t.Fatalf("record failure error: %s", err)
+		}
+		if _, locked, _ := lockout.Locked(key); !locked {
+			t.Fatalf("expected %s to be locked", key)
+		}
+	}
+}
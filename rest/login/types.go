@@ -18,10 +18,11 @@ type FactorResponse struct {
 }
 
 type LoginResponse struct {
-	Authenticated bool     `json:"authenticated"`
-	Suspended     bool     `json:"suspended"`
-	NoLicense     bool     `json:"noLicense"`
-	Token         string   `json:"token,omitempty"`
-	MFARequired   bool     `json:"mfaRequired"`
-	Factors       []string `json:"factors"`
+	Authenticated   bool     `json:"authenticated"`
+	Suspended       bool     `json:"suspended"`
+	NoLicense       bool     `json:"noLicense"`
+	Token           string   `json:"token,omitempty"`
+	MFARequired     bool     `json:"mfaRequired"`
+	Factors         []string `json:"factors"`
+	PasswordExpired bool     `json:"passwordExpired,omitempty"` // the password needs to be changed before the api can be used
 }
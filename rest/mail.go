@@ -0,0 +1,6 @@
+package rest
+
+// MailSender sends mails to users, e.g. the password reset link. It's optional: set Context.MailSender to enable mails.
+type MailSender interface {
+	SendMail(to, subject, body string) error
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -113,6 +115,27 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.wroteHeader = true
 }
 
+// configLockMiddleware holds the config read lock while the request is handled, so a reload of config.json doesn't change the config during a request
+func (c *Context) configLockMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		configMu.RLock()
+		var once sync.Once
+		unlock := func() { once.Do(configMu.RUnlock) }
+		defer unlock()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), CustomValue("configUnlock"), unlock)))
+	})
+}
+
+// configUnlockMiddleware releases the config read lock before next is called, for handlers that don't use the config and can run long (e.g. apps)
+func configUnlockMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unlock, ok := r.Context().Value(CustomValue("configUnlock")).(func()); ok {
+			unlock()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (c *Context) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -143,21 +166,31 @@ func (c *Context) injectUserMiddleware(next http.Handler) http.Handler {
 			c.returnError(w, fmt.Errorf("token error: %s", err), http.StatusUnauthorized)
 			return
 		}
+		if c.UserStore.PasswordExpired(user.ID) && !slices.Contains(passwordExpiredAllowedPaths, r.URL.Path) {
+			c.returnError(w, fmt.Errorf("password expired: change your password first"), http.StatusForbidden)
+			return
+		}
+
+		groupNames := []string{}
+		for _, group := range c.UserStore.GetUserGroups(user) {
+			groupNames = append(groupNames, group.Name)
+		}
 
 		ctx := context.WithValue(r.Context(), CustomValue("user"), user)
+		ctx = context.WithValue(ctx, CustomValue("groups"), groupNames)
 		ctx = context.WithValue(ctx, CustomValue("licenseUserCount"), c.LicenseUserCount)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func (c *Context) isAdminMiddleware(next http.Handler) http.Handler {
-	return IsAdminMiddleware(next)
-}
+// endpoints that can be used when the password is expired
+var passwordExpiredAllowedPaths = []string{"/api/profile/password", "/api/userinfo"}
 
+// IsAdminMiddleware only allows users with the admin role. Apps can use this in their router.
 func IsAdminMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := r.Context().Value(CustomValue("user"))
-		if user == nil || user.(users.User).Role != "admin" {
+		if user == nil || user.(users.User).Role != users.ROLE_ADMIN {
 			w.WriteHeader(http.StatusForbidden)
 			w.Write([]byte(`{ "error": "endpoint forbidden" }`))
 			return
@@ -166,6 +199,64 @@ func IsAdminMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// RequirePermission only allows users with a role that has the permission
+func (c *Context) RequirePermission(permission users.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := r.Context().Value(CustomValue("user")).(users.User)
+			if !ok || !c.hasPermission(user, permission) {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{ "error": "endpoint forbidden" }`))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireReadWritePermission requires the read permission for GET requests and the write permission for other requests
+func (c *Context) requireReadWritePermission(read, write users.Permission, next http.Handler) http.Handler {
+	readHandler := c.RequirePermission(read)(next)
+	writeHandler := c.RequirePermission(write)(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			readHandler.ServeHTTP(w, r)
+			return
+		}
+		writeHandler.ServeHTTP(w, r)
+	})
+}
+
+func (c *Context) hasPermission(user users.User, permission users.Permission) bool {
+	role, ok := users.FindRole(c.Roles, user.Role)
+	return ok && role.HasPermission(permission)
+}
+
+// IsMemberOfGroupMiddleware only allows admins and members of one of the groups (by name). Apps can use this in their router.
+func IsMemberOfGroupMiddleware(groupNames []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value(CustomValue("user"))
+		if user == nil {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{ "error": "endpoint forbidden" }`))
+			return
+		}
+		if user.(users.User).Role == users.ROLE_ADMIN {
+			next.ServeHTTP(w, r)
+			return
+		}
+		userGroups, _ := r.Context().Value(CustomValue("groups")).([]string)
+		for _, group := range userGroups {
+			if slices.Contains(groupNames, group) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{ "error": "endpoint forbidden" }`))
+	})
+}
+
 func (c *Context) httpsRedirectMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if c.RedirectToHttps && r.TLS == nil {
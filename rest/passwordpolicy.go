@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func (c *Context) passwordPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		out, err := json.Marshal(c.PasswordPolicy)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("could not marshal password policy: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	case http.MethodPost:
+		var passwordPolicy users.PasswordPolicy
+		err := json.NewDecoder(r.Body).Decode(&passwordPolicy)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+			return
+		}
+		err = passwordPolicy.Validate()
+		if err != nil {
+			c.returnError(w, fmt.Errorf("invalid password policy: %s", err), http.StatusBadRequest)
+			return
+		}
+		if passwordPolicy.MaxAgeDays > 0 {
+			err = c.UserStore.StartPasswordAging()
+			if err != nil {
+				c.returnError(w, fmt.Errorf("could not set the password change dates: %s", err), http.StatusBadRequest)
+				return
+			}
+		}
+		c.PasswordPolicy = passwordPolicy
+		c.UserStore.SetPasswordPolicy(passwordPolicy)
+		err = SaveConfig(c)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("could not save config to disk: %s", err), http.StatusBadRequest)
+			return
+		}
+		out, err := json.Marshal(passwordPolicy)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("could not marshal password policy: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	default:
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,99 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/in4it/go-devops-platform/rest/login"
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func TestPasswordPolicyHandler(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	c, err := newContext(storage, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	req := httptest.NewRequest("POST", "http://example.com/api/setup/password-policy", bytes.NewBufferString(`{"minLength": -1}`))
+	w := httptest.NewRecorder()
+	c.passwordPolicyHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got: %d", w.Code)
+	}
+	req = httptest.NewRequest("POST", "http://example.com/api/setup/password-policy", bytes.NewBufferString(`{"minLength": 12, "requireDigit": true}`))
+	w = httptest.NewRecorder()
+	c.passwordPolicyHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	if _, err := c.UserStore.AddUser(users.User{Login: "john", Password: "short1"}); err == nil {
+		t.Fatalf("expected password policy to be enforced")
+	}
+
+	// policy is saved in the config
+	c2, err := newContext(storage, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	if c2.PasswordPolicy.MinLength != 12 || c2.UserStore.GetPasswordPolicy().MinLength != 12 {
+		t.Fatalf("password policy not saved: %+v", c2.PasswordPolicy)
+	}
+}
+
+func TestLoginWithExpiredPassword(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	hashedPassword, err := users.HashPassword("password")
+	if err != nil {
+		t.Fatalf("hash error: %s", err)
+	}
+	userStoreBytes, err := json.Marshal([]users.User{
+		{ID: "1", Login: "john", Password: hashedPassword, Role: users.ROLE_USER, PasswordChangedAt: users.TimeOrEmpty(time.Now().AddDate(0, 0, -100))},
+		{ID: "2", Login: "jane", Password: hashedPassword, Role: users.ROLE_USER}, // created before the password policy existed
+	})
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+	err = storage.WriteFile(storage.ConfigPath(users.USERSTORE_FILENAME), userStoreBytes)
+	if err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	c, err := newContext(storage, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	c.UserStore.SetPasswordPolicy(users.PasswordPolicy{MaxAgeDays: 90})
+	req := httptest.NewRequest("POST", "http://example.com/api/auth", bytes.NewBufferString(`{"login": "john", "password": "password"}`))
+	w := httptest.NewRecorder()
+	c.authHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	var loginResponse login.LoginResponse
+	err = json.NewDecoder(w.Body).Decode(&loginResponse)
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if !loginResponse.Authenticated || !loginResponse.PasswordExpired {
+		t.Fatalf("expected authenticated with expired password: %+v", loginResponse)
+	}
+
+	// passwords without change date only start to age when the policy is enabled
+	if c.UserStore.PasswordExpired("2") {
+		t.Fatalf("expected password without change date not to be expired")
+	}
+	req = httptest.NewRequest("POST", "http://example.com/api/setup/password-policy", bytes.NewBufferString(`{"maxAgeDays": 90}`))
+	w = httptest.NewRecorder()
+	c.passwordPolicyHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	jane, err := c.UserStore.GetUserByID("2")
+	if err != nil || jane.PasswordChangedAt.IsZero() || c.UserStore.PasswordExpired("2") {
+		t.Fatalf("expected password change date to be set, without expiring the password: %+v (%v)", jane, err)
+	}
+}
@@ -0,0 +1,200 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/in4it/go-devops-platform/logging"
+	"github.com/in4it/go-devops-platform/rest/auditlog"
+	"github.com/in4it/go-devops-platform/rest/login"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+const PASSWORD_RESET_TOKEN_TTL = 1 * time.Hour
+const PASSWORD_RESET_MAX_TOKENS = 3 // outstanding tokens per user when a reset is requested with the login
+
+// passwordResetHandler is unauthenticated. With a login, a reset link is mailed to the user (when a mail sender is configured).
+// With a token and a password, the password is reset.
+func (c *Context) passwordResetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	rateLimitKey := login.PasswordResetKey(clientIP(r))
+	if c.loginLocked(rateLimitKey) {
+		c.returnError(w, fmt.Errorf("too many password reset attempts, try again later"), http.StatusTooManyRequests)
+		return
+	}
+	var resetRequest PasswordResetRequest
+	err := json.NewDecoder(r.Body).Decode(&resetRequest)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+		return
+	}
+	if resetRequest.Token == "" {
+		c.recordLoginFailure(rateLimitKey)
+		if resetRequest.Login == "" {
+			c.returnError(w, fmt.Errorf("no login supplied"), http.StatusBadRequest)
+			return
+		}
+		// the mail is sent in the background: same response, and response time, when the user doesn't exist, to not leak which logins exist
+		go func(userLogin string) {
+			configMu.RLock()
+			defer configMu.RUnlock()
+			err := c.mailPasswordReset(userLogin)
+			if err != nil {
+				logging.ErrorLog(fmt.Errorf("password reset request for %s: %s", userLogin, err))
+			}
+		}(resetRequest.Login)
+		c.write(w, []byte(`{"result": "OK"}`))
+		return
+	}
+	if resetRequest.Password == "" {
+		c.returnError(w, fmt.Errorf("no password supplied"), http.StatusBadRequest)
+		return
+	}
+	var resetUser users.User
+	err = c.UserStore.Tokens().Consume(users.TOKEN_PURPOSE_PASSWORD_RESET, resetRequest.Token, func(token users.Token) error {
+		resetUser, err = c.UserStore.GetUserByID(token.UserID)
+		if err != nil || !canResetPassword(resetUser) { // the user could have been suspended or deleted after the token was issued
+			return users.ErrTokenInvalid
+		}
+		return c.UserStore.UpdatePassword(token.UserID, resetRequest.Password)
+	})
+	if err != nil {
+		var policyErr users.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			c.returnError(w, err, http.StatusBadRequest)
+			return
+		}
+		c.recordLoginFailure(rateLimitKey)
+		if errors.Is(err, users.ErrTokenInvalid) {
+			c.returnError(w, err, http.StatusBadRequest)
+			return
+		}
+		c.returnError(w, fmt.Errorf("password reset error: %s", err), http.StatusBadRequest)
+		return
+	}
+	err = c.UserStore.Tokens().Revoke(users.TOKEN_PURPOSE_PASSWORD_RESET, resetUser.ID) // other requested tokens
+	if err != nil {
+		logging.ErrorLog(fmt.Errorf("revoke password reset tokens error: %s", err))
+	}
+	c.clearLoginFailures(login.AccountKey(resetUser.Login))
+	c.audit(resetUser.ID, "password-reset")
+	c.write(w, []byte(`{"result": "OK"}`))
+}
+
+// mailPasswordReset mails a reset link to local users with an email address
+func (c *Context) mailPasswordReset(userLogin string) error {
+	if c.MailSender == nil {
+		return fmt.Errorf("no mail sender configured")
+	}
+	user, err := c.UserStore.GetUserByLogin(userLogin)
+	if err != nil {
+		return err
+	}
+	if !canResetPassword(user) || user.Email == "" {
+		return fmt.Errorf("user can't reset password or has no email address")
+	}
+	// don't revoke the previous tokens: anyone can request a reset for the login
+	token, _, err := c.UserStore.Tokens().IssueWithLimit(users.TOKEN_PURPOSE_PASSWORD_RESET, user.ID, user.ID, PASSWORD_RESET_TOKEN_TTL, PASSWORD_RESET_MAX_TOKENS)
+	if err != nil {
+		return fmt.Errorf("issue token error: %s", err)
+	}
+	err = c.MailSender.SendMail(user.Email, "Password reset", fmt.Sprintf("A password reset was requested for %s. Use the following link to set a new password (valid for %s):\n\n%s\n\nIgnore this mail if you didn't request a password reset.", user.Login, PASSWORD_RESET_TOKEN_TTL, c.passwordResetLink(token)))
+	if err != nil {
+		return fmt.Errorf("send mail error: %s", err)
+	}
+	c.audit(user.ID, "password-reset-mailed")
+	return nil
+}
+
+// userPasswordResetHandler lets an admin issue a reset token for a user. The token is returned, or mailed to the user.
+func (c *Context) userPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	var resetRequest UserPasswordResetRequest
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&resetRequest)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+	user, err := c.UserStore.GetUserByID(r.PathValue("id"))
+	if err != nil {
+		c.returnError(w, fmt.Errorf("user not found: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := c.canChangeUser(r, user); err != nil {
+		c.returnError(w, err, http.StatusForbidden)
+		return
+	}
+	if !canResetPassword(user) {
+		c.returnError(w, fmt.Errorf("password of this user can't be reset"), http.StatusBadRequest)
+		return
+	}
+	if resetRequest.SendMail && (c.MailSender == nil || user.Email == "") {
+		c.returnError(w, fmt.Errorf("can't send mail: no mail sender configured or user has no email address"), http.StatusBadRequest)
+		return
+	}
+	createdBy := ""
+	if admin, ok := r.Context().Value(CustomValue("user")).(users.User); ok {
+		createdBy = admin.ID
+	}
+	token, issuedToken, err := c.UserStore.Tokens().Issue(users.TOKEN_PURPOSE_PASSWORD_RESET, user.ID, createdBy, PASSWORD_RESET_TOKEN_TTL)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("issue token error: %s", err), http.StatusBadRequest)
+		return
+	}
+	response := UserPasswordResetResponse{ExpiresAt: issuedToken.ExpiresAt}
+	if resetRequest.SendMail {
+		err = c.MailSender.SendMail(user.Email, "Password reset", fmt.Sprintf("An administrator created a password reset for %s. Use the following link to set a new password (valid for %s):\n\n%s", user.Login, PASSWORD_RESET_TOKEN_TTL, c.passwordResetLink(token)))
+		if err != nil {
+			c.returnError(w, fmt.Errorf("send mail error: %s", err), http.StatusBadRequest)
+			return
+		}
+		response.Mailed = true
+	} else {
+		response.Token = token
+		response.Link = c.passwordResetLink(token)
+	}
+	c.audit(user.ID, "password-reset-token-issued")
+	out, err := json.Marshal(response)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("response marshal error: %s", err), http.StatusBadRequest)
+		return
+	}
+	c.write(w, out)
+}
+
+func (c *Context) passwordResetLink(token string) string {
+	return fmt.Sprintf("%s://%s/reset-password?token=%s", c.Protocol, c.Hostname, token)
+}
+
+// canResetPassword returns false for users that authenticate with an identity provider, didn't accept their invitation yet, or are suspended or deleted
+func canResetPassword(user users.User) bool {
+	return user.OIDCID == "" && user.SAMLID == "" && !user.Invited && !user.Suspended && !user.Deleted()
+}
+
+// audit writes an entry in the audit log. Errors are logged, as they shouldn't fail the request.
+func (c *Context) audit(userID, action string) {
+	err := auditlog.Write(c.Storage.Client, auditlog.LogEntry{Timestamp: auditlog.LogTimestamp(time.Now()), UserID: userID, Action: action})
+	if err != nil {
+		logging.ErrorLog(fmt.Errorf("audit log error: %s", err))
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,195 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/in4it/go-devops-platform/rest/login"
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+type mockMailSender struct {
+	mu   sync.Mutex
+	to   string
+	body string
+}
+
+func (m *mockMailSender) SendMail(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.to = to
+	m.body = body
+	return nil
+}
+
+// waitForMail returns the last mail, and waits for it when it's sent in the background
+func (m *mockMailSender) waitForMail(t *testing.T) (string, string) {
+	for range 100 {
+		m.mu.Lock()
+		to, body := m.to, m.body
+		m.mu.Unlock()
+		if to != "" {
+			return to, body
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no mail sent")
+	return "", ""
+}
+
+func resetPassword(c *Context, payload string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "http://example.com/api/auth/reset", bytes.NewBufferString(payload))
+	w := httptest.NewRecorder()
+	c.passwordResetHandler(w, req)
+	return w
+}
+
+func TestPasswordResetByAdmin(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	c, err := newContext(storage, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	user, err := c.UserStore.AddUser(users.User{Login: "john", Password: "old-password", Role: users.ROLE_USER})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	req := httptest.NewRequest("POST", "http://example.com/api/user/"+user.ID+"/password-reset", nil)
+	req.SetPathValue("id", user.ID)
+	w := httptest.NewRecorder()
+	c.userPasswordResetHandler(w, req.WithContext(context.WithValue(req.Context(), CustomValue("user"), users.User{ID: "admin-id", Role: users.ROLE_ADMIN})))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	var response UserPasswordResetResponse
+	err = json.NewDecoder(w.Body).Decode(&response)
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if response.Token == "" {
+		t.Fatalf("expected token in response")
+	}
+
+	w = resetPassword(c, `{"token": "wrong", "password": "new-password"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for wrong token, got: %d", w.Code)
+	}
+	w = resetPassword(c, `{"token": "`+response.Token+`", "password": "new-password"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	if _, ok := c.UserStore.AuthUser("john", "new-password"); !ok {
+		t.Fatalf("expected new password to work")
+	}
+	w = resetPassword(c, `{"token": "`+response.Token+`", "password": "other-password"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected token to be single use, got: %d", w.Code)
+	}
+
+	// audited
+	files, err := storage.ReadDir("stats")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("expected audit log: %v", err)
+	}
+	auditLog, err := storage.ReadFile("stats/" + files[0])
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	if !strings.Contains(string(auditLog), `"action":"password-reset-token-issued"`) || !strings.Contains(string(auditLog), `"action":"password-reset"`) {
+		t.Fatalf("missing audit log entries: %s", auditLog)
+	}
+}
+
+func TestPasswordResetPermissions(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	c.Roles = append(c.Roles, users.Role{Name: "helpdesk", Permissions: []users.Permission{users.PermissionUsersRead, users.PermissionUsersWrite}})
+	admin, err := c.UserStore.AddUser(users.User{Login: "admin2", Password: "old-password", Role: users.ROLE_ADMIN})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	suspended, err := c.UserStore.AddUser(users.User{Login: "john", Password: "old-password", Role: users.ROLE_USER, Suspended: true})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	for _, test := range []struct {
+		user     users.User
+		expected int
+	}{
+		{user: admin, expected: http.StatusForbidden},
+		{user: suspended, expected: http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest("POST", "http://example.com/api/user/"+test.user.ID+"/password-reset", nil)
+		req.SetPathValue("id", test.user.ID)
+		w := httptest.NewRecorder()
+		c.userPasswordResetHandler(w, req.WithContext(context.WithValue(req.Context(), CustomValue("user"), users.User{ID: "helpdesk-id", Role: "helpdesk"})))
+		if w.Code != test.expected {
+			t.Fatalf("expected %d for %s, got: %d (%s)", test.expected, test.user.Login, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestPasswordResetByMail(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	mailSender := &mockMailSender{}
+	c.MailSender = mailSender
+	c.LoginLockout.SetPolicy(login.LockoutPolicy{MaxRequestsPerIP: 3})
+	c.UserStore.SetPasswordPolicy(users.PasswordPolicy{MinLength: 10})
+	john, err := c.UserStore.AddUser(users.User{Login: "john", Password: "old-password", Profile: users.Profile{Email: "john@example.inv"}})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	w := resetPassword(c, `{"login": "doesnotexist"}`)
+	if w.Code != http.StatusOK || mailSender.to != "" {
+		t.Fatalf("expected OK without mail for unknown user, got: %d (to: %s)", w.Code, mailSender.to)
+	}
+	w = resetPassword(c, `{"login": "john"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected OK, got: %d", w.Code)
+	}
+	to, body := mailSender.waitForMail(t)
+	if to != "john@example.inv" {
+		t.Fatalf("expected reset mail, got mail to: %s", to)
+	}
+	token := regexp.MustCompile(`token=([A-Za-z0-9_-]+)`).FindStringSubmatch(body)
+	if len(token) != 2 {
+		t.Fatalf("no token in mail: %s", body)
+	}
+	// another request for the login doesn't revoke the mailed token
+	other, _, err := c.UserStore.Tokens().IssueWithLimit(users.TOKEN_PURPOSE_PASSWORD_RESET, john.ID, john.ID, PASSWORD_RESET_TOKEN_TTL, PASSWORD_RESET_MAX_TOKENS)
+	if err != nil {
+		t.Fatalf("issue error: %s", err)
+	}
+	w = resetPassword(c, `{"token": "`+token[1]+`", "password": "short"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected password policy error, got: %d", w.Code)
+	}
+	w = resetPassword(c, `{"token": "`+token[1]+`", "password": "long-enough-password"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected token to still be valid after policy error, got: %d (%s)", w.Code, w.Body.String())
+	}
+	if _, err := c.UserStore.Tokens().Lookup(users.TOKEN_PURPOSE_PASSWORD_RESET, other); err == nil {
+		t.Fatalf("expected other tokens to be revoked after the reset")
+	}
+
+	// rate limited: 2 requests were made, the third is the last one allowed
+	resetPassword(c, `{"login": "john"}`)
+	w = resetPassword(c, `{"login": "john"}`)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected rate limit, got: %d", w.Code)
+	}
+}
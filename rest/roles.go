@@ -0,0 +1,178 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func (c *Context) rolesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		out, err := json.Marshal(RolesResponse{Roles: append(users.BuiltinRoles(), c.Roles...), Permissions: users.Permissions()})
+		if err != nil {
+			c.returnError(w, fmt.Errorf("roles marshal error: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	case http.MethodPost:
+		var role users.Role
+		err := json.NewDecoder(r.Body).Decode(&role)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+			return
+		}
+		role.Builtin = false
+		err = users.ValidateRole(role)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("invalid role: %s", err), http.StatusBadRequest)
+			return
+		}
+		if _, exists := users.FindRole(c.Roles, role.Name); exists {
+			c.returnError(w, fmt.Errorf("role %s already exists", role.Name), http.StatusBadRequest)
+			return
+		}
+		if err := c.canChangeRole(r, role); err != nil {
+			c.returnError(w, err, http.StatusForbidden)
+			return
+		}
+		c.Roles = append(c.Roles, role)
+		err = SaveConfig(c)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("saveConfig error: %s", err), http.StatusBadRequest)
+			return
+		}
+		out, err := json.Marshal(role)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("role marshal error: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	default:
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+	}
+}
+
+func (c *Context) roleHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	match := slices.IndexFunc(c.Roles, func(role users.Role) bool { return role.Name == name })
+	if match == -1 {
+		c.returnError(w, fmt.Errorf("role not found (built-in roles can't be changed)"), http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		var role users.Role
+		err := json.NewDecoder(r.Body).Decode(&role)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+			return
+		}
+		role.Name = name // renaming would orphan the users with this role
+		role.Builtin = false
+		err = users.ValidateRole(role)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("invalid role: %s", err), http.StatusBadRequest)
+			return
+		}
+		for _, changedRole := range []users.Role{c.Roles[match], role} { // the current permissions can't be removed either
+			if err := c.canChangeRole(r, changedRole); err != nil {
+				c.returnError(w, err, http.StatusForbidden)
+				return
+			}
+		}
+		c.Roles[match] = role
+		err = SaveConfig(c)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("saveConfig error: %s", err), http.StatusBadRequest)
+			return
+		}
+		out, err := json.Marshal(role)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("role marshal error: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, out)
+	case http.MethodDelete:
+		for _, user := range c.UserStore.ListUsers() {
+			if user.Role == name {
+				c.returnError(w, fmt.Errorf("role is still assigned to user %s", user.Login), http.StatusBadRequest)
+				return
+			}
+		}
+		c.Roles = append(c.Roles[:match], c.Roles[match+1:]...)
+		err := SaveConfig(c)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("saveConfig error: %s", err), http.StatusBadRequest)
+			return
+		}
+		c.write(w, []byte(`{"deleted": "`+name+`"}`))
+	default:
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+	}
+}
+
+// missingPermission returns a permission of the role that the user in the request doesn't have.
+// Without user in the request context (e.g. during setup), nothing is missing.
+func (c *Context) missingPermission(r *http.Request, role users.Role) (users.Permission, bool) {
+	user, ok := r.Context().Value(CustomValue("user")).(users.User)
+	if !ok {
+		return "", false
+	}
+	for _, permission := range role.Permissions {
+		if !c.hasPermission(user, permission) {
+			return permission, true
+		}
+	}
+	return "", false
+}
+
+// canAssignRole returns an error when the role doesn't exist, or when it has permissions that the user assigning it doesn't have
+func (c *Context) canAssignRole(r *http.Request, roleName string) error {
+	role, ok := users.FindRole(c.Roles, roleName)
+	if !ok {
+		return fmt.Errorf("invalid role")
+	}
+	if permission, missing := c.missingPermission(r, role); missing {
+		return fmt.Errorf("not allowed to assign role %s (missing permission %s)", role.Name, permission)
+	}
+	return nil
+}
+
+// canChangeUser returns an error when the target user has a role with permissions that the user in the request doesn't have,
+// so a user can't delete, suspend, demote or take over a user with more permissions. Call it before changing another user.
+func (c *Context) canChangeUser(r *http.Request, target users.User) error {
+	role, ok := users.FindRole(c.Roles, target.Role)
+	if !ok {
+		return nil // a role that doesn't exist has no permissions
+	}
+	if permission, missing := c.missingPermission(r, role); missing {
+		return fmt.Errorf("not allowed to change user %s with role %s (missing permission %s)", target.Login, role.Name, permission)
+	}
+	return nil
+}
+
+// canChangeRole returns an error when the user in the request has the role, or doesn't have all the permissions of the role
+func (c *Context) canChangeRole(r *http.Request, role users.Role) error {
+	if user, ok := r.Context().Value(CustomValue("user")).(users.User); ok && user.Role == role.Name {
+		return fmt.Errorf("not allowed to change your own role")
+	}
+	if permission, missing := c.missingPermission(r, role); missing {
+		return fmt.Errorf("not allowed to grant permission %s", permission)
+	}
+	return nil
+}
+
+// permissions returns the permissions of the role of the user. The wildcard is expanded.
+func (c *Context) permissions(user users.User) []users.Permission {
+	permissions := []users.Permission{}
+	for _, permission := range users.Permissions() {
+		if c.hasPermission(user, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
@@ -0,0 +1,164 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func TestRequirePermission(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	c, err := newContext(storage, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	admin := users.User{Login: "admin", Role: users.ROLE_ADMIN}
+	req := httptest.NewRequest("POST", "http://example.com/api/roles", bytes.NewBufferString(`{"name": "helpdesk", "permissions": ["users:read", "users:write"]}`))
+	w := httptest.NewRecorder()
+	c.rolesHandler(w, req.WithContext(context.WithValue(req.Context(), CustomValue("user"), admin)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	req = httptest.NewRequest("POST", "http://example.com/api/roles", bytes.NewBufferString(`{"name": "other", "permissions": ["doesnotexist"]}`))
+	w = httptest.NewRecorder()
+	c.rolesHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown permission, got: %d", w.Code)
+	}
+
+	// custom roles are saved in the config
+	c2, err := newContext(storage, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	if _, ok := users.FindRole(c2.Roles, "helpdesk"); !ok {
+		t.Fatalf("custom role not saved")
+	}
+
+	handler := c.requireReadWritePermission(users.PermissionUsersRead, users.PermissionUsersWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backupHandler := c.RequirePermission(users.PermissionAll)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})) // backup and restore are admin only
+	c.Roles = append(c.Roles, users.Role{Name: "everything", Permissions: users.Permissions()})
+	for _, test := range []struct {
+		role     string
+		method   string
+		handler  http.Handler
+		expected int
+	}{
+		{role: users.ROLE_ADMIN, method: "POST", handler: backupHandler, expected: http.StatusOK},
+		{role: users.ROLE_USER, method: "GET", handler: handler, expected: http.StatusForbidden},
+		{role: "helpdesk", method: "GET", handler: handler, expected: http.StatusOK},
+		{role: "helpdesk", method: "POST", handler: handler, expected: http.StatusOK},
+		{role: "helpdesk", method: "POST", handler: backupHandler, expected: http.StatusForbidden},
+		{role: "everything", method: "POST", handler: backupHandler, expected: http.StatusForbidden},
+		{role: "doesnotexist", method: "GET", handler: handler, expected: http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(test.method, "http://example.com/api/users", nil)
+		ctx := context.WithValue(req.Context(), CustomValue("user"), users.User{Login: "john", Role: test.role})
+		w := httptest.NewRecorder()
+		test.handler.ServeHTTP(w, req.WithContext(ctx))
+		if w.Code != test.expected {
+			t.Fatalf("expected %d for role %s (%s), got: %d", test.expected, test.role, test.method, w.Code)
+		}
+	}
+
+	// a helpdesk user can't make someone admin
+	req = httptest.NewRequest("POST", "http://example.com/api/users", bytes.NewBufferString(`{"login": "jane", "password": "mypass", "role": "admin"}`))
+	w = httptest.NewRecorder()
+	c.usersHandler(w, req.WithContext(context.WithValue(req.Context(), CustomValue("user"), users.User{Login: "john", Role: "helpdesk"})))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when assigning admin role, got: %d", w.Code)
+	}
+
+	// roles in use can't be deleted
+	_, err = c.UserStore.AddUser(users.User{Login: "john", Role: "helpdesk"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	req = httptest.NewRequest("DELETE", "http://example.com/api/roles/helpdesk", nil)
+	req.SetPathValue("name", "helpdesk")
+	w = httptest.NewRecorder()
+	c.roleHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when deleting a role in use, got: %d", w.Code)
+	}
+}
+
+func TestRoleEscalation(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	c.Roles = []users.Role{
+		{Name: "rolemanager", Permissions: []users.Permission{users.PermissionRolesManage, users.PermissionUsersRead}},
+		{Name: "viewer", Permissions: []users.Permission{users.PermissionUsersRead}},
+		{Name: "helpdesk", Permissions: []users.Permission{users.PermissionUsersRead, users.PermissionUsersWrite}},
+	}
+	roleManager := users.User{Login: "jane", Role: "rolemanager"}
+	for _, test := range []struct {
+		method   string
+		name     string
+		body     string
+		expected int
+	}{
+		{method: "POST", body: `{"name": "superuser", "permissions": ["*"]}`, expected: http.StatusForbidden},
+		{method: "POST", body: `{"name": "writer", "permissions": ["users:write"]}`, expected: http.StatusForbidden},
+		{method: "PUT", name: "rolemanager", body: `{"permissions": ["roles:manage", "users:read", "users:write"]}`, expected: http.StatusForbidden},
+		{method: "PUT", name: "viewer", body: `{"permissions": ["*"]}`, expected: http.StatusForbidden},
+		{method: "PUT", name: "helpdesk", body: `{"permissions": ["users:read"]}`, expected: http.StatusForbidden},
+		{method: "PUT", name: "viewer", body: `{"description": "read only", "permissions": ["users:read"]}`, expected: http.StatusOK},
+		{method: "POST", body: `{"name": "reader", "permissions": ["users:read"]}`, expected: http.StatusOK},
+	} {
+		req := httptest.NewRequest(test.method, "http://example.com/api/roles/"+test.name, bytes.NewBufferString(test.body))
+		req.SetPathValue("name", test.name)
+		req = req.WithContext(context.WithValue(req.Context(), CustomValue("user"), roleManager))
+		w := httptest.NewRecorder()
+		if test.method == "POST" {
+			c.rolesHandler(w, req)
+		} else {
+			c.roleHandler(w, req)
+		}
+		if w.Code != test.expected {
+			t.Fatalf("expected %d for %s %s %s, got: %d (%s)", test.expected, test.method, test.name, test.body, w.Code, w.Body.String())
+		}
+	}
+
+	// a helpdesk user can't change an admin
+	admin, err := c.UserStore.AddUser(users.User{Login: "admin2", Password: "mypass", Role: users.ROLE_ADMIN})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	john, err := c.UserStore.AddUser(users.User{Login: "john", Role: users.ROLE_USER})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	helpdesk := users.User{Login: "helpdesk", Role: "helpdesk"}
+	for _, test := range []struct {
+		method   string
+		user     users.User
+		body     string
+		expected int
+	}{
+		{method: "DELETE", user: admin, expected: http.StatusForbidden},
+		{method: "PATCH", user: admin, body: `{"role": "user"}`, expected: http.StatusForbidden},
+		{method: "PATCH", user: admin, body: `{"role": "admin", "suspended": true}`, expected: http.StatusForbidden},
+		{method: "PATCH", user: admin, body: `{"role": "admin", "password": "takeover"}`, expected: http.StatusForbidden},
+		{method: "PATCH", user: john, body: `{"role": "user", "suspended": true}`, expected: http.StatusOK},
+		{method: "DELETE", user: john, expected: http.StatusOK},
+	} {
+		req := httptest.NewRequest(test.method, "http://example.com/api/user/"+test.user.ID, bytes.NewBufferString(test.body))
+		req.SetPathValue("id", test.user.ID)
+		w := httptest.NewRecorder()
+		c.userHandler(w, req.WithContext(context.WithValue(req.Context(), CustomValue("user"), helpdesk)))
+		if w.Code != test.expected {
+			t.Fatalf("expected %d for %s %s %s, got: %d (%s)", test.expected, test.method, test.user.Login, test.body, w.Code, w.Body.String())
+		}
+	}
+	if user, _ := c.UserStore.GetUserByID(admin.ID); user.Role != users.ROLE_ADMIN || user.Suspended {
+		t.Fatalf("admin was changed: %+v", user)
+	}
+}
@@ -3,6 +3,8 @@ package rest
 import (
 	"io/fs"
 	"net/http"
+
+	"github.com/in4it/go-devops-platform/users"
 )
 
 func (c *Context) getRouter(assets fs.FS, indexHtml []byte) *http.ServeMux {
@@ -20,6 +22,8 @@ func (c *Context) getRouter(assets fs.FS, indexHtml []byte) *http.ServeMux {
 	// endpoints with no authentication
 	mux.Handle("/api/context", http.HandlerFunc(c.contextHandler))
 	mux.Handle("/api/auth", http.HandlerFunc(c.authHandler))
+	mux.Handle("/api/auth/reset", http.HandlerFunc(c.passwordResetHandler))
+	mux.Handle("/api/auth/invitation", http.HandlerFunc(c.acceptInvitationHandler))
 	mux.Handle("/api/authmethods", http.HandlerFunc(c.authMethods))
 	mux.Handle("/api/authmethods/{method}/{id}/redirect", http.HandlerFunc(c.authMethodsByIDRedirect))
 	mux.Handle("/api/authmethods/{method}/{id}", http.HandlerFunc(c.authMethodsByID))
@@ -29,7 +33,11 @@ func (c *Context) getRouter(assets fs.FS, indexHtml []byte) *http.ServeMux {
 
 	// endpoints for apps
 	for appName, app := range c.Apps.Clients {
-		mux.Handle("/api/"+appName+"/", c.authMiddleware(c.injectUserMiddleware(app.GetRouter())))
+		var handler http.Handler = configUnlockMiddleware(app.GetRouter())
+		if appWithGroups, ok := app.(AppClientWithGroups); ok {
+			handler = IsMemberOfGroupMiddleware(appWithGroups.AllowedGroups(), handler)
+		}
+		mux.Handle("/api/"+appName+"/", c.authMiddleware(c.injectUserMiddleware(handler)))
 	}
 
 	// scim
@@ -41,18 +49,40 @@ func (c *Context) getRouter(assets fs.FS, indexHtml []byte) *http.ServeMux {
 	mux.Handle("/api/profile/factors", c.authMiddleware(c.injectUserMiddleware(http.HandlerFunc(c.profileFactorsHandler))))
 	mux.Handle("/api/profile/factors/{name}", c.authMiddleware(c.injectUserMiddleware(http.HandlerFunc(c.profileFactorsHandler))))
 
-	// endpoints with authentication, with admin role
-	mux.Handle("/api/license", c.authMiddleware(c.injectUserMiddleware(c.isAdminMiddleware(http.HandlerFunc(c.licenseHandler)))))
-	mux.Handle("/api/license/{action}", c.authMiddleware(c.injectUserMiddleware(c.isAdminMiddleware(http.HandlerFunc(c.licenseHandler)))))
-	mux.Handle("/api/oidc", c.authMiddleware(c.injectUserMiddleware(c.isAdminMiddleware(http.HandlerFunc(c.oidcProviderHandler)))))
-	mux.Handle("/api/oidc-renew-tokens", c.authMiddleware(c.injectUserMiddleware(c.isAdminMiddleware(http.HandlerFunc(c.oidcRenewTokensHandler)))))
-	mux.Handle("/api/oidc/{id}", c.authMiddleware(c.injectUserMiddleware(c.isAdminMiddleware(http.HandlerFunc(c.oidcProviderElementHandler)))))
-	mux.Handle("/api/setup/general", c.authMiddleware(c.injectUserMiddleware(c.isAdminMiddleware(http.HandlerFunc(c.setupHandler)))))
-	mux.Handle("/api/scim-setup", c.authMiddleware(c.injectUserMiddleware(c.isAdminMiddleware(http.HandlerFunc(c.scimSetupHandler)))))
-	mux.Handle("/api/saml-setup", c.authMiddleware(c.injectUserMiddleware(c.isAdminMiddleware(http.HandlerFunc(c.samlSetupHandler)))))
-	mux.Handle("/api/saml-setup/{id}", c.authMiddleware(c.injectUserMiddleware(c.isAdminMiddleware(http.HandlerFunc(c.samlSetupElementHandler)))))
-	mux.Handle("/api/users", c.authMiddleware(c.injectUserMiddleware(c.isAdminMiddleware(http.HandlerFunc(c.usersHandler)))))
-	mux.Handle("/api/user/{id}", c.authMiddleware(c.injectUserMiddleware(c.isAdminMiddleware(http.HandlerFunc(c.userHandler)))))
+	// endpoints with authentication, with permissions of the role of the user
+	mux.Handle("/api/license", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionLicenseRead)(http.HandlerFunc(c.licenseHandler)))))
+	mux.Handle("/api/license/{action}", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionLicenseManage)(http.HandlerFunc(c.licenseHandler)))))
+	mux.Handle("/api/oidc", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionOIDCManage)(http.HandlerFunc(c.oidcProviderHandler)))))
+	mux.Handle("/api/oidc-renew-tokens", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionOIDCManage)(http.HandlerFunc(c.oidcRenewTokensHandler)))))
+	mux.Handle("/api/oidc/{id}", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionOIDCManage)(http.HandlerFunc(c.oidcProviderElementHandler)))))
+	mux.Handle("/api/setup/general", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionSetupManage)(http.HandlerFunc(c.setupHandler)))))
+	mux.Handle("/api/setup/inactivity-policy", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionSetupManage)(http.HandlerFunc(c.inactivityPolicyHandler)))))
+	mux.Handle("/api/setup/password-policy", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionSetupManage)(http.HandlerFunc(c.passwordPolicyHandler)))))
+	mux.Handle("/api/setup/lockout-policy", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionSetupManage)(http.HandlerFunc(c.lockoutPolicyHandler)))))
+	mux.Handle("/api/lockouts", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionUsersRead)(http.HandlerFunc(c.lockoutsHandler)))))
+	mux.Handle("/api/lockouts/{key}", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionUsersWrite)(http.HandlerFunc(c.lockoutHandler)))))
+	mux.Handle("/api/scim-setup", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionSCIMManage)(http.HandlerFunc(c.scimSetupHandler)))))
+	mux.Handle("/api/saml-setup", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionSAMLManage)(http.HandlerFunc(c.samlSetupHandler)))))
+	mux.Handle("/api/saml-setup/{id}", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionSAMLManage)(http.HandlerFunc(c.samlSetupElementHandler)))))
+	mux.Handle("/api/users/import", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionUsersWrite)(http.HandlerFunc(c.usersImportHandler)))))
+	mux.Handle("/api/users/inactivity-report", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionUsersRead)(http.HandlerFunc(c.inactivityReportHandler)))))
+	mux.Handle("/api/users/export", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionUsersRead)(http.HandlerFunc(c.usersExportHandler)))))
+	mux.Handle("/api/users/events/dead-letters", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionUsersRead)(http.HandlerFunc(c.userEventsDeadLettersHandler)))))
+	mux.Handle("/api/users/events/dead-letters/{id}", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionUsersWrite)(http.HandlerFunc(c.userEventsDeadLetterHandler)))))
+	mux.Handle("/api/users", c.authMiddleware(c.injectUserMiddleware(c.requireReadWritePermission(users.PermissionUsersRead, users.PermissionUsersWrite, http.HandlerFunc(c.usersHandler)))))
+	mux.Handle("/api/user/{id}/password-reset", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionUsersWrite)(http.HandlerFunc(c.userPasswordResetHandler)))))
+	mux.Handle("/api/user/{id}/restore", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionUsersWrite)(http.HandlerFunc(c.userRestoreHandler)))))
+	mux.Handle("/api/invitations", c.authMiddleware(c.injectUserMiddleware(c.requireReadWritePermission(users.PermissionUsersRead, users.PermissionUsersWrite, http.HandlerFunc(c.invitationsHandler)))))
+	mux.Handle("/api/invitations/{id}", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionUsersWrite)(http.HandlerFunc(c.invitationHandler)))))
+	mux.Handle("/api/user/{id}", c.authMiddleware(c.injectUserMiddleware(c.requireReadWritePermission(users.PermissionUsersRead, users.PermissionUsersWrite, http.HandlerFunc(c.userHandler)))))
+	mux.Handle("/api/groups", c.authMiddleware(c.injectUserMiddleware(c.requireReadWritePermission(users.PermissionGroupsRead, users.PermissionGroupsWrite, http.HandlerFunc(c.groupsHandler)))))
+	mux.Handle("/api/groups/{id}", c.authMiddleware(c.injectUserMiddleware(c.requireReadWritePermission(users.PermissionGroupsRead, users.PermissionGroupsWrite, http.HandlerFunc(c.groupHandler)))))
+	mux.Handle("/api/groups/{id}/members", c.authMiddleware(c.injectUserMiddleware(c.requireReadWritePermission(users.PermissionGroupsRead, users.PermissionGroupsWrite, http.HandlerFunc(c.groupMembersHandler)))))
+	mux.Handle("/api/groups/{id}/members/{userID}", c.authMiddleware(c.injectUserMiddleware(c.requireReadWritePermission(users.PermissionGroupsRead, users.PermissionGroupsWrite, http.HandlerFunc(c.groupMembersHandler)))))
+	mux.Handle("/api/roles", c.authMiddleware(c.injectUserMiddleware(c.requireReadWritePermission(users.PermissionUsersRead, users.PermissionRolesManage, http.HandlerFunc(c.rolesHandler)))))
+	mux.Handle("/api/roles/{name}", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionRolesManage)(http.HandlerFunc(c.roleHandler)))))
+	mux.Handle("/api/backup", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionAll)(http.HandlerFunc(c.backupHandler)))))
+	mux.Handle("/api/restore", c.authMiddleware(c.injectUserMiddleware(c.RequirePermission(users.PermissionAll)(http.HandlerFunc(c.restoreHandler)))))
 
 	return mux
 }
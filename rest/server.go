@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -21,6 +22,11 @@ var (
 
 func StartServer(httpPort, httpsPort int, storage storage.Iface, c *Context, assets fs.FS) {
 	go handleSignals(c)
+	go watchConfig(context.Background(), c)
+	c.startAuditLogRetentionWorker()
+	go purgeDeletedUsersWorker(c.UserStore)
+	go userEventsOutboxWorker(c.UserStore.Events())
+	go inactivityPolicyWorker(c)
 
 	assetsFS, err := fs.Sub(assets, "static")
 	if err != nil {
@@ -44,7 +50,7 @@ func StartServer(httpPort, httpsPort int, storage storage.Iface, c *Context, ass
 		httpServer := &http.Server{
 			Addr: fmt.Sprintf(":%d", httpPort),
 
-			Handler: certManager.HTTPHandler(c.loggingMiddleware(c.httpsRedirectMiddleware(c.corsMiddleware(c.getRouter(assetsFS, indexHtmlBody))))),
+			Handler: certManager.HTTPHandler(c.configLockMiddleware(c.loggingMiddleware(c.httpsRedirectMiddleware(c.corsMiddleware(c.getRouter(assetsFS, indexHtmlBody)))))),
 
 			ReadHeaderTimeout: 5 * time.Second,
 			ReadTimeout:       10 * time.Second,
@@ -75,7 +81,7 @@ func StartServer(httpPort, httpsPort int, storage storage.Iface, c *Context, ass
 		TLSConfig: &tls.Config{
 			GetCertificate: certManager.GetCertificate,
 		},
-		Handler: c.loggingMiddleware(c.corsMiddleware(c.getRouter(assetsFS, indexHtmlBody))),
+		Handler: c.configLockMiddleware(c.loggingMiddleware(c.corsMiddleware(c.getRouter(assetsFS, indexHtmlBody)))),
 
 		ReadHeaderTimeout: 5 * time.Second,
 		ReadTimeout:       10 * time.Second,
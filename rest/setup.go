@@ -123,11 +123,14 @@ func (c *Context) setupHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		setupRequest := GeneralSetupRequest{
-			Hostname:               c.Hostname,
-			EnableTLS:              c.EnableTLS,
-			RedirectToHttps:        c.RedirectToHttps,
-			DisableLocalAuth:       c.LocalAuthDisabled,
-			EnableOIDCTokenRenewal: c.EnableOIDCTokenRenewal,
+			Hostname:                    c.Hostname,
+			EnableTLS:                   c.EnableTLS,
+			RedirectToHttps:             c.RedirectToHttps,
+			DisableLocalAuth:            c.LocalAuthDisabled,
+			EnableOIDCTokenRenewal:      c.EnableOIDCTokenRenewal,
+			AuditLogCompressDays:        c.AuditLogCompressDays,
+			AuditLogRetentionDays:       c.AuditLogRetentionDays,
+			UserDeletionGracePeriodDays: c.UserDeletionGracePeriodDays,
 		}
 		out, err := json.Marshal(setupRequest)
 		if err != nil {
@@ -158,6 +161,17 @@ func (c *Context) setupHandler(w http.ResponseWriter, r *http.Request) {
 			c.EnableOIDCTokenRenewal = setupRequest.EnableOIDCTokenRenewal
 			c.OIDCRenewal.SetEnabled(c.EnableOIDCTokenRenewal)
 		}
+		if setupRequest.AuditLogCompressDays != 0 { // 0 is not set, negative disables compression
+			c.AuditLogCompressDays = setupRequest.AuditLogCompressDays
+		}
+		if setupRequest.AuditLogRetentionDays != 0 { // 0 is not set, negative keeps the logs forever
+			c.AuditLogRetentionDays = setupRequest.AuditLogRetentionDays
+		}
+		c.startAuditLogRetentionWorker()
+		if setupRequest.UserDeletionGracePeriodDays != 0 { // 0 is not set, negative deletes users immediately
+			c.UserDeletionGracePeriodDays = setupRequest.UserDeletionGracePeriodDays
+			c.UserStore.SetDeletionGracePeriod(c.userDeletionGracePeriod())
+		}
 		err := SaveConfig(c)
 		if err != nil {
 			c.returnError(w, fmt.Errorf("could not save config to disk: %s", err), http.StatusBadRequest)
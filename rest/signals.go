@@ -7,6 +7,9 @@ import (
 	"os/signal"
 	"path"
 	"syscall"
+
+	"github.com/in4it/go-devops-platform/auth/oidc"
+	"github.com/in4it/go-devops-platform/logging"
 )
 
 func handleSignals(c *Context) {
@@ -25,14 +28,112 @@ func handleSignals(c *Context) {
 	}
 }
 
+// ReloadConfig reloads config.json, users.json, groups.json and the oidc store from storage
 func (c *Context) ReloadConfig() {
-	newC, err := NewContext(c.Storage.Client, c.ServerType, c.UserStore, c.SCIM.Client, c.LicenseUserCount, c.CloudType, c.Apps.Clients)
+	_, err := c.reloadConfig()
 	if err != nil {
 		log.Printf("ReloadConfig failed: %s\n", err)
+		return
+	}
+	if c.UserStore != nil {
+		_, err = c.UserStore.Reload()
+		if err != nil {
+			log.Printf("ReloadConfig failed (users): %s\n", err)
+			return
+		}
+		_, err = c.UserStore.Groups().Reload()
+		if err != nil {
+			log.Printf("ReloadConfig failed (groups): %s\n", err)
+			return
+		}
+	}
+	if c.OIDCStore != nil {
+		_, err = c.OIDCStore.Reload()
+		if err != nil {
+			log.Printf("ReloadConfig failed (oidc store): %s\n", err)
+			return
+		}
 	}
-	c.AppDir = newC.AppDir
-	c.Hostname = newC.Hostname
-	c.SetupCompleted = newC.SetupCompleted
-	c.UserStore = newC.UserStore
 	log.Printf("Config Reloaded!\n")
 }
+
+// reloadConfig copies the fields persisted in config.json, if the file changed since it was last loaded or saved.
+// It waits until the running requests are handled, so handlers don't see the config change while they run.
+func (c *Context) reloadConfig() (bool, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	return c.loadConfig()
+}
+
+// loadConfig is reloadConfig without taking configMu, for handlers that reload the config themselves
+func (c *Context) loadConfig() (bool, error) {
+	newC, err := GetConfig(c.Storage.Client)
+	if err != nil {
+		return false, fmt.Errorf("getConfig error: %s", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if newC.configHash == c.configHash {
+		return false, nil
+	}
+	c.SetupCompleted = newC.SetupCompleted
+	c.Hostname = newC.Hostname
+	c.Protocol = newC.Protocol
+	c.JWTKeysKID = newC.JWTKeysKID
+	c.OIDCProviders = newC.OIDCProviders
+	if c.OIDCProviders == nil {
+		c.OIDCProviders = []oidc.OIDCProvider{}
+	}
+	c.LocalAuthDisabled = newC.LocalAuthDisabled
+	c.EnableTLS = newC.EnableTLS
+	c.RedirectToHttps = newC.RedirectToHttps
+	if c.EnableOIDCTokenRenewal != newC.EnableOIDCTokenRenewal {
+		c.EnableOIDCTokenRenewal = newC.EnableOIDCTokenRenewal
+		if c.OIDCRenewal != nil {
+			c.OIDCRenewal.SetEnabled(c.EnableOIDCTokenRenewal)
+		}
+	}
+	c.TokenRenewalTimeMinutes = newC.TokenRenewalTimeMinutes
+	c.LogLevel = newC.LogLevel
+	c.AuditLogCompressDays = newC.AuditLogCompressDays
+	c.AuditLogRetentionDays = newC.AuditLogRetentionDays
+	c.startAuditLogRetentionWorker()
+	c.UserDeletionGracePeriodDays = newC.UserDeletionGracePeriodDays
+	c.Roles = newC.Roles
+	c.PasswordPolicy = newC.PasswordPolicy
+	c.InactivityPolicy = newC.InactivityPolicy
+	if c.UserStore != nil {
+		c.UserStore.SetPasswordPolicy(c.PasswordPolicy)
+		c.UserStore.SetDeletionGracePeriod(c.userDeletionGracePeriod())
+	}
+	if err := newC.LockoutPolicy.Validate(); err != nil {
+		logging.ErrorLog(fmt.Errorf("invalid lockout policy in config, keeping the current policy: %s", err))
+	} else {
+		c.LockoutPolicy = newC.LockoutPolicy
+		if c.LoginLockout != nil {
+			c.LoginLockout.SetPolicy(c.LockoutPolicy)
+		}
+	}
+	if err := newC.PasswordHashing.Validate(); err != nil {
+		logging.ErrorLog(fmt.Errorf("invalid password hashing parameters in config, keeping the current parameters: %s", err))
+	} else {
+		c.PasswordHashing = newC.PasswordHashing
+		if c.UserStore != nil {
+			c.UserStore.SetPasswordHashParams(c.PasswordHashing)
+		}
+	}
+	if newC.SCIM != nil && c.SCIM != nil {
+		c.SCIM.EnableSCIM = newC.SCIM.EnableSCIM
+		if c.SCIM.Token != newC.SCIM.Token {
+			c.SCIM.Token = newC.SCIM.Token
+			if c.SCIM.Client != nil {
+				c.SCIM.Client.UpdateToken(c.SCIM.Token)
+			}
+		}
+	}
+	if newC.SAML != nil && newC.SAML.Providers != nil && c.SAML != nil && c.SAML.Providers != nil {
+		*c.SAML.Providers = *newC.SAML.Providers // the saml client keeps a pointer to the providers
+	}
+	c.configHash = newC.configHash
+	return true, nil
+}
@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"crypto/sha256"
 	"net/http"
 	"time"
 
@@ -21,31 +22,48 @@ type AppClient interface {
 	GetRouter() *http.ServeMux
 }
 
+// AppClientWithGroups can be implemented by an AppClient to only allow admins and members of the groups (by name)
+type AppClientWithGroups interface {
+	AppClient
+	AllowedGroups() []string
+}
+
 type Context struct {
-	AppDir                  string               `json:"appDir,omitempty"`
-	ServerType              string               `json:"serverType,omitempty"`
-	SetupCompleted          bool                 `json:"setupCompleted"`
-	Hostname                string               `json:"hostname,omitempty"`
-	Protocol                string               `json:"protocol,omitempty"`
-	JWTKeys                 *JWTKeys             `json:"jwtKeys,omitempty"`
-	JWTKeysKID              string               `json:"jwtKeysKid,omitempty"`
-	OIDCProviders           []oidc.OIDCProvider  `json:"oidcProviders,omitempty"`
-	LocalAuthDisabled       bool                 `json:"disableLocalAuth,omitempty"`
-	EnableTLS               bool                 `json:"enableTLS,omitempty"`
-	RedirectToHttps         bool                 `json:"redirectToHttps,omitempty"`
-	EnableOIDCTokenRenewal  bool                 `json:"enableOIDCTokenRenewal,omitempty"`
-	OIDCStore               *oidcstore.Store     `json:"oidcStore,omitempty"`
-	UserStore               *users.UserStore     `json:"users,omitempty"`
-	OIDCRenewal             *oidcrenewal.Renewal `json:"oidcRenewal,omitempty"`
-	LoginAttempts           login.Attempts       `json:"loginAttempts,omitempty"`
-	LicenseUserCount        int                  `json:"licenseUserCount,omitempty"`
-	CloudType               string               `json:"cloudType,omitempty"`
-	TokenRenewalTimeMinutes int                  `json:"tokenRenewalTimeMinutes,omitempty"`
-	LogLevel                int                  `json:"loglevel,omitempty"`
-	SCIM                    *SCIM                `json:"scim,omitempty"`
-	SAML                    *SAML                `json:"saml,omitempty"`
-	Apps                    *Apps                `json:"apps,omitempty"`
-	Storage                 *Storage             `json:"storage,omitempty"`
+	AppDir                      string                   `json:"appDir,omitempty"`
+	ServerType                  string                   `json:"serverType,omitempty"`
+	SetupCompleted              bool                     `json:"setupCompleted"`
+	Hostname                    string                   `json:"hostname,omitempty"`
+	Protocol                    string                   `json:"protocol,omitempty"`
+	JWTKeys                     *JWTKeys                 `json:"jwtKeys,omitempty"`
+	JWTKeysKID                  string                   `json:"jwtKeysKid,omitempty"`
+	OIDCProviders               []oidc.OIDCProvider      `json:"oidcProviders,omitempty"`
+	LocalAuthDisabled           bool                     `json:"disableLocalAuth,omitempty"`
+	EnableTLS                   bool                     `json:"enableTLS,omitempty"`
+	RedirectToHttps             bool                     `json:"redirectToHttps,omitempty"`
+	EnableOIDCTokenRenewal      bool                     `json:"enableOIDCTokenRenewal,omitempty"`
+	OIDCStore                   *oidcstore.Store         `json:"oidcStore,omitempty"`
+	UserStore                   *users.UserStore         `json:"users,omitempty"`
+	OIDCRenewal                 *oidcrenewal.Renewal     `json:"oidcRenewal,omitempty"`
+	LoginLockout                *login.Lockout           `json:"-"`
+	LoginAttempts               login.Attempts           `json:"loginAttempts,omitempty"` // Deprecated: not used anymore, see LoginLockout
+	LicenseUserCount            int                      `json:"licenseUserCount,omitempty"`
+	CloudType                   string                   `json:"cloudType,omitempty"`
+	TokenRenewalTimeMinutes     int                      `json:"tokenRenewalTimeMinutes,omitempty"`
+	LogLevel                    int                      `json:"loglevel,omitempty"`
+	AuditLogCompressDays        int                      `json:"auditLogCompressDays,omitempty"`
+	AuditLogRetentionDays       int                      `json:"auditLogRetentionDays,omitempty"`
+	UserDeletionGracePeriodDays int                      `json:"userDeletionGracePeriodDays,omitempty"` // deleted users are suspended and purged after the grace period
+	SCIM                        *SCIM                    `json:"scim,omitempty"`
+	SAML                        *SAML                    `json:"saml,omitempty"`
+	Roles                       []users.Role             `json:"roles,omitempty"` // custom roles, next to the built-in roles
+	PasswordPolicy              users.PasswordPolicy     `json:"passwordPolicy,omitempty"`
+	PasswordHashing             users.PasswordHashParams `json:"passwordHashing,omitempty"` // parameters for new password hashes, empty is the default (argon2id)
+	LockoutPolicy               login.LockoutPolicy      `json:"lockoutPolicy,omitempty"`
+	InactivityPolicy            users.InactivityPolicy   `json:"inactivityPolicy,omitempty"`
+	Apps                        *Apps                    `json:"apps,omitempty"`
+	Storage                     *Storage                 `json:"storage,omitempty"`
+	MailSender                  MailSender               `json:"-"`
+	configHash                  [sha256.Size]byte        // hash of the last loaded or saved config.json
 }
 type SCIM struct {
 	EnableSCIM bool       `json:"enableSCIM,omitempty"`
@@ -99,17 +117,22 @@ type SAMLCallback struct {
 }
 
 type UserInfoResponse struct {
-	Login    string `json:"login"`
-	Role     string `json:"role"`
-	UserType string `json:"userType"`
+	Login       string             `json:"login"`
+	Role        string             `json:"role"`
+	UserType    string             `json:"userType"`
+	Permissions []users.Permission `json:"permissions"`
+	users.Profile
 }
 
 type GeneralSetupRequest struct {
-	Hostname               string `json:"hostname"`
-	EnableTLS              bool   `json:"enableTLS"`
-	RedirectToHttps        bool   `json:"redirectToHttps"`
-	DisableLocalAuth       bool   `json:"disableLocalAuth"`
-	EnableOIDCTokenRenewal bool   `json:"enableOIDCTokenRenewal"`
+	Hostname                    string `json:"hostname"`
+	EnableTLS                   bool   `json:"enableTLS"`
+	RedirectToHttps             bool   `json:"redirectToHttps"`
+	DisableLocalAuth            bool   `json:"disableLocalAuth"`
+	EnableOIDCTokenRenewal      bool   `json:"enableOIDCTokenRenewal"`
+	AuditLogCompressDays        int    `json:"auditLogCompressDays,omitempty"`
+	AuditLogRetentionDays       int    `json:"auditLogRetentionDays,omitempty"`
+	UserDeletionGracePeriodDays int    `json:"userDeletionGracePeriodDays,omitempty"`
 }
 
 type LicenseResponse struct {
@@ -127,15 +150,103 @@ type JwtHeader struct {
 
 type UsersResponse struct {
 	ID                               string    `json:"id"`
+	Groups                           []string  `json:"groups"`
 	Login                            string    `json:"login"`
 	Role                             string    `json:"role"`
 	OIDCID                           string    `json:"oidcID"`
 	SAMLID                           string    `json:"samlID"`
 	Provisioned                      bool      `json:"provisioned"`
 	Suspended                        bool      `json:"suspended"`
+	SuspendedReason                  string    `json:"suspendedReason,omitempty"`
+	Invited                          bool      `json:"invited"`
+	DeletedAt                        string    `json:"deletedAt,omitempty"`
 	ConnectionsDisabledOnAuthFailure bool      `json:"connectionsDisabledOnAuthFailure"`
 	LastTokenRenewal                 time.Time `json:"lastTokenRenewal,omitempty"`
 	LastLogin                        string    `json:"lastLogin"`
+	ExpiresAt                        string    `json:"expiresAt,omitempty"`
+	users.Profile
+}
+
+// UserExpiryRequest is the expiry date in a user update. The expiry date is only changed when it's in the request.
+type UserExpiryRequest struct {
+	ExpiresAt *users.TimeOrEmpty `json:"expiresAt"`
+}
+
+type PasswordResetRequest struct {
+	Login    string `json:"login,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+type UserPasswordResetRequest struct {
+	SendMail bool `json:"sendMail"`
+}
+type UserPasswordResetResponse struct {
+	Token     string    `json:"token,omitempty"`
+	Link      string    `json:"link,omitempty"`
+	Mailed    bool      `json:"mailed"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type InvitationRequest struct {
+	Login    string `json:"login"`
+	Role     string `json:"role"`
+	SendMail bool   `json:"sendMail"`
+	users.Profile
+}
+type InvitationResponse struct {
+	UserID    string    `json:"userID"`
+	Login     string    `json:"login"`
+	Role      string    `json:"role"`
+	Email     string    `json:"email,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Expired   bool      `json:"expired"`
+	Token     string    `json:"token,omitempty"`
+	Link      string    `json:"link,omitempty"`
+	Mailed    bool      `json:"mailed,omitempty"`
+}
+type InvitationInfoResponse struct {
+	Login     string    `json:"login"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+type AcceptInvitationRequest struct {
+	Token    string         `json:"token"`
+	Password string         `json:"password"`
+	Factor   *FactorRequest `json:"factor,omitempty"`
+}
+
+type ImportUserRequest struct {
+	Login        string `json:"login"`
+	Role         string `json:"role"`
+	Password     string `json:"password,omitempty"`
+	PasswordHash string `json:"passwordHash,omitempty"` // bcrypt, pbkdf2-sha256, sha512-crypt or argon2
+	users.Profile
+}
+type ImportUsersResponse struct {
+	DryRun  bool                 `json:"dryRun"`
+	Created int                  `json:"created"`
+	Updated int                  `json:"updated"`
+	Error   string               `json:"error,omitempty"`
+	Rows    []ImportUserResponse `json:"rows"`
+}
+type ImportUserResponse struct {
+	Row    int    `json:"row"`
+	ID     string `json:"id,omitempty"`
+	Login  string `json:"login"`
+	Role   string `json:"role"`
+	Action string `json:"action"` // create or update
+	Error  string `json:"error,omitempty"`
+}
+type UserExport struct {
+	ID        string `json:"id"`
+	Login     string `json:"login"`
+	Role      string `json:"role"`
+	Source    string `json:"source"` // local, oidc, saml or scim
+	Suspended bool   `json:"suspended"`
+	Invited   bool   `json:"invited"`
+	LastLogin string `json:"lastLogin,omitempty"`
+	Factors   int    `json:"factors"` // number of factors
+	users.Profile
 }
 
 type FactorRequest struct {
@@ -163,3 +274,43 @@ type NewUserRequest struct {
 	Role     string `json:"role"`
 	Password string `json:"password,omitempty"`
 }
+
+type RolesResponse struct {
+	Roles       []users.Role       `json:"roles"`
+	Permissions []users.Permission `json:"permissions"`
+}
+
+type GroupRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type GroupResponse struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ExternalID  string `json:"externalID,omitempty"`
+	MemberCount int    `json:"memberCount"`
+}
+
+type GroupMemberRequest struct {
+	UserID string `json:"userID"`
+}
+
+type GroupMemberResponse struct {
+	ID    string `json:"id"`
+	Login string `json:"login"`
+}
+
+type BackupManifest struct {
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Hostname  string            `json:"hostname"`
+	PublicKey string            `json:"publicKey"` // public key (PEM) of the key that signed the manifest
+	Files     map[string]string `json:"files"`     // sha256 checksum per file
+}
+
+type RestoreResponse struct {
+	Restored         []string `json:"restored"`
+	PreRestoreBackup string   `json:"preRestoreBackup"`
+}
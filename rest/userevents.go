@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/in4it/go-devops-platform/logging"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+const USER_EVENTS_OUTBOX_POLL_INTERVAL = 10 * time.Second
+
+// userEventsOutboxWorker executes the queued subscribers (e.g. the user hooks) with retries
+func userEventsOutboxWorker(events *users.Dispatcher) {
+	if events.Outbox() == nil {
+		return
+	}
+	for {
+		_, err := events.ProcessOutbox()
+		if err != nil {
+			logging.ErrorLog(fmt.Errorf("user events outbox error: %s", err))
+		}
+		events.Outbox().Wait(USER_EVENTS_OUTBOX_POLL_INTERVAL)
+	}
+}
+
+// userEventsDeadLettersHandler lists the user events that couldn't be delivered after the last retry
+func (c *Context) userEventsDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	deadLetters, err := c.UserStore.Events().Outbox().DeadLetters()
+	if err != nil {
+		c.returnError(w, fmt.Errorf("list dead letters error: %s", err), http.StatusBadRequest)
+		return
+	}
+	for k := range deadLetters {
+		deadLetters[k].Event.User.Factors = nil // don't return the mfa secrets of entries queued before Dispatch removed them
+	}
+	out, err := json.Marshal(deadLetters)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("could not marshal dead letters: %s", err), http.StatusBadRequest)
+		return
+	}
+	c.write(w, out)
+}
+
+// userEventsDeadLetterHandler replays (POST) or discards (DELETE) a dead letter
+func (c *Context) userEventsDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var action string
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		action = "replay user event " + id
+		_, err = c.UserStore.Events().Outbox().Replay(id)
+	case http.MethodDelete:
+		action = "discard user event " + id
+		err = c.UserStore.Events().Outbox().Discard(id)
+	default:
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, users.ErrOutboxEntryNotFound) {
+		c.returnError(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.returnError(w, fmt.Errorf("dead letter error: %s", err), http.StatusBadRequest)
+		return
+	}
+	if admin, ok := r.Context().Value(CustomValue("user")).(users.User); ok {
+		c.audit(admin.ID, action)
+	}
+	if r.Method == http.MethodDelete {
+		c.write(w, []byte(`{"deleted": "`+id+`"}`))
+		return
+	}
+	c.write(w, []byte(`{"replayed": "`+id+`"}`))
+}
@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func TestUserEventsDeadLetters(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	c, err := newContext(storage, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	fail := true
+	disabled := []string{}
+	c.UserStore.Events().SubscribeQueued("app", func(event users.Event) error {
+		if fail {
+			return fmt.Errorf("app unavailable")
+		}
+		disabled = append(disabled, event.User.Login)
+		return nil
+	}, users.EVENT_USER_DISABLED)
+	user, err := c.UserStore.AddUser(users.User{Login: "john", Role: users.ROLE_USER})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+
+	// a failing subscriber doesn't fail the request
+	req := httptest.NewRequest("PATCH", "http://example.com/api/user/"+user.ID, bytes.NewBufferString(`{"suspended": true}`))
+	req.SetPathValue("id", user.ID)
+	w := httptest.NewRecorder()
+	c.userHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	if user, _ := c.UserStore.GetUserByID(user.ID); !user.Suspended {
+		t.Fatalf("expected user to be suspended")
+	}
+	if _, err := c.UserStore.Events().ProcessOutbox(); err != nil {
+		t.Fatalf("process outbox error: %s", err)
+	}
+	pending, err := c.UserStore.Events().Outbox().Pending()
+	if err != nil || len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastError != "app unavailable" {
+		t.Fatalf("expected the event to be retried: %+v (%v)", pending, err)
+	}
+
+	// move the entry to the dead letters
+	out, err := json.Marshal(map[string][]users.OutboxEntry{"pending": {}, "deadLetters": pending})
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+	if err := storage.WriteFile(storage.ConfigPath(users.OUTBOX_FILENAME), out); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	req = httptest.NewRequest("GET", "http://example.com/api/users/events/dead-letters", nil)
+	w = httptest.NewRecorder()
+	c.userEventsDeadLettersHandler(w, req)
+	var deadLetters []users.OutboxEntry
+	if err := json.NewDecoder(w.Body).Decode(&deadLetters); err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if len(deadLetters) != 1 || deadLetters[0].Event.User.Login != "john" || deadLetters[0].Event.Type != users.EVENT_USER_DISABLED {
+		t.Fatalf("unexpected dead letters: %+v", deadLetters)
+	}
+
+	fail = false
+	req = httptest.NewRequest("POST", "http://example.com/api/users/events/dead-letters/"+deadLetters[0].ID, nil)
+	req.SetPathValue("id", deadLetters[0].ID)
+	w = httptest.NewRecorder()
+	c.userEventsDeadLetterHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	if _, err := c.UserStore.Events().ProcessOutbox(); err != nil {
+		t.Fatalf("process outbox error: %s", err)
+	}
+	if len(disabled) != 1 || disabled[0] != "john" {
+		t.Fatalf("expected replayed event to be delivered: %v", disabled)
+	}
+
+	req = httptest.NewRequest("DELETE", "http://example.com/api/users/events/dead-letters/"+deadLetters[0].ID, nil)
+	req.SetPathValue("id", deadLetters[0].ID)
+	w = httptest.NewRecorder()
+	c.userEventsDeadLetterHandler(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a replayed dead letter, got: %d", w.Code)
+	}
+}
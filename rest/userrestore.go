@@ -0,0 +1,60 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/in4it/go-devops-platform/logging"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+// userRestoreHandler restores a deleted user before the deletion grace period is over
+func (c *Context) userRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	user, err := c.UserStore.GetUserByID(r.PathValue("id"))
+	if err != nil {
+		c.returnError(w, fmt.Errorf("user not found: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := c.canChangeUser(r, user); err != nil {
+		c.returnError(w, err, http.StatusForbidden)
+		return
+	}
+	user, err = c.UserStore.RestoreUser(user.ID)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("restore user error: %s", err), http.StatusBadRequest)
+		return
+	}
+	out, err := json.Marshal(user)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("marshal user error: %s", err), http.StatusBadRequest)
+		return
+	}
+	c.write(w, out)
+}
+
+func (c *Context) userDeletionGracePeriod() time.Duration {
+	if c.UserDeletionGracePeriodDays <= 0 {
+		return 0
+	}
+	return time.Duration(c.UserDeletionGracePeriodDays) * 24 * time.Hour
+}
+
+// purgeDeletedUsersWorker purges the deleted users after their grace period, every hour
+func purgeDeletedUsersWorker(userStore *users.UserStore) {
+	for {
+		purged, err := userStore.PurgeDeletedUsers()
+		if err != nil {
+			logging.ErrorLog(fmt.Errorf("purge deleted users error: %s", err))
+		}
+		for _, user := range purged {
+			logging.DebugLog(fmt.Errorf("purged deleted user %s (%s)", user.Login, user.ID))
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func TestUserRestoreHandler(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	c.UserDeletionGracePeriodDays = 7
+	c.UserStore.SetDeletionGracePeriod(c.userDeletionGracePeriod())
+	user, err := c.UserStore.AddUser(users.User{Login: "john", Role: users.ROLE_USER})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	req := httptest.NewRequest("DELETE", "http://example.com/api/user/"+user.ID, nil)
+	req.SetPathValue("id", user.ID)
+	w := httptest.NewRecorder()
+	c.userHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	deletedUser, err := c.UserStore.GetUserByID(user.ID)
+	if err != nil || !deletedUser.Deleted() || !deletedUser.Suspended {
+		t.Fatalf("expected user to be kept and suspended during the grace period: %+v (%v)", deletedUser, err)
+	}
+	req = httptest.NewRequest("POST", "http://example.com/api/user/"+user.ID+"/restore", nil)
+	req.SetPathValue("id", user.ID)
+	w = httptest.NewRecorder()
+	c.userRestoreHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	restoredUser, err := c.UserStore.GetUserByID(user.ID)
+	if err != nil || restoredUser.Deleted() || restoredUser.Suspended {
+		t.Fatalf("expected user to be restored: %+v (%v)", restoredUser, err)
+	}
+}
@@ -3,11 +3,12 @@ package rest
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/in4it/go-devops-platform/storage"
+	"github.com/in4it/go-devops-platform/auth/oidc"
 	"github.com/in4it/go-devops-platform/users"
 )
 
@@ -67,11 +68,24 @@ func (c *Context) usersHandler(w http.ResponseWriter, r *http.Request) {
 			userResponse[k].OIDCID = user.OIDCID
 			userResponse[k].SAMLID = user.SAMLID
 			userResponse[k].Suspended = user.Suspended
+			userResponse[k].SuspendedReason = user.SuspendedReason
+			userResponse[k].Invited = user.Invited
+			if user.Deleted() {
+				userResponse[k].DeletedAt = user.DeletedAt.UTC().Format(time.RFC3339)
+			}
 			userResponse[k].Provisioned = user.Provisioned
 			userResponse[k].ConnectionsDisabledOnAuthFailure = user.ConnectionsDisabledOnAuthFailure
+			userResponse[k].Profile = user.Profile
+			userResponse[k].Groups = []string{}
+			for _, group := range c.UserStore.GetUserGroups(user) {
+				userResponse[k].Groups = append(userResponse[k].Groups, group.Name)
+			}
 			if !user.LastLogin.IsZero() {
 				userResponse[k].LastLogin = user.LastLogin.UTC().Format(time.RFC3339)
 			}
+			if !user.ExpiresAt.IsZero() {
+				userResponse[k].ExpiresAt = user.ExpiresAt.UTC().Format(time.RFC3339)
+			}
 			for _, oauth2Data := range c.OIDCStore.OAuth2Data {
 				if oauth2Data.ID == user.OIDCID {
 					userResponse[k].LastTokenRenewal = oauth2Data.LastTokenRenewal
@@ -104,8 +118,8 @@ func (c *Context) usersHandler(w http.ResponseWriter, r *http.Request) {
 			c.returnError(w, fmt.Errorf("password is empty"), http.StatusBadRequest)
 			return
 		}
-		if user.Role != "user" && user.Role != "admin" {
-			c.returnError(w, fmt.Errorf("invalid role"), http.StatusBadRequest)
+		if err := c.canAssignRole(r, user.Role); err != nil {
+			c.returnError(w, err, http.StatusBadRequest)
 			return
 		}
 		if c.UserStore.UserCount() >= c.LicenseUserCount {
@@ -133,14 +147,18 @@ func (c *Context) userHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodDelete:
 		userID := r.PathValue("id")
-		err := c.UserStore.DeleteUserByID(userID)
+		user, err := c.UserStore.GetUserByID(userID)
 		if err != nil {
-			c.returnError(w, fmt.Errorf("delete user error: %s", err), http.StatusBadRequest)
+			c.returnError(w, fmt.Errorf("user not found: %s", err), http.StatusBadRequest)
 			return
 		}
-		err = c.UserStore.UserHooks.DeleteFunc(c.Storage.Client, users.User{ID: userID})
+		if err := c.canChangeUser(r, user); err != nil {
+			c.returnError(w, err, http.StatusForbidden)
+			return
+		}
+		err = c.UserStore.DeleteUser(userID) // soft delete when a deletion grace period is configured
 		if err != nil {
-			c.returnError(w, fmt.Errorf("could not delete all clients for user %s: %s", userID, err), http.StatusBadRequest)
+			c.returnError(w, fmt.Errorf("delete user error: %s", err), http.StatusBadRequest)
 			return
 		}
 		c.write(w, []byte(`{"deleted": "`+userID+`"}`))
@@ -150,34 +168,45 @@ func (c *Context) userHandler(w http.ResponseWriter, r *http.Request) {
 			c.returnError(w, fmt.Errorf("user not found: %s", err), http.StatusBadRequest)
 			return
 		}
+		if err := c.canChangeUser(r, dbUser); err != nil {
+			c.returnError(w, err, http.StatusForbidden)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("read input error: %s", err), http.StatusBadRequest)
+			return
+		}
 		var user users.User
-		decoder := json.NewDecoder(r.Body)
-		err = decoder.Decode(&user)
+		err = json.Unmarshal(body, &user)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+			return
+		}
+		var expiry UserExpiryRequest
+		err = json.Unmarshal(body, &expiry)
 		if err != nil {
 			c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
 			return
 		}
 		updateUser := false
+		if expiry.ExpiresAt != nil && !time.Time(*expiry.ExpiresAt).Equal(time.Time(dbUser.ExpiresAt)) { // an empty string removes the expiry date
+			dbUser.ExpiresAt = *expiry.ExpiresAt
+			updateUser = true
+		}
+		suspendedChanged := false
 		if user.Role != "" && dbUser.Role != user.Role {
+			if err := c.canAssignRole(r, user.Role); err != nil {
+				c.returnError(w, err, http.StatusBadRequest)
+				return
+			}
 			dbUser.Role = user.Role
 			updateUser = true
 		}
 		if dbUser.Suspended != user.Suspended {
 			dbUser.Suspended = user.Suspended
 			updateUser = true
-			if user.Suspended { // user is now suspended
-				err := c.UserStore.UserHooks.DisableFunc(c.Storage.Client, user)
-				if err != nil {
-					c.returnError(w, fmt.Errorf("could not delete all clients for user %s: %s", user.ID, err), http.StatusBadRequest)
-					return
-				}
-			} else { // user is now unsuspended
-				err := c.UserStore.UserHooks.ReactivateFunc(c.Storage.Client, user)
-				if err != nil {
-					c.returnError(w, fmt.Errorf("could not reactivate all clients for user %s: %s", user.ID, err), http.StatusBadRequest)
-					return
-				}
-			}
+			suspendedChanged = true
 		}
 		if updateUser {
 			err = c.UserStore.UpdateUser(dbUser)
@@ -186,8 +215,19 @@ func (c *Context) userHandler(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		}
+		if suspendedChanged { // the hooks are executed by the outbox worker
+			event := users.Event{Type: users.EVENT_USER_REACTIVATED, User: dbUser}
+			if dbUser.Suspended {
+				event.Type = users.EVENT_USER_DISABLED
+			}
+			err = c.UserStore.Events().Dispatch(event)
+			if err != nil {
+				c.returnError(w, fmt.Errorf("could not queue %s event for user %s: %s", event.Type, dbUser.ID, err), http.StatusBadRequest)
+				return
+			}
+		}
 		if user.Password != "" {
-			err = c.UserStore.UpdatePassword(user.ID, user.Password)
+			err = c.UserStore.UpdatePassword(dbUser.ID, user.Password)
 			if err != nil {
 				c.returnError(w, fmt.Errorf("update password error: %s", err), http.StatusBadRequest)
 				return
@@ -204,7 +244,7 @@ func (c *Context) userHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func addOrModifyExternalUser(storage storage.Iface, userStore *users.UserStore, login, authType, externalAuthID string) (users.User, error) {
+func addOrModifyExternalUser(userStore *users.UserStore, login, authType, externalAuthID string, profile users.Profile) (users.User, error) {
 	if userStore.LoginExists(login) {
 		existingUser, err := userStore.GetUserByLogin(login)
 		if err != nil {
@@ -218,25 +258,34 @@ func addOrModifyExternalUser(storage storage.Iface, userStore *users.UserStore,
 			existingUser.SAMLID = externalAuthID
 		}
 
-		if existingUser.ConnectionsDisabledOnAuthFailure { // we can enable connections again after auth
-			err := userStore.UserHooks.ReactivateFunc(storage, existingUser)
-			if err != nil {
-				return existingUser, fmt.Errorf("could not reactivate all clients for user %s: %s", existingUser.ID, err)
-			}
+		// we can enable connections again after auth, unless the user is suspended or deleted (restoring a deleted user reactivates it)
+		reactivate := existingUser.ConnectionsDisabledOnAuthFailure && !existingUser.Suspended && !existingUser.Deleted()
+		if reactivate {
 			existingUser.ConnectionsDisabledOnAuthFailure = false
 		}
 
-		existingUser.LastLogin = users.TimeOrEmpty(time.Now())
+		existingUser.UpdateProfile(profile)
 
 		err = userStore.UpdateUser(existingUser)
 		if err != nil {
 			return existingUser, fmt.Errorf("couldn't update user: %s", login)
 		}
+		if reactivate {
+			err := userStore.Events().Dispatch(users.Event{Type: users.EVENT_USER_REACTIVATED, User: existingUser})
+			if err != nil {
+				return existingUser, fmt.Errorf("could not queue reactivated event for user %s: %s", existingUser.ID, err)
+			}
+		}
+		err = userStore.RecordLogin(existingUser.ID, authType)
+		if err != nil {
+			return existingUser, fmt.Errorf("couldn't record login: %s", err)
+		}
 		return existingUser, nil
 	} else {
 		newUser := users.User{
-			Login: login,
-			Role:  "user",
+			Login:   login,
+			Role:    "user",
+			Profile: profile,
 		}
 		if authType == "oidc" {
 			newUser.OIDCID = externalAuthID
@@ -245,16 +294,28 @@ func addOrModifyExternalUser(storage storage.Iface, userStore *users.UserStore,
 			newUser.SAMLID = externalAuthID
 		}
 
-		newUser.LastLogin = users.TimeOrEmpty(time.Now())
-
 		newUserAdded, err := userStore.AddUser(newUser)
 		if err != nil {
 			return newUserAdded, fmt.Errorf("could not add user: %s", err)
 		}
+		err = userStore.RecordLogin(newUserAdded.ID, authType)
+		if err != nil {
+			return newUserAdded, fmt.Errorf("couldn't record login: %s", err)
+		}
 		return newUserAdded, nil
 	}
 }
 
+func oidcProfile(userInfo oidc.UserInfo) users.Profile {
+	return users.Profile{
+		DisplayName: userInfo.Name,
+		GivenName:   userInfo.GivenName,
+		FamilyName:  userInfo.FamilyName,
+		Email:       userInfo.Email,
+		Locale:      userInfo.Locale,
+	}
+}
+
 func (c *Context) userinfoHandler(w http.ResponseWriter, r *http.Request) {
 	var response UserInfoResponse
 
@@ -262,6 +323,8 @@ func (c *Context) userinfoHandler(w http.ResponseWriter, r *http.Request) {
 
 	response.Login = user.Login
 	response.Role = user.Role
+	response.Permissions = c.permissions(user)
+	response.Profile = user.Profile
 	if user.OIDCID == "" {
 		response.UserType = "local"
 	} else {
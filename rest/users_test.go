@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
 	"github.com/in4it/go-devops-platform/users"
@@ -46,3 +47,46 @@ func TestCreateUser(t *testing.T) {
 	}
 
 }
+
+func TestAddOrModifyExternalUserDeleted(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	c.UserStore.SetDeletionGracePeriod(24 * time.Hour)
+	reactivated := []string{}
+	c.UserStore.Events().Subscribe("test", func(event users.Event) error {
+		reactivated = append(reactivated, event.User.Login)
+		return nil
+	}, users.EVENT_USER_REACTIVATED)
+	for _, login := range []string{"john", "jane"} {
+		_, err = c.UserStore.AddUser(users.User{Login: login, Role: users.ROLE_USER, ConnectionsDisabledOnAuthFailure: true})
+		if err != nil {
+			t.Fatalf("add user error: %s", err)
+		}
+	}
+	jane, err := c.UserStore.GetUserByLogin("jane")
+	if err != nil {
+		t.Fatalf("get user error: %s", err)
+	}
+	err = c.UserStore.DeleteUser(jane.ID)
+	if err != nil {
+		t.Fatalf("delete user error: %s", err)
+	}
+	for _, login := range []string{"john", "jane"} {
+		_, err = addOrModifyExternalUser(c.UserStore, login, "oidc", "oidc-"+login, users.Profile{})
+		if err != nil {
+			t.Fatalf("add or modify external user error: %s", err)
+		}
+	}
+	if len(reactivated) != 1 || reactivated[0] != "john" {
+		t.Fatalf("expected only john to be reactivated, got: %v", reactivated)
+	}
+	jane, err = c.UserStore.GetUserByLogin("jane")
+	if err != nil {
+		t.Fatalf("get user error: %s", err)
+	}
+	if !jane.ConnectionsDisabledOnAuthFailure {
+		t.Fatalf("expected connections of deleted user to stay disabled")
+	}
+}
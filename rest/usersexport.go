@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/in4it/go-devops-platform/logging"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+var userExportColumns = []string{"id", "login", "role", "source", "suspended", "invited", "lastLogin", "factors", "displayName", "givenName", "familyName", "email", "locale"}
+
+// usersExportHandler exports the users as JSON, or as CSV with format=csv. Passwords and factor secrets are not exported.
+func (c *Context) usersExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	userList := c.UserStore.ListUsers()
+	export := make([]UserExport, len(userList))
+	for k, user := range userList {
+		export[k] = UserExport{
+			ID:        user.ID,
+			Login:     user.Login,
+			Role:      user.Role,
+			Source:    userSource(user),
+			Suspended: user.Suspended,
+			Invited:   user.Invited,
+			Factors:   len(user.Factors),
+			Profile:   user.Profile,
+		}
+		if !user.LastLogin.IsZero() {
+			export[k].LastLogin = user.LastLogin.UTC().Format(time.RFC3339)
+		}
+	}
+	filename := "users-" + time.Now().UTC().Format("20060102-150405")
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		out, err := json.Marshal(export)
+		if err != nil {
+			c.returnError(w, fmt.Errorf("export marshal error: %s", err), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		c.write(w, out)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		sendCorsHeaders(w, "", c.Hostname, c.Protocol)
+		w.WriteHeader(http.StatusOK)
+		csvWriter := csv.NewWriter(w)
+		csvWriter.Write(userExportColumns)
+		for _, user := range export {
+			csvWriter.Write([]string{user.ID, user.Login, user.Role, user.Source, strconv.FormatBool(user.Suspended), strconv.FormatBool(user.Invited), user.LastLogin, strconv.Itoa(user.Factors), user.DisplayName, user.GivenName, user.FamilyName, user.Email, user.Locale})
+		}
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			logging.ErrorLog(fmt.Errorf("users export error: %s", err))
+		}
+	default:
+		c.returnError(w, fmt.Errorf("unknown format"), http.StatusBadRequest)
+	}
+}
+
+// userSource returns how the user is provisioned: scim, saml, oidc or local
+func userSource(user users.User) string {
+	switch {
+	case user.Provisioned:
+		return "scim"
+	case user.SAMLID != "":
+		return "saml"
+	case user.OIDCID != "":
+		return "oidc"
+	}
+	return "local"
+}
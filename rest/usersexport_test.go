@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func TestUsersExportHandler(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	_, err = c.UserStore.AddUser(users.User{Login: "john", Password: "mypassword", Role: users.ROLE_ADMIN, Factors: []users.Factor{{Name: "phone", Type: "totp", Secret: "SECRETSECRET"}}, Profile: users.Profile{Email: "john@example.inv"}})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	_, err = c.UserStore.AddUser(users.User{Login: "jane", Role: users.ROLE_USER, SAMLID: "saml-id", Suspended: true})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+
+	w := httptest.NewRecorder()
+	c.usersExportHandler(w, httptest.NewRequest("GET", "http://example.com/api/users/export", nil))
+	if strings.Contains(w.Body.String(), "SECRETSECRET") || strings.Contains(w.Body.String(), "argon2") {
+		t.Fatalf("secrets shouldn't be exported: %s", w.Body.String())
+	}
+	var export []UserExport
+	err = json.NewDecoder(w.Body).Decode(&export)
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if len(export) != 2 || export[0].Factors != 1 || export[0].Source != "local" || export[0].Email != "john@example.inv" || export[1].Source != "saml" || !export[1].Suspended {
+		t.Fatalf("unexpected export: %+v", export)
+	}
+
+	w = httptest.NewRecorder()
+	c.usersExportHandler(w, httptest.NewRequest("GET", "http://example.com/api/users/export?format=csv", nil))
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("csv error: %s", err)
+	}
+	if len(records) != 3 || records[0][1] != "login" || records[1][1] != "john" || records[2][3] != "saml" {
+		t.Fatalf("unexpected csv: %v", records)
+	}
+	// the export can be imported again
+	importRequest, err := readImportCSV(strings.NewReader(strings.Join([]string{strings.Join(records[0], ","), strings.Join(records[1], ",")}, "\n")))
+	if err != nil {
+		t.Fatalf("read import csv error: %s", err)
+	}
+	if len(importRequest) != 1 || importRequest[0].Login != "john" || importRequest[0].Email != "john@example.inv" {
+		t.Fatalf("unexpected import: %+v", importRequest)
+	}
+}
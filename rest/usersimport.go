@@ -0,0 +1,210 @@
+package rest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/in4it/go-devops-platform/users"
+)
+
+const (
+	IMPORT_ACTION_CREATE = "create"
+	IMPORT_ACTION_UPDATE = "update"
+)
+
+// usersImportHandler imports local users from JSON, or from CSV when the Content-Type is text/csv. Passwords can be supplied in plain text or as a hash from another system (see users.HashFormat).
+// Every row is validated first: when one row fails, nothing is imported and the errors are returned per row.
+// With dryRun=true the rows are only validated. With mode=upsert, users that already exist are updated.
+func (c *Context) usersImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.returnError(w, fmt.Errorf("method not supported"), http.StatusBadRequest)
+		return
+	}
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	upsert := r.URL.Query().Get("mode") == "upsert"
+	var importRequest []ImportUserRequest
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		importRequest, err = readImportCSV(r.Body)
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&importRequest)
+	}
+	if err != nil {
+		c.returnError(w, fmt.Errorf("decode input error: %s", err), http.StatusBadRequest)
+		return
+	}
+	if len(importRequest) == 0 {
+		c.returnError(w, fmt.Errorf("no users to import"), http.StatusBadRequest)
+		return
+	}
+
+	response := ImportUsersResponse{DryRun: dryRun, Rows: make([]ImportUserResponse, len(importRequest))}
+	newUsers := make([]users.User, len(importRequest))
+	rows := make(map[string]int) // login => row
+	for k, user := range importRequest {
+		row := &response.Rows[k]
+		*row = ImportUserResponse{Row: k + 1, Login: user.Login, Role: user.Role, Action: IMPORT_ACTION_CREATE}
+		existingUser, err := c.UserStore.GetUserByLogin(user.Login)
+		exists := err == nil
+		if exists {
+			row.ID = existingUser.ID
+			row.Action = IMPORT_ACTION_UPDATE
+			if row.Role == "" {
+				row.Role = existingUser.Role
+			}
+		} else if row.Role == "" {
+			row.Role = users.ROLE_USER
+		}
+		if duplicateRow, ok := rows[user.Login]; ok && user.Login != "" {
+			row.Error = fmt.Sprintf("duplicate login, also on row %d", duplicateRow)
+			continue
+		}
+		rows[user.Login] = row.Row
+		if err := c.validateImportRow(r, user, row.Role, existingUser, exists, upsert); err != nil {
+			row.Error = err.Error()
+			continue
+		}
+		newUsers[k] = users.User{
+			Login:        user.Login,
+			Role:         row.Role,
+			Password:     user.Password,
+			PasswordHash: user.PasswordHash,
+			Profile:      user.Profile,
+		}
+		if exists {
+			response.Updated++
+		} else {
+			response.Created++
+		}
+	}
+	if c.UserStore.UserCount()+response.Created > c.LicenseUserCount {
+		response.Error = fmt.Sprintf("not enough licenses available to import %d users", response.Created)
+	}
+	failed := response.Error != "" || slices.ContainsFunc(response.Rows, func(row ImportUserResponse) bool { return row.Error != "" })
+	if failed || dryRun {
+		if failed {
+			response.Created, response.Updated = 0, 0
+		}
+		c.writeImportResponse(w, response, failed)
+		return
+	}
+
+	var importedUsers []users.User
+	if upsert {
+		importedUsers, err = c.UserStore.UpsertUsers(newUsers)
+	} else {
+		importedUsers, err = c.UserStore.AddUsers(newUsers)
+	}
+	if err != nil {
+		c.returnError(w, fmt.Errorf("import error: %s", err), http.StatusBadRequest)
+		return
+	}
+	for _, user := range importedUsers {
+		response.Rows[rows[user.Login]-1].ID = user.ID
+	}
+	c.writeImportResponse(w, response, false)
+}
+
+// validateImportRow checks a row of the import. The role is the role the user will have after the import.
+func (c *Context) validateImportRow(r *http.Request, user ImportUserRequest, role string, existingUser users.User, exists, upsert bool) error {
+	if user.Login == "" {
+		return fmt.Errorf("login is empty")
+	}
+	if !isAlphaNumeric(user.Login) {
+		return fmt.Errorf("login not valid")
+	}
+	if exists && !upsert {
+		return fmt.Errorf("user already exists")
+	}
+	if exists && existingUser.Provisioned {
+		return fmt.Errorf("user is provisioned by SCIM")
+	}
+	if exists {
+		if err := c.canChangeUser(r, existingUser); err != nil {
+			return err
+		}
+		if (user.Password != "" || user.PasswordHash != "") && (existingUser.OIDCID != "" || existingUser.SAMLID != "" || existingUser.Invited) {
+			return fmt.Errorf("password can't be set for users of an identity provider or with a pending invitation")
+		}
+	}
+	if user.Password != "" && user.PasswordHash != "" {
+		return fmt.Errorf("password and password hash can't be used together")
+	}
+	if user.PasswordHash != "" {
+		if _, err := users.HashFormat(user.PasswordHash); err != nil {
+			return err
+		}
+	}
+	if user.Password != "" {
+		if err := c.UserStore.CheckPassword(user.Password); err != nil {
+			return err
+		}
+	}
+	if role != existingUser.Role {
+		if err := c.canAssignRole(r, role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Context) writeImportResponse(w http.ResponseWriter, response ImportUsersResponse, failed bool) {
+	out, err := json.Marshal(response)
+	if err != nil {
+		c.returnError(w, fmt.Errorf("import response marshal error: %s", err), http.StatusBadRequest)
+		return
+	}
+	if failed {
+		c.writeWithStatus(w, out, http.StatusBadRequest)
+		return
+	}
+	c.write(w, out)
+}
+
+// readImportCSV reads users from a CSV file with a header. The columns of the export that can't be imported (e.g. id) are ignored.
+func readImportCSV(r io.Reader) ([]ImportUserRequest, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("csv error: %s", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("csv header missing")
+	}
+	header := records[0]
+	for _, column := range header {
+		if !slices.Contains(userExportColumns, column) && column != "password" && column != "passwordHash" {
+			return nil, fmt.Errorf("unknown column: %s", column)
+		}
+	}
+	importRequest := make([]ImportUserRequest, len(records)-1)
+	for k, record := range records[1:] {
+		for i, value := range record {
+			switch header[i] {
+			case "login":
+				importRequest[k].Login = value
+			case "role":
+				importRequest[k].Role = value
+			case "password":
+				importRequest[k].Password = value
+			case "passwordHash":
+				importRequest[k].PasswordHash = value
+			case "displayName":
+				importRequest[k].DisplayName = value
+			case "givenName":
+				importRequest[k].GivenName = value
+			case "familyName":
+				importRequest[k].FamilyName = value
+			case "email":
+				importRequest[k].Email = value
+			case "locale":
+				importRequest[k].Locale = value
+			}
+		}
+	}
+	return importRequest, nil
+}
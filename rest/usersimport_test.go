@@ -0,0 +1,156 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func TestUsersImportHandler(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	admin := users.User{Login: "admin", Role: users.ROLE_ADMIN}
+	importUsers := func(payload string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "http://example.com/api/users/import", bytes.NewBufferString(payload))
+		w := httptest.NewRecorder()
+		c.usersImportHandler(w, req.WithContext(context.WithValue(req.Context(), CustomValue("user"), admin)))
+		return w
+	}
+
+	w := importUsers(`[{"login": "john", "passwordHash": "$1$md5$unsupported"}]`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unsupported hash, got: %d", w.Code)
+	}
+	w = importUsers(`[{"login": "john", "password": "secret", "passwordHash": "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"}]`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when password and hash are set, got: %d", w.Code)
+	}
+	w = importUsers(`[
+		{"login": "john", "givenName": "John", "passwordHash": "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+		{"login": "jane", "role": "admin", "password": "secret"}
+	]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "$6$") {
+		t.Fatalf("password hash shouldn't be in the response")
+	}
+	var response ImportUsersResponse
+	err = json.NewDecoder(w.Body).Decode(&response)
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if len(response.Rows) != 2 || response.Rows[0].Role != users.ROLE_USER || response.Rows[1].Role != users.ROLE_ADMIN || response.Created != 2 || response.Rows[0].ID == "" {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+	if _, ok := c.UserStore.AuthUser("john", "Hello world!"); !ok {
+		t.Fatalf("expected login with imported hash to succeed")
+	}
+	if _, ok := c.UserStore.AuthUser("jane", "secret"); !ok {
+		t.Fatalf("expected login with imported password to succeed")
+	}
+	user, err := c.UserStore.GetUserByLogin("john")
+	if err != nil || user.GivenName != "John" {
+		t.Fatalf("expected profile to be imported: %+v (%v)", user.Profile, err)
+	}
+}
+
+func TestUsersImportRowErrors(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	c.LicenseUserCount = 3
+	_, err = c.UserStore.AddUser(users.User{Login: "john", Password: "oldpassword", Role: users.ROLE_USER})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	importUsers := func(query, contentType, payload string) ImportUsersResponse {
+		req := httptest.NewRequest("POST", "http://example.com/api/users/import"+query, bytes.NewBufferString(payload))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		c.usersImportHandler(w, req.WithContext(context.WithValue(req.Context(), CustomValue("user"), users.User{Login: "admin", Role: users.ROLE_ADMIN})))
+		var response ImportUsersResponse
+		err := json.NewDecoder(w.Body).Decode(&response)
+		if err != nil {
+			t.Fatalf("decode error: %s", err)
+		}
+		return response
+	}
+
+	response := importUsers("", "application/json", `[{"login": "john"}, {"login": "jane@"}, {"login": "bob"}, {"login": "bob"}]`)
+	if response.Rows[0].Error != "user already exists" || response.Rows[1].Error != "login not valid" || response.Rows[2].Error != "" || response.Rows[3].Error == "" {
+		t.Fatalf("unexpected row errors: %+v", response.Rows)
+	}
+	if c.UserStore.LoginExists("bob") {
+		t.Fatalf("nothing should be imported when a row fails")
+	}
+
+	// the license is checked against the users that are created
+	response = importUsers("?mode=upsert", "application/json", `[{"login": "jane"}, {"login": "bob"}, {"login": "alice"}]`)
+	if response.Error == "" {
+		t.Fatalf("expected license error")
+	}
+
+	csvPayload := "login,role,password,givenName,factors\njohn,admin,newpassword,John,0\njane,,,Jane,0\n"
+	response = importUsers("?mode=upsert&dryRun=true", "text/csv", csvPayload)
+	if !response.DryRun || response.Created != 1 || response.Updated != 1 || response.Rows[0].Action != IMPORT_ACTION_UPDATE || response.Rows[1].Role != users.ROLE_USER {
+		t.Fatalf("unexpected dry run response: %+v", response)
+	}
+	if c.UserStore.LoginExists("jane") {
+		t.Fatalf("dry run shouldn't import users")
+	}
+	response = importUsers("?mode=upsert", "text/csv", csvPayload)
+	if response.Created != 1 || response.Updated != 1 || response.Rows[1].ID == "" {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+	john, ok := c.UserStore.AuthUser("john", "newpassword")
+	if !ok || john.Role != users.ROLE_ADMIN || john.GivenName != "John" {
+		t.Fatalf("expected john to be updated: %+v", john)
+	}
+	jane, err := c.UserStore.GetUserByLogin("jane")
+	if err != nil || jane.GivenName != "Jane" {
+		t.Fatalf("expected jane to be created: %+v (%v)", jane, err)
+	}
+}
+
+func TestUsersImportUpsertPermissions(t *testing.T) {
+	c, err := newContext(&memorystorage.MockMemoryStorage{}, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	c.Roles = append(c.Roles, users.Role{Name: "helpdesk", Permissions: []users.Permission{users.PermissionUsersRead, users.PermissionUsersWrite}})
+	_, err = c.UserStore.AddUsers([]users.User{
+		{Login: "admin2", Password: "oldpassword", Role: users.ROLE_ADMIN},
+		{Login: "oidcuser", Role: users.ROLE_USER, OIDCID: "123"},
+		{Login: "invited", Role: users.ROLE_USER, Invited: true},
+	})
+	if err != nil {
+		t.Fatalf("add users error: %s", err)
+	}
+	for _, payload := range []string{
+		`[{"login": "admin2", "role": "admin", "password": "takeover"}]`,
+		`[{"login": "admin2", "givenName": "Admin"}]`,
+		`[{"login": "oidcuser", "password": "newpassword"}]`,
+		`[{"login": "invited", "password": "newpassword"}]`,
+	} {
+		req := httptest.NewRequest("POST", "http://example.com/api/users/import?mode=upsert", bytes.NewBufferString(payload))
+		w := httptest.NewRecorder()
+		c.usersImportHandler(w, req.WithContext(context.WithValue(req.Context(), CustomValue("user"), users.User{Login: "helpdesk", Role: "helpdesk"})))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected import of %s to fail, got: %d (%s)", payload, w.Code, w.Body.String())
+		}
+	}
+	if _, ok := c.UserStore.AuthUser("admin2", "oldpassword"); !ok {
+		t.Fatalf("expected admin password to be unchanged")
+	}
+}
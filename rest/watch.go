@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	oidcstore "github.com/in4it/go-devops-platform/auth/oidc/store"
+	"github.com/in4it/go-devops-platform/logging"
+	"github.com/in4it/go-devops-platform/storage"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+// watchConfig reloads config.json, users.json, groups.json and the oidc store when they are changed by another instance, until ctx is done
+func watchConfig(ctx context.Context, c *Context) {
+	watcher, ok := c.Storage.Client.(storage.Watcher)
+	if !ok {
+		logging.DebugLog(fmt.Errorf("storage doesn't support watching for changes. Config is only reloaded on SIGHUP"))
+		return
+	}
+	events, err := watcher.Watch(ctx, c.Storage.Client.ConfigPath(""))
+	if err != nil {
+		logging.ErrorLog(fmt.Errorf("could not watch for config changes: %s", err))
+		return
+	}
+	for event := range events {
+		if event.Type != storage.EventWrite {
+			continue
+		}
+		err := c.handleConfigEvent(event)
+		if err != nil {
+			logging.ErrorLog(fmt.Errorf("could not reload %s: %s", event.Name, err))
+		}
+	}
+}
+
+func (c *Context) handleConfigEvent(event storage.Event) error {
+	var (
+		reloaded bool
+		err      error
+	)
+	switch path.Clean(event.Name) {
+	case c.Storage.Client.ConfigPath("config.json"):
+		reloaded, err = c.reloadConfig()
+	case c.Storage.Client.ConfigPath(users.USERSTORE_FILENAME):
+		reloaded, err = c.UserStore.Reload()
+	case c.Storage.Client.ConfigPath(users.GROUPSTORE_FILENAME):
+		reloaded, err = c.UserStore.Groups().Reload()
+	case c.Storage.Client.ConfigPath(oidcstore.DEFAULT_PATH):
+		reloaded, err = c.OIDCStore.Reload()
+	}
+	if err != nil {
+		return err
+	}
+	if reloaded {
+		logging.InfoLog(fmt.Sprintf("%s changed in storage: reloaded", event.Name))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"github.com/in4it/go-devops-platform/users"
+)
+
+func TestHandleConfigEvent(t *testing.T) {
+	storageClient := &memorystorage.MockMemoryStorage{}
+	events, err := storageClient.Watch(context.Background(), storageClient.ConfigPath(""))
+	if err != nil {
+		t.Fatalf("watch error: %s", err)
+	}
+	c1, err := newContext(storageClient, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	c2, err := newContext(storageClient, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+
+	// changes from another instance are reloaded
+	c2.Hostname = "vpn.example.com"
+	c2.LocalAuthDisabled = true
+	err = SaveConfig(c2)
+	if err != nil {
+		t.Fatalf("save config error: %s", err)
+	}
+	_, err = c2.UserStore.AddUser(users.User{Login: "john", Password: "mypass"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	handleEvents(t, c1, events)
+	if c1.Hostname != "vpn.example.com" || !c1.LocalAuthDisabled {
+		t.Fatalf("config not reloaded: hostname %s, local auth disabled: %v", c1.Hostname, c1.LocalAuthDisabled)
+	}
+	if !c1.UserStore.LoginExists("john") {
+		t.Fatalf("users not reloaded")
+	}
+
+	// own changes don't trigger a reload
+	c1.Hostname = "vpn2.example.com"
+	err = SaveConfig(c1)
+	if err != nil {
+		t.Fatalf("save config error: %s", err)
+	}
+	reloaded, err := c1.reloadConfig()
+	if err != nil {
+		t.Fatalf("reload config error: %s", err)
+	}
+	if reloaded {
+		t.Fatalf("expected no reload after own save")
+	}
+}
+
+func handleEvents(t *testing.T, c *Context, events <-chan storage.Event) {
+	for {
+		select {
+		case event := <-events:
+			err := c.handleConfigEvent(event)
+			if err != nil {
+				t.Fatalf("handle config event error: %s", err)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func TestReloadConfigWaitsForRequests(t *testing.T) {
+	storageClient := &memorystorage.MockMemoryStorage{}
+	c, err := newContext(storageClient, SERVER_TYPE_VPN)
+	if err != nil {
+		t.Fatalf("Cannot create context: %s", err)
+	}
+	inRequest := make(chan struct{})
+	finishRequest := make(chan struct{})
+	var hostnames []string
+	handler := c.configLockMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hostnames = append(hostnames, c.Hostname)
+		close(inRequest)
+		<-finishRequest
+		hostnames = append(hostnames, c.Hostname)
+	}))
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/api/context", nil))
+	<-inRequest
+
+	other, err := GetConfig(storageClient) // another instance changes config.json
+	if err != nil {
+		t.Fatalf("get config error: %s", err)
+	}
+	other.Storage = &Storage{Client: storageClient}
+	other.Hostname = "vpn.example.com"
+	if err := SaveConfig(other); err != nil {
+		t.Fatalf("save config error: %s", err)
+	}
+	reloaded := make(chan error)
+	go func() {
+		_, err := c.reloadConfig()
+		reloaded <- err
+	}()
+	select {
+	case <-reloaded:
+		t.Fatalf("expected the reload to wait for the request")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(finishRequest)
+	if err := <-reloaded; err != nil {
+		t.Fatalf("reload config error: %s", err)
+	}
+	if hostnames[0] != hostnames[1] || c.Hostname != "vpn.example.com" {
+		t.Fatalf("unexpected hostnames during the request %v, after: %s", hostnames, c.Hostname)
+	}
+
+	// apps release the lock before their handler runs
+	appHandler := c.configLockMiddleware(configUnlockMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := c.reloadConfig(); err != nil {
+			t.Errorf("reload config error: %s", err)
+		}
+	})))
+	done := make(chan struct{})
+	go func() {
+		appHandler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/api/app/", nil))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("reload blocked by an app request")
+	}
+}
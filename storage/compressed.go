@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// COMPRESSED_SUFFIX is the suffix of files that were compressed in place, e.g. by the audit log retention policy.
+// OpenFilesFromPos reads name+COMPRESSED_SUFFIX when name doesn't exist, so readers keep working after compression.
+const COMPRESSED_SUFFIX = ".gz"
+
+// OpenCompressedFromPos decompresses the data of a compressed file and returns a reader starting at pos.
+// When pos is past the end, the reader is nil and the remaining pos is returned, like OpenFilesFromPos skips files.
+func OpenCompressedFromPos(name string, data []byte, pos int64) (io.ReadCloser, int64, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, pos, fmt.Errorf("gzip error (%s): %s", name, err)
+	}
+	uncompressed, err := io.ReadAll(gzipReader)
+	if err != nil {
+		return nil, pos, fmt.Errorf("gzip read error (%s): %s", name, err)
+	}
+	if int64(len(uncompressed)) <= pos {
+		return nil, pos - int64(len(uncompressed)), nil
+	}
+	return io.NopCloser(bytes.NewReader(uncompressed[pos:])), 0, nil
+}
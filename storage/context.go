@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+)
+
+// ContextIface is the storage interface with a context, to pass deadlines and cancellation to the storage backend.
+// Use WithContext to get it for any storage.
+type ContextIface interface {
+	ReadFileContext(ctx context.Context, name string) ([]byte, error)
+	WriteFileContext(ctx context.Context, name string, data []byte) error
+	AppendFileContext(ctx context.Context, name string, data []byte) error
+	FileExistsContext(ctx context.Context, filename string) bool
+	ReadDirContext(ctx context.Context, name string) ([]string, error)
+	RemoveContext(ctx context.Context, name string) error
+	RenameContext(ctx context.Context, oldName, newName string) error
+	FileInfoContext(ctx context.Context, name string) (fs.FileInfo, error)
+	OpenFileContext(ctx context.Context, name string) (io.ReadCloser, error)
+	OpenFilesFromPosContext(ctx context.Context, names []string, pos int64) ([]io.ReadCloser, error)
+}
+
+// WithContext returns the storage as ContextIface. Storage that doesn't support a context natively is wrapped:
+// the context is checked before every call, but a call that already started can't be interrupted.
+func WithContext(storage Iface) ContextIface {
+	if contextStorage, ok := storage.(ContextIface); ok {
+		return contextStorage
+	}
+	return contextAdapter{storage: storage}
+}
+
+type contextAdapter struct {
+	storage Iface
+}
+
+func (c contextAdapter) ReadFileContext(ctx context.Context, name string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.storage.ReadFile(name)
+}
+
+func (c contextAdapter) WriteFileContext(ctx context.Context, name string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.storage.WriteFile(name, data)
+}
+
+func (c contextAdapter) AppendFileContext(ctx context.Context, name string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.storage.AppendFile(name, data)
+}
+
+func (c contextAdapter) FileExistsContext(ctx context.Context, filename string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return c.storage.FileExists(filename)
+}
+
+func (c contextAdapter) ReadDirContext(ctx context.Context, name string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.storage.ReadDir(name)
+}
+
+func (c contextAdapter) RemoveContext(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.storage.Remove(name)
+}
+
+func (c contextAdapter) RenameContext(ctx context.Context, oldName, newName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.storage.Rename(oldName, newName)
+}
+
+func (c contextAdapter) FileInfoContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.storage.FileInfo(name)
+}
+
+func (c contextAdapter) OpenFileContext(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.storage.OpenFile(name)
+}
+
+func (c contextAdapter) OpenFilesFromPosContext(ctx context.Context, names []string, pos int64) ([]io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.storage.OpenFilesFromPos(names, pos)
+}
@@ -0,0 +1,148 @@
+package encryptedstorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+// inner returns the inner storage with context support
+func (e *EncryptedStorage) inner() storage.ContextIface {
+	return storage.WithContext(e.storage)
+}
+
+func (e *EncryptedStorage) ReadDirContext(ctx context.Context, name string) ([]string, error) {
+	return e.inner().ReadDirContext(ctx, name)
+}
+
+func (e *EncryptedStorage) RemoveContext(ctx context.Context, name string) error {
+	return e.inner().RemoveContext(ctx, name)
+}
+
+func (e *EncryptedStorage) RenameContext(ctx context.Context, oldName, newName string) error {
+	return e.inner().RenameContext(ctx, oldName, newName)
+}
+
+func (e *EncryptedStorage) FileExistsContext(ctx context.Context, filename string) bool {
+	return e.inner().FileExistsContext(ctx, filename)
+}
+
+// FileInfoContext returns the file info of the inner storage, with the size of the decrypted contents
+func (e *EncryptedStorage) FileInfoContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	fileInfo, err := e.inner().FileInfoContext(ctx, name)
+	if err != nil || fileInfo.IsDir() {
+		return fileInfo, err
+	}
+	data, err := e.inner().ReadFileContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !isEncrypted(data) {
+		return fileInfo, nil
+	}
+	size, err := plaintextSize(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return fileInfoWithSize{FileInfo: fileInfo, size: size}, nil
+}
+
+// ReadFileContext decrypts the file. Plaintext files (not yet migrated) are returned as-is.
+func (e *EncryptedStorage) ReadFileContext(ctx context.Context, name string) ([]byte, error) {
+	data, err := e.inner().ReadFileContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !isEncrypted(data) {
+		return data, nil
+	}
+	plaintext, err := e.keyring.decrypt(data)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt error (%s): %s", name, err)
+	}
+	return plaintext, nil
+}
+
+func (e *EncryptedStorage) WriteFileContext(ctx context.Context, name string, data []byte) error {
+	if !e.filter(name) {
+		return e.inner().WriteFileContext(ctx, name, data)
+	}
+	encrypted, err := e.keyring.encrypt(data)
+	if err != nil {
+		return fmt.Errorf("encrypt error (%s): %s", name, err)
+	}
+	return e.inner().WriteFileContext(ctx, name, encrypted)
+}
+
+// AppendFileContext decrypts, appends and encrypts the file again
+func (e *EncryptedStorage) AppendFileContext(ctx context.Context, name string, data []byte) error {
+	if !e.filter(name) {
+		return e.inner().AppendFileContext(ctx, name, data)
+	}
+	existing, err := e.readFileIfExists(ctx, name)
+	if err != nil {
+		return err
+	}
+	newData := make([]byte, 0, len(existing)+len(data))
+	newData = append(newData, existing...)
+	return e.WriteFileContext(ctx, name, append(newData, data...))
+}
+
+func (e *EncryptedStorage) OpenFileContext(ctx context.Context, name string) (io.ReadCloser, error) {
+	data, err := e.ReadFileContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (e *EncryptedStorage) OpenFilesFromPosContext(ctx context.Context, names []string, pos int64) ([]io.ReadCloser, error) {
+	readers := []io.ReadCloser{}
+	if pos < 0 {
+		return readers, nil
+	}
+	for _, name := range names {
+		data, err := e.ReadFileContext(ctx, name)
+		if errors.Is(err, fs.ErrNotExist) && e.inner().FileExistsContext(ctx, name+storage.COMPRESSED_SUFFIX) {
+			data, err = e.ReadFileContext(ctx, name+storage.COMPRESSED_SUFFIX)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read file (%s): %s", name+storage.COMPRESSED_SUFFIX, err)
+			}
+			var reader io.ReadCloser
+			reader, pos, err = storage.OpenCompressedFromPos(name+storage.COMPRESSED_SUFFIX, data, pos)
+			if err != nil {
+				return nil, err
+			}
+			if reader != nil {
+				readers = append(readers, reader)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot open file (%s): %s", name, err)
+		}
+		if int64(len(data)) <= pos {
+			pos -= int64(len(data))
+			continue
+		}
+		readers = append(readers, io.NopCloser(bytes.NewReader(data[pos:])))
+		pos = 0
+	}
+	return readers, nil
+}
+
+func (e *EncryptedStorage) readFileIfExists(ctx context.Context, name string) ([]byte, error) {
+	if !e.inner().FileExistsContext(ctx, name) {
+		return []byte{}, nil
+	}
+	data, err := e.ReadFileContext(ctx, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return data, nil
+}
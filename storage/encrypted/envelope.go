@@ -0,0 +1,180 @@
+package encryptedstorage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// file format: magic | keyID length (1 byte) | keyID | wrapped data key length (2 bytes) | wrapped data key | nonce | ciphertext
+var magic = []byte("GDPENC01")
+
+type envelope struct {
+	keyID      string
+	wrappedKey []byte
+}
+
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+func (k *Keyring) encrypt(plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("could not generate data key: %s", err)
+	}
+	wrappedKey, err := seal(k.current(), dataKey, []byte(k.currentID))
+	if err != nil {
+		return nil, fmt.Errorf("could not wrap data key: %s", err)
+	}
+	e := envelope{keyID: k.currentID, wrappedKey: wrappedKey}
+	header := e.header()
+	sealed, err := seal(dataKey, plaintext, header)
+	if err != nil {
+		return nil, fmt.Errorf("could not encrypt data: %s", err)
+	}
+	return append(header, sealed...), nil
+}
+
+func (k *Keyring) decrypt(data []byte) ([]byte, error) {
+	e, headerLen, err := parseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := k.unwrap(e)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(dataKey, data[headerLen:], data[:headerLen])
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt data: %s", err)
+	}
+	return plaintext, nil
+}
+
+// rewrap encrypts the data key with the current key. The data key itself doesn't change.
+func (k *Keyring) rewrap(data []byte) ([]byte, bool, error) {
+	e, headerLen, err := parseEnvelope(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if e.keyID == k.currentID {
+		return data, false, nil
+	}
+	dataKey, err := k.unwrap(e)
+	if err != nil {
+		return nil, false, err
+	}
+	plaintext, err := open(dataKey, data[headerLen:], data[:headerLen])
+	if err != nil {
+		return nil, false, fmt.Errorf("could not decrypt data: %s", err)
+	}
+	wrappedKey, err := seal(k.current(), dataKey, []byte(k.currentID))
+	if err != nil {
+		return nil, false, fmt.Errorf("could not wrap data key: %s", err)
+	}
+	newEnvelope := envelope{keyID: k.currentID, wrappedKey: wrappedKey}
+	header := newEnvelope.header()
+	// the header is authenticated, so the contents are sealed again with the same data key
+	sealed, err := seal(dataKey, plaintext, header)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not encrypt data: %s", err)
+	}
+	return append(header, sealed...), true, nil
+}
+
+func (k *Keyring) unwrap(e envelope) ([]byte, error) {
+	masterKey, err := k.get(e.keyID)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := open(masterKey, e.wrappedKey, []byte(e.keyID))
+	if err != nil {
+		return nil, fmt.Errorf("could not unwrap data key (key id: %s): %s", e.keyID, err)
+	}
+	return dataKey, nil
+}
+
+func (e envelope) header() []byte {
+	header := bytes.NewBuffer(append([]byte{}, magic...))
+	header.WriteByte(byte(len(e.keyID)))
+	header.WriteString(e.keyID)
+	binary.Write(header, binary.BigEndian, uint16(len(e.wrappedKey)))
+	header.Write(e.wrappedKey)
+	return header.Bytes()
+}
+
+func parseEnvelope(data []byte) (envelope, int, error) {
+	var e envelope
+	if !isEncrypted(data) {
+		return e, 0, fmt.Errorf("data is not encrypted")
+	}
+	pos := len(magic)
+	if len(data) < pos+1 {
+		return e, 0, fmt.Errorf("invalid envelope: too short")
+	}
+	keyIDLen := int(data[pos])
+	pos++
+	if len(data) < pos+keyIDLen+2 {
+		return e, 0, fmt.Errorf("invalid envelope: too short")
+	}
+	e.keyID = string(data[pos : pos+keyIDLen])
+	pos += keyIDLen
+	wrappedKeyLen := int(binary.BigEndian.Uint16(data[pos : pos+2]))
+	pos += 2
+	if len(data) < pos+wrappedKeyLen {
+		return e, 0, fmt.Errorf("invalid envelope: too short")
+	}
+	e.wrappedKey = data[pos : pos+wrappedKeyLen]
+	pos += wrappedKeyLen
+	return e, pos, nil
+}
+
+// seal encrypts with AES-GCM, and prepends the nonce
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("could not generate nonce: %s", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("cipher error: %s", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// plaintextSize returns the size of the decrypted contents
+func plaintextSize(data []byte) (int64, error) {
+	_, headerLen, err := parseEnvelope(data)
+	if err != nil {
+		return 0, err
+	}
+	size := len(data) - headerLen - 12 - 16 // nonce and GCM tag
+	if size < 0 {
+		return 0, fmt.Errorf("invalid envelope: too short")
+	}
+	return int64(size), nil
+}
@@ -0,0 +1,69 @@
+package encryptedstorage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// NewKeyring returns a keyring. New files are encrypted with the key matching currentID, older keys are only used to decrypt.
+func NewKeyring(currentID string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[currentID]; !ok {
+		return nil, fmt.Errorf("current key id not found in keys: %s", currentID)
+	}
+	for keyID, key := range keys {
+		if len(keyID) == 0 || len(keyID) > 255 {
+			return nil, fmt.Errorf("invalid key id length: %s", keyID)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %s: key needs to be 32 bytes (AES-256)", keyID)
+		}
+	}
+	return &Keyring{
+		keys:      keys,
+		currentID: currentID,
+	}, nil
+}
+
+// ParseKeyring parses keys in the format "keyID:base64key", separated by newlines or commas.
+// The last key is the current key. A single key without key id gets the id "default".
+func ParseKeyring(input string) (*Keyring, error) {
+	keys := make(map[string][]byte)
+	currentID := ""
+	for _, line := range strings.FieldsFunc(input, func(r rune) bool { return r == '\n' || r == ',' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keyID, encodedKey, found := strings.Cut(line, ":")
+		if !found {
+			keyID, encodedKey = DEFAULT_KEY_ID, line
+		}
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: base64 decode error: %s", keyID, err)
+		}
+		keys[keyID] = key
+		currentID = keyID
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys found")
+	}
+	return NewKeyring(currentID, keys)
+}
+
+func (k *Keyring) CurrentID() string {
+	return k.currentID
+}
+
+func (k *Keyring) current() []byte {
+	return k.keys[k.currentID]
+}
+
+func (k *Keyring) get(keyID string) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("key with id %s not found in keyring", keyID)
+	}
+	return key, nil
+}
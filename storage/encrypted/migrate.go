@@ -0,0 +1,63 @@
+package encryptedstorage
+
+import (
+	"fmt"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+// MigratePlaintext encrypts the existing plaintext files under root. Returns the number of encrypted files.
+func (e *EncryptedStorage) MigratePlaintext(root string) (int, error) {
+	migrated := 0
+	err := storage.Walk(e.storage, root, func(name string) error {
+		if !e.filter(name) {
+			return nil
+		}
+		data, err := e.storage.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("read error (%s): %s", name, err)
+		}
+		if isEncrypted(data) {
+			return nil
+		}
+		encrypted, err := e.keyring.encrypt(data)
+		if err != nil {
+			return fmt.Errorf("encrypt error (%s): %s", name, err)
+		}
+		err = e.storage.WriteFile(name, encrypted)
+		if err != nil {
+			return fmt.Errorf("write error (%s): %s", name, err)
+		}
+		migrated++
+		return nil
+	})
+	return migrated, err
+}
+
+// RotateKeys wraps the data keys of files encrypted with an older key with the current key. Returns the number of rewrapped files.
+func (e *EncryptedStorage) RotateKeys(root string) (int, error) {
+	rotated := 0
+	err := storage.Walk(e.storage, root, func(name string) error {
+		data, err := e.storage.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("read error (%s): %s", name, err)
+		}
+		if !isEncrypted(data) {
+			return nil
+		}
+		rewrapped, changed, err := e.keyring.rewrap(data)
+		if err != nil {
+			return fmt.Errorf("rewrap error (%s): %s", name, err)
+		}
+		if !changed { // already using the current key
+			return nil
+		}
+		err = e.storage.WriteFile(name, rewrapped)
+		if err != nil {
+			return fmt.Errorf("write error (%s): %s", name, err)
+		}
+		rotated++
+		return nil
+	})
+	return rotated, err
+}
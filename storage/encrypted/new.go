@@ -0,0 +1,73 @@
+package encryptedstorage
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+// New returns storage that encrypts all files under the config path
+func New(inner storage.Iface, keyring *Keyring) *EncryptedStorage {
+	configPath := strings.TrimSuffix(inner.ConfigPath(""), "/") + "/"
+	return NewWithFilter(inner, keyring, func(name string) bool {
+		return strings.HasPrefix(name, configPath)
+	})
+}
+
+// NewWithFilter returns storage that encrypts the files for which filter returns true
+func NewWithFilter(inner storage.Iface, keyring *Keyring, filter func(name string) bool) *EncryptedStorage {
+	return &EncryptedStorage{
+		storage: inner,
+		keyring: keyring,
+		filter:  filter,
+	}
+}
+
+// NewFromEnvironment reads the master key(s) from STORAGE_ENCRYPTION_KEY or from the file in STORAGE_ENCRYPTION_KEY_FILE.
+// Existing plaintext files are encrypted, and files encrypted with an older key are rewrapped with the current key.
+// When no key is configured, the inner storage is returned.
+// The embedding app must wrap its storage with NewFromEnvironment before it is passed to users.NewUserStore and rest.NewContext,
+// which refuse unwrapped storage when a key is configured (see CheckWrapped).
+func NewFromEnvironment(inner storage.Iface) (storage.Iface, error) {
+	keys := os.Getenv(ENV_KEY)
+	if keyFile := os.Getenv(ENV_KEY_FILE); keyFile != "" {
+		keyFileContents, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read key file: %s", err)
+		}
+		keys = string(keyFileContents)
+	}
+	if keys == "" {
+		return inner, nil
+	}
+	keyring, err := ParseKeyring(keys)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse encryption keys: %s", err)
+	}
+	encryptedStorage := New(inner, keyring)
+	if _, err := encryptedStorage.MigratePlaintext(inner.ConfigPath("")); err != nil {
+		return nil, fmt.Errorf("plaintext migration error: %s", err)
+	}
+	if _, err := encryptedStorage.RotateKeys(inner.ConfigPath("")); err != nil {
+		return nil, fmt.Errorf("key rotation error: %s", err)
+	}
+	return encryptedStorage, nil
+}
+
+// KeyConfigured returns true when STORAGE_ENCRYPTION_KEY or STORAGE_ENCRYPTION_KEY_FILE is set
+func KeyConfigured() bool {
+	return os.Getenv(ENV_KEY) != "" || os.Getenv(ENV_KEY_FILE) != ""
+}
+
+// CheckWrapped returns an error when an encryption key is configured, but the storage is not encrypted, so files aren't written in plaintext by mistake
+func CheckWrapped(storageClient storage.Iface) error {
+	if !KeyConfigured() {
+		return nil
+	}
+	if _, ok := storageClient.(*EncryptedStorage); !ok {
+		return fmt.Errorf("%s or %s is set, but the storage is not wrapped with encryptedstorage.NewFromEnvironment", ENV_KEY, ENV_KEY_FILE)
+	}
+	return nil
+}
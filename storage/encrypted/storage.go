@@ -0,0 +1,147 @@
+package encryptedstorage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+func (e *EncryptedStorage) GetPath() string {
+	return e.storage.GetPath()
+}
+
+func (e *EncryptedStorage) EnsurePath(path string) error {
+	return e.storage.EnsurePath(path)
+}
+
+func (e *EncryptedStorage) EnsureOwnership(filename, login string) error {
+	return e.storage.EnsureOwnership(filename, login)
+}
+
+func (e *EncryptedStorage) ReadDir(name string) ([]string, error) {
+	return e.ReadDirContext(context.Background(), name)
+}
+
+func (e *EncryptedStorage) Remove(name string) error {
+	return e.RemoveContext(context.Background(), name)
+}
+
+func (e *EncryptedStorage) Rename(oldName, newName string) error {
+	return e.RenameContext(context.Background(), oldName, newName)
+}
+
+func (e *EncryptedStorage) EnsurePermissions(name string, mode fs.FileMode) error {
+	return e.storage.EnsurePermissions(name, mode)
+}
+
+func (e *EncryptedStorage) FileExists(filename string) bool {
+	return e.FileExistsContext(context.Background(), filename)
+}
+
+func (e *EncryptedStorage) ConfigPath(filename string) string {
+	return e.storage.ConfigPath(filename)
+}
+
+// FileInfo returns the file info of the inner storage, with the size of the decrypted contents
+func (e *EncryptedStorage) FileInfo(name string) (fs.FileInfo, error) {
+	return e.FileInfoContext(context.Background(), name)
+}
+
+// ReadFile decrypts the file. Plaintext files (not yet migrated) are returned as-is.
+func (e *EncryptedStorage) ReadFile(name string) ([]byte, error) {
+	return e.ReadFileContext(context.Background(), name)
+}
+
+func (e *EncryptedStorage) WriteFile(name string, data []byte) error {
+	return e.WriteFileContext(context.Background(), name, data)
+}
+
+// AppendFile decrypts, appends and encrypts the file again
+func (e *EncryptedStorage) AppendFile(name string, data []byte) error {
+	return e.AppendFileContext(context.Background(), name, data)
+}
+
+func (e *EncryptedStorage) OpenFile(name string) (io.ReadCloser, error) {
+	return e.OpenFileContext(context.Background(), name)
+}
+
+// OpenFileForWriting returns a writer that encrypts and writes the file on Close()
+func (e *EncryptedStorage) OpenFileForWriting(name string) (io.WriteCloser, error) {
+	if !e.filter(name) {
+		return e.storage.OpenFileForWriting(name)
+	}
+	return &encryptedWriter{storage: e, name: name}, nil
+}
+
+// OpenFileForAppending returns a writer that encrypts and writes the existing and new data on Close()
+func (e *EncryptedStorage) OpenFileForAppending(name string) (io.WriteCloser, error) {
+	if !e.filter(name) {
+		return e.storage.OpenFileForAppending(name)
+	}
+	existing, err := e.readFileIfExists(context.Background(), name)
+	if err != nil {
+		return nil, err
+	}
+	writer := &encryptedWriter{storage: e, name: name}
+	writer.buf.Write(existing)
+	return writer, nil
+}
+
+func (e *EncryptedStorage) OpenFilesFromPos(names []string, pos int64) ([]io.ReadCloser, error) {
+	return e.OpenFilesFromPosContext(context.Background(), names, pos)
+}
+
+type encryptedWriter struct {
+	storage *EncryptedStorage
+	name    string
+	buf     bytes.Buffer
+}
+
+func (w *encryptedWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *encryptedWriter) Close() error {
+	return w.storage.WriteFile(w.name, w.buf.Bytes())
+}
+
+type fileInfoWithSize struct {
+	fs.FileInfo
+	size int64
+}
+
+func (f fileInfoWithSize) Size() int64 {
+	return f.size
+}
+
+// Snapshots returns the snapshots of the inner storage (if supported)
+func (e *EncryptedStorage) Snapshots(name string) ([]string, error) {
+	snapshotter, ok := e.storage.(storage.Snapshotter)
+	if !ok {
+		return []string{}, nil
+	}
+	return snapshotter.Snapshots(name)
+}
+
+// Watch forwards to the inner storage (if supported)
+func (e *EncryptedStorage) Watch(ctx context.Context, prefix string) (<-chan storage.Event, error) {
+	watcher, ok := e.storage.(storage.Watcher)
+	if !ok {
+		return nil, fmt.Errorf("storage doesn't support watching for changes")
+	}
+	return watcher.Watch(ctx, prefix)
+}
+
+// Lock forwards to the inner storage. ErrLockNotSupported is returned when the inner storage doesn't support locking.
+func (e *EncryptedStorage) Lock(name string, ttl time.Duration) (storage.Lease, error) {
+	locker, ok := e.storage.(storage.Locker)
+	if !ok {
+		return nil, storage.ErrLockNotSupported
+	}
+	return locker.Lock(name, ttl)
+}
@@ -0,0 +1,19 @@
+package encryptedstorage
+
+import "github.com/in4it/go-devops-platform/storage"
+
+const ENV_KEY = "STORAGE_ENCRYPTION_KEY"
+const ENV_KEY_FILE = "STORAGE_ENCRYPTION_KEY_FILE"
+const DEFAULT_KEY_ID = "default"
+
+// EncryptedStorage encrypts file contents before passing them to the inner storage
+type EncryptedStorage struct {
+	storage storage.Iface
+	keyring *Keyring
+	filter  func(name string) bool
+}
+
+type Keyring struct {
+	keys      map[string][]byte
+	currentID string
+}
@@ -2,3 +2,7 @@ package localstorage
 
 const CONFIG_PATH = "config"
 const VPN_CLIENTS_DIR = "clients"
+const DEFAULT_SNAPSHOTS = 5
+const SNAPSHOT_SUFFIX = ".snapshot."
+
+var DEFAULT_SNAPSHOT_FILES = []string{"config.json", "users.json", "oidcstore.json"}
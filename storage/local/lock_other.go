@@ -0,0 +1,16 @@
+//go:build !unix
+
+package localstorage
+
+import (
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+var locks storage.LocalLocks
+
+// Lock only locks within this process on platforms without flock
+func (l *LocalStorage) Lock(name string, ttl time.Duration) (storage.Lease, error) {
+	return locks.Lock(name, ttl)
+}
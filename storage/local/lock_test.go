@@ -0,0 +1,41 @@
+package localstorage
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+func TestLock(t *testing.T) {
+	l, err := NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("new error: %s", err)
+	}
+	var holders, maxHolders atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := storage.WithLock(l, "counter", func() error {
+				current := holders.Add(1)
+				if current > maxHolders.Load() {
+					maxHolders.Store(current)
+				}
+				time.Sleep(time.Millisecond)
+				holders.Add(-1)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("with lock error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxHolders.Load() != 1 {
+		t.Fatalf("lock held by %d goroutines at the same time", maxHolders.Load())
+	}
+}
@@ -0,0 +1,42 @@
+//go:build unix
+
+package localstorage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+type flockLease struct {
+	file *os.File
+}
+
+// Lock uses flock on name.lock. The lock is released by the OS when the process exits, so the ttl is not used.
+func (l *LocalStorage) Lock(name string, ttl time.Duration) (storage.Lease, error) {
+	return storage.RetryLock(storage.DEFAULT_LOCK_TIMEOUT, func() (storage.Lease, error) {
+		file, err := os.OpenFile(path.Join(l.path, name+storage.LOCK_SUFFIX), os.O_CREATE|os.O_RDWR, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open lock file: %s", err)
+		}
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err != nil {
+			file.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("flock error: %s", err)
+		}
+		return &flockLease{file: file}, nil
+	})
+}
+
+func (f *flockLease) Unlock() error {
+	defer f.file.Close()
+	return syscall.Flock(int(f.file.Fd()), syscall.LOCK_UN)
+}
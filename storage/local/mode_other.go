@@ -0,0 +1,21 @@
+//go:build !unix
+
+package localstorage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// copyFileMode sets the mode of the existing file on the temporary file. New files get mode 0600.
+func copyFileMode(existing string, tmpFile *os.File) error {
+	info, err := os.Stat(existing)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return tmpFile.Chmod(info.Mode().Perm())
+}
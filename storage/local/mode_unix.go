@@ -0,0 +1,29 @@
+//go:build unix
+
+package localstorage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// copyFileMode sets the mode and owner of the existing file on the temporary file. New files get mode 0600.
+func copyFileMode(existing string, tmpFile *os.File) error {
+	info, err := os.Stat(existing)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil // os.CreateTemp uses 0600
+	}
+	if err != nil {
+		return err
+	}
+	if err := tmpFile.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || (int(stat.Uid) == os.Getuid() && int(stat.Gid) == os.Getgid()) {
+		return nil
+	}
+	return tmpFile.Chown(int(stat.Uid), int(stat.Gid))
+}
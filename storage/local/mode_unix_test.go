@@ -0,0 +1,41 @@
+//go:build unix
+
+package localstorage
+
+import (
+	"os"
+	"path"
+	"syscall"
+	"testing"
+)
+
+func TestWriteFileKeepsMode(t *testing.T) {
+	l, err := NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("new error: %s", err)
+	}
+	if err := l.WriteFile("key.pem", []byte("1")); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if err := l.EnsurePermissions("key.pem", 0640); err != nil {
+		t.Fatalf("ensure permissions error: %s", err)
+	}
+	if os.Getuid() == 0 {
+		if err := os.Chown(path.Join(l.GetPath(), "key.pem"), 65534, 65534); err != nil {
+			t.Fatalf("chown error: %s", err)
+		}
+	}
+	if err := l.WriteFile("key.pem", []byte("2")); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	info, err := os.Stat(path.Join(l.GetPath(), "key.pem"))
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Fatalf("expected mode 0640, got: %s", info.Mode().Perm())
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && os.Getuid() == 0 && stat.Uid != 65534 {
+		t.Fatalf("expected owner to be kept, got uid %d", stat.Uid)
+	}
+}
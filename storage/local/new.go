@@ -38,7 +38,13 @@ func New() (*LocalStorage, error) {
 }
 
 func NewWithPath(pathname string) (*LocalStorage, error) {
-	return &LocalStorage{
+	l := &LocalStorage{
 		path: pathname,
-	}, nil
+	}
+	snapshotFiles := make([]string, len(DEFAULT_SNAPSHOT_FILES))
+	for k, filename := range DEFAULT_SNAPSHOT_FILES {
+		snapshotFiles[k] = l.ConfigPath(filename)
+	}
+	l.SetSnapshots(DEFAULT_SNAPSHOTS, snapshotFiles...)
+	return l, nil
 }
@@ -92,5 +92,5 @@ func (l *LocalStorage) EnsurePermissions(name string, mode fs.FileMode) error {
 }
 
 func (l *LocalStorage) FileInfo(name string) (fs.FileInfo, error) {
-	return os.Stat(name)
+	return os.Stat(path.Join(l.path, name))
 }
@@ -1,10 +1,14 @@
 package localstorage
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path"
+
+	"github.com/in4it/go-devops-platform/storage"
 )
 
 func (l *LocalStorage) ReadFile(name string) ([]byte, error) {
@@ -18,6 +22,21 @@ func (l *LocalStorage) OpenFilesFromPos(names []string, pos int64) ([]io.ReadClo
 	}
 	for _, name := range names {
 		file, err := os.Open(path.Join(l.path, name))
+		if errors.Is(err, fs.ErrNotExist) && l.FileExists(name+storage.COMPRESSED_SUFFIX) {
+			data, err := l.ReadFile(name + storage.COMPRESSED_SUFFIX)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read file (%s): %s", name+storage.COMPRESSED_SUFFIX, err)
+			}
+			var reader io.ReadCloser
+			reader, pos, err = storage.OpenCompressedFromPos(name+storage.COMPRESSED_SUFFIX, data, pos)
+			if err != nil {
+				return nil, err
+			}
+			if reader != nil {
+				readers = append(readers, reader)
+			}
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf("cannot open file (%s): %s", name, err)
 		}
@@ -0,0 +1,54 @@
+package localstorage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+)
+
+// SetSnapshots keeps the given number of previous versions of the files, when they are overwritten with WriteFile
+func (l *LocalStorage) SetSnapshots(count int, names ...string) {
+	l.snapshots = count
+	l.snapshotFiles = make(map[string]bool)
+	for _, name := range names {
+		l.snapshotFiles[path.Clean(name)] = true
+	}
+}
+
+// Snapshots returns the existing snapshots of a file, most recent first
+func (l *LocalStorage) Snapshots(name string) ([]string, error) {
+	snapshots := []string{}
+	for i := 1; i <= l.snapshots; i++ {
+		snapshot := snapshotName(name, i)
+		if l.FileExists(snapshot) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots, nil
+}
+
+// rotateSnapshots moves the existing snapshots one position and copies the current file to the first snapshot
+func (l *LocalStorage) rotateSnapshots(name string) error {
+	if l.snapshots <= 0 || !l.snapshotFiles[path.Clean(name)] {
+		return nil
+	}
+	current, err := os.ReadFile(path.Join(l.path, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read error: %s", err)
+	}
+	for i := l.snapshots - 1; i >= 1; i-- {
+		err := os.Rename(path.Join(l.path, snapshotName(name, i)), path.Join(l.path, snapshotName(name, i+1)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("rename error: %s", err)
+		}
+	}
+	return l.writeFileAtomic(snapshotName(name, 1), current)
+}
+
+func snapshotName(name string, i int) string {
+	return fmt.Sprintf("%s%s%d", name, SNAPSHOT_SUFFIX, i)
+}
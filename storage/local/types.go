@@ -1,5 +1,10 @@
 package localstorage
 
+import "time"
+
 type LocalStorage struct {
-	path string
+	path          string
+	snapshots     int
+	snapshotFiles map[string]bool
+	watchInterval time.Duration
 }
@@ -0,0 +1,62 @@
+package localstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+// SetWatchInterval sets how often the filesystem is polled for changes
+func (l *LocalStorage) SetWatchInterval(interval time.Duration) {
+	l.watchInterval = interval
+}
+
+// Watch polls the files under prefix and sends an event when a file is written or removed
+func (l *LocalStorage) Watch(ctx context.Context, prefix string) (<-chan storage.Event, error) {
+	interval := l.watchInterval
+	if interval == 0 {
+		interval = storage.DEFAULT_WATCH_INTERVAL
+	}
+	return storage.Poll(ctx, interval, func() (storage.WatchState, error) {
+		return l.scan(prefix)
+	})
+}
+
+func (l *LocalStorage) scan(prefix string) (storage.WatchState, error) {
+	state := storage.WatchState{}
+	err := filepath.WalkDir(path.Join(l.path, prefix), func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") { // skip temporary files of atomic writes
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		name, err := filepath.Rel(l.path, fullPath)
+		if err != nil {
+			return err
+		}
+		state[filepath.ToSlash(name)] = fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+		return nil
+	})
+	if err != nil {
+		return state, fmt.Errorf("walk error: %s", err)
+	}
+	return state, nil
+}
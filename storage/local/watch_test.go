@@ -0,0 +1,66 @@
+package localstorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+func TestWatch(t *testing.T) {
+	l, err := NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("new error: %s", err)
+	}
+	l.SetWatchInterval(10 * time.Millisecond)
+	err = l.EnsurePath(CONFIG_PATH)
+	if err != nil {
+		t.Fatalf("ensure path error: %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := l.Watch(ctx, CONFIG_PATH)
+	if err != nil {
+		t.Fatalf("watch error: %s", err)
+	}
+	err = l.WriteFile("outside.json", []byte("{}"))
+	if err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	err = l.WriteFile(l.ConfigPath("users.json"), []byte("[]"))
+	if err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	select {
+	case event := <-events:
+		if event.Name != l.ConfigPath("users.json") || event.Type != storage.EventWrite {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for event")
+	}
+	err = l.Remove(l.ConfigPath("users.json"))
+	if err != nil {
+		t.Fatalf("remove error: %s", err)
+	}
+	select {
+	case event := <-events:
+		if event.Name != l.ConfigPath("users.json") || event.Type != storage.EventRemove {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for event")
+	}
+
+	// the polling stops when the context is done
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatalf("expected no more events")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the events channel to be closed")
+	}
+}
@@ -7,8 +7,49 @@ import (
 	"path"
 )
 
+// WriteFile writes to a temporary file first, and renames it when the data is synced to disk.
+// A crash during the write leaves the previous version of the file intact. The mode and ownership of the existing file are kept.
 func (l *LocalStorage) WriteFile(name string, data []byte) error {
-	return os.WriteFile(path.Join(l.path, name), data, 0600)
+	err := l.rotateSnapshots(name)
+	if err != nil {
+		return fmt.Errorf("snapshot error (%s): %s", name, err)
+	}
+	return l.writeFileAtomic(name, data)
+}
+
+func (l *LocalStorage) writeFileAtomic(name string, data []byte) error {
+	fullPath := path.Join(l.path, name)
+	tmpFile, err := os.CreateTemp(path.Dir(fullPath), "."+path.Base(fullPath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary file (%s): %s", name, err)
+	}
+	defer os.Remove(tmpFile.Name()) // no-op after a successful rename
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("write error (%s): %s", name, err)
+	}
+	if err := copyFileMode(fullPath, tmpFile); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("cannot copy mode and ownership (%s): %s", name, err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("sync error (%s): %s", name, err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("close error (%s): %s", name, err)
+	}
+	if err := os.Rename(tmpFile.Name(), fullPath); err != nil {
+		return fmt.Errorf("rename error (%s): %s", name, err)
+	}
+	// sync the directory, so the rename is persisted
+	dir, err := os.Open(path.Dir(fullPath))
+	if err != nil {
+		return nil
+	}
+	defer dir.Close()
+	dir.Sync()
+	return nil
 }
 
 func (l *LocalStorage) AppendFile(name string, data []byte) error {
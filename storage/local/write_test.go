@@ -0,0 +1,93 @@
+package localstorage
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+func TestWriteFileSnapshots(t *testing.T) {
+	l, err := NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("new error: %s", err)
+	}
+	err = l.EnsurePath(CONFIG_PATH)
+	if err != nil {
+		t.Fatalf("ensure path error: %s", err)
+	}
+	for i := 0; i < DEFAULT_SNAPSHOTS+3; i++ {
+		err = l.WriteFile(l.ConfigPath("config.json"), []byte(`{"version": `+strconv.Itoa(i)+`}`))
+		if err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	snapshots, err := l.Snapshots(l.ConfigPath("config.json"))
+	if err != nil {
+		t.Fatalf("snapshots error: %s", err)
+	}
+	if len(snapshots) != DEFAULT_SNAPSHOTS {
+		t.Fatalf("expected %d snapshots, got %d", DEFAULT_SNAPSHOTS, len(snapshots))
+	}
+	body, err := l.ReadFile(snapshots[0])
+	if err != nil {
+		t.Fatalf("read snapshot error: %s", err)
+	}
+	if string(body) != `{"version": `+strconv.Itoa(DEFAULT_SNAPSHOTS+1)+`}` {
+		t.Fatalf("unexpected snapshot contents: %s", body)
+	}
+
+	// files that are not in the snapshot list are not snapshotted
+	err = l.WriteFile("other.json", []byte("1"))
+	if err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	err = l.WriteFile("other.json", []byte("2"))
+	if err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if l.FileExists(snapshotName("other.json", 1)) {
+		t.Fatalf("expected no snapshot for other.json")
+	}
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(path.Join(l.GetPath(), CONFIG_PATH))
+	if err != nil {
+		t.Fatalf("readdir error: %s", err)
+	}
+	if len(entries) != DEFAULT_SNAPSHOTS+1 {
+		t.Fatalf("expected %d files in config dir, got %d", DEFAULT_SNAPSHOTS+1, len(entries))
+	}
+}
+
+func TestReadFileWithFallback(t *testing.T) {
+	l, err := NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("new error: %s", err)
+	}
+	err = l.EnsurePath(CONFIG_PATH)
+	if err != nil {
+		t.Fatalf("ensure path error: %s", err)
+	}
+	err = l.WriteFile(l.ConfigPath("users.json"), []byte(`{"login": "admin"}`))
+	if err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	err = l.WriteFile(l.ConfigPath("users.json"), []byte(`{"login": "adm`)) // truncated write
+	if err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	var out map[string]string
+	err = storage.ReadFileWithFallback(l, l.ConfigPath("users.json"), func(data []byte) error {
+		return json.Unmarshal(data, &out)
+	})
+	if err != nil {
+		t.Fatalf("read with fallback error: %s", err)
+	}
+	if out["login"] != "admin" {
+		t.Fatalf("expected snapshot to be used, got: %v", out)
+	}
+}
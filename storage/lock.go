@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/in4it/go-devops-platform/logging"
+)
+
+const DEFAULT_LOCK_TTL = 30 * time.Second
+const DEFAULT_LOCK_TIMEOUT = 30 * time.Second
+const LOCK_SUFFIX = ".lock"
+
+var ErrLockTimeout = errors.New("timeout while waiting for lock")
+
+// ErrLockNotSupported is returned by wrappers of which the inner storage doesn't implement Locker
+var ErrLockNotSupported = errors.New("storage doesn't support locking")
+
+var lockNotSupportedOnce sync.Once
+
+// Locker is implemented by storage that supports locks across instances sharing the same storage
+type Locker interface {
+	// Lock blocks until the lock is acquired. When the lock is not released within ttl (e.g. the instance crashed),
+	// another instance can take it over.
+	Lock(name string, ttl time.Duration) (Lease, error)
+}
+
+type Lease interface {
+	Unlock() error
+}
+
+// WithLock runs fn while holding the lock for name. Storage that doesn't support locking runs fn without lock, which is logged once.
+func WithLock(storage Iface, name string, fn func() error) error {
+	locker, ok := storage.(Locker)
+	if !ok {
+		logLockNotSupported(storage)
+		return fn()
+	}
+	lease, err := locker.Lock(name, DEFAULT_LOCK_TTL)
+	if errors.Is(err, ErrLockNotSupported) {
+		logLockNotSupported(storage)
+		return fn()
+	}
+	if err != nil {
+		return fmt.Errorf("lock error (%s): %s", name, err)
+	}
+	defer func() {
+		if err := lease.Unlock(); err != nil {
+			logging.ErrorLog(fmt.Errorf("unlock error (%s): %s", name, err))
+		}
+	}()
+	return fn()
+}
+
+func logLockNotSupported(storage Iface) {
+	lockNotSupportedOnce.Do(func() {
+		logging.ErrorLog(fmt.Errorf("storage %T doesn't support locking: changes of instances that share the storage can overwrite each other", storage))
+	})
+}
+
+// RetryLock calls tryLock until it returns a lease or an error. A nil lease without error means the lock is held by someone else.
+func RetryLock(timeout time.Duration, tryLock func() (Lease, error)) (Lease, error) {
+	deadline := time.Now().Add(timeout)
+	wait := 5 * time.Millisecond
+	for {
+		lease, err := tryLock()
+		if err != nil || lease != nil {
+			return lease, err
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLockTimeout
+		}
+		time.Sleep(wait)
+		if wait < 500*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// LocalLocks is an in-process Locker, for storage that is not shared between instances (or tests)
+type LocalLocks struct {
+	mu     sync.Mutex
+	locks  map[string]localLock
+	lastID uint64
+}
+
+type localLock struct {
+	id      uint64
+	expires time.Time
+}
+
+type localLease struct {
+	locks *LocalLocks
+	name  string
+	id    uint64
+}
+
+func (l *LocalLocks) Lock(name string, ttl time.Duration) (Lease, error) {
+	return RetryLock(DEFAULT_LOCK_TIMEOUT, func() (Lease, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.locks == nil {
+			l.locks = make(map[string]localLock)
+		}
+		if existing, ok := l.locks[name]; ok && time.Now().Before(existing.expires) {
+			return nil, nil
+		}
+		l.lastID++
+		l.locks[name] = localLock{id: l.lastID, expires: time.Now().Add(ttl)}
+		return &localLease{locks: l, name: name, id: l.lastID}, nil
+	})
+}
+
+func (l *localLease) Unlock() error {
+	l.locks.mu.Lock()
+	defer l.locks.mu.Unlock()
+	if existing, ok := l.locks.locks[l.name]; !ok || existing.id != l.id {
+		return fmt.Errorf("lock expired and was taken over")
+	}
+	delete(l.locks.locks, l.name)
+	return nil
+}
@@ -0,0 +1,58 @@
+package memorystorage
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// isDir returns true for directories created with EnsurePath and for the parent directories of files.
+// Must be called with Mu held.
+func (m *MockMemoryStorage) isDir(name string) bool {
+	name = path.Clean(name)
+	if name == "." || name == "/" {
+		return true
+	}
+	if _, ok := m.dirs[name]; ok {
+		return true
+	}
+	for k := range m.Data {
+		if strings.HasPrefix(k, name+"/") {
+			return true
+		}
+	}
+	for k := range m.dirs {
+		if strings.HasPrefix(k, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// children returns the sorted names of the files and directories directly under dir. Must be called with Mu held.
+func (m *MockMemoryStorage) children(dir string) []string {
+	prefix := ""
+	if dir = path.Clean(dir); dir != "." && dir != "/" {
+		prefix = dir + "/"
+	}
+	names := make(map[string]bool)
+	add := func(name string) {
+		if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			return
+		}
+		child, _, _ := strings.Cut(strings.TrimPrefix(name, prefix), "/")
+		names[child] = true
+	}
+	for k := range m.Data {
+		add(k)
+	}
+	for k := range m.dirs {
+		add(k)
+	}
+	res := make([]string, 0, len(names))
+	for name := range names {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,86 @@
+package memorystorage
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Op is a storage operation, used to select the operations a fault is injected in
+type Op string
+
+const (
+	OpAny     Op = ""
+	OpRead    Op = "read"  // ReadFile, OpenFile, OpenFilesFromPos
+	OpWrite   Op = "write" // WriteFile, AppendFile, OpenFileForWriting, OpenFileForAppending
+	OpRemove  Op = "remove"
+	OpRename  Op = "rename"
+	OpReadDir Op = "readdir"
+)
+
+var ErrInjectedFault = errors.New("injected fault")
+
+// Fault is injected in the operations that match Op and Prefix. Examples:
+//
+//	Fault{Op: OpWrite, Nth: 2, Err: ErrInjectedFault}           // the 2nd write fails
+//	Fault{Op: OpWrite, Partial: 10}                             // writes are truncated to 10 bytes, without error
+//	Fault{Op: OpRead, Prefix: "config/", Latency: time.Second}  // reads in config/ are slow
+type Fault struct {
+	Op      Op
+	Prefix  string        // only files with this prefix (empty matches every file)
+	Nth     int           // only the Nth matching call (1-based), 0 matches every call
+	Err     error         // error returned by the operation
+	Partial int           // writes only: the first Partial bytes are written before Err is returned
+	Latency time.Duration // delay before the operation
+}
+
+type faultState struct {
+	Fault
+	calls int
+}
+
+// InjectFault adds a fault. Faults stay active until ClearFaults is called.
+func (m *MockMemoryStorage) InjectFault(fault Fault) {
+	m.faultMu.Lock()
+	defer m.faultMu.Unlock()
+	m.faults = append(m.faults, &faultState{Fault: fault})
+}
+
+func (m *MockMemoryStorage) ClearFaults() {
+	m.faultMu.Lock()
+	defer m.faultMu.Unlock()
+	m.faults = nil
+}
+
+// fault applies the latency of the matching faults and returns the first matching fault with an error or partial write.
+// Must be called without holding Mu.
+func (m *MockMemoryStorage) fault(op Op, name string) *Fault {
+	m.faultMu.Lock()
+	var latency time.Duration
+	var res *Fault
+	for _, f := range m.faults {
+		if (f.Op != OpAny && f.Op != op) || !strings.HasPrefix(name, f.Prefix) {
+			continue
+		}
+		f.calls++
+		if f.Nth > 0 && f.calls != f.Nth {
+			continue
+		}
+		latency += f.Latency
+		if res == nil && (f.Err != nil || (op == OpWrite && f.Partial > 0)) {
+			fault := f.Fault
+			res = &fault
+		}
+	}
+	m.faultMu.Unlock()
+	time.Sleep(latency)
+	return res
+}
+
+// faultErr returns the error of the matching fault, for operations that can't be partial
+func (m *MockMemoryStorage) faultErr(op Op, name string) error {
+	if fault := m.fault(op, name); fault != nil {
+		return fault.Err
+	}
+	return nil
+}
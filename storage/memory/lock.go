@@ -0,0 +1,11 @@
+package memorystorage
+
+import (
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+func (m *MockMemoryStorage) Lock(name string, ttl time.Duration) (storage.Lease, error) {
+	return m.locks.Lock(name, ttl)
+}
@@ -7,10 +7,18 @@ import (
 	"io/fs"
 	"os"
 	"path"
-	"strings"
 	"sync"
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
 )
 
+// DEFAULT_FILE_MODE is the mode of a file created with WriteFile, like the temporary file of the local storage
+const DEFAULT_FILE_MODE fs.FileMode = 0600
+
+// DEFAULT_APPEND_FILE_MODE is the mode of a file created with AppendFile or OpenFileForAppending
+const DEFAULT_APPEND_FILE_MODE fs.FileMode = 0660
+
 type MockReadWriterData []byte
 
 func (m *MockReadWriterData) Close() error {
@@ -22,71 +30,148 @@ func (m *MockReadWriterData) Write(p []byte) (nn int, err error) {
 }
 
 type MockMemoryStorage struct {
-	FileInfoData map[string]*FileInfo
+	FileInfoData map[string]*FileInfo // overrides the file info of a file
 	Data         map[string]*MockReadWriterData
 	Mu           sync.Mutex
+	meta         map[string]*fileMeta
+	dirs         map[string]time.Time
+	faultMu      sync.Mutex
+	faults       []*faultState
+	watchMu      sync.Mutex
+	watchers     []*watcher
+	locks        storage.LocalLocks
+}
+
+type fileMeta struct {
+	modTime time.Time
+	mode    fs.FileMode
+	owner   string
+}
+
+// init must be called with Mu held
+func (m *MockMemoryStorage) init() {
+	if m.Data == nil {
+		m.Data = make(map[string]*MockReadWriterData)
+	}
+	if m.meta == nil {
+		m.meta = make(map[string]*fileMeta)
+	}
+	if m.dirs == nil {
+		m.dirs = make(map[string]time.Time)
+	}
+}
+
+// setData must be called with Mu held
+func (m *MockMemoryStorage) setData(name string, data *MockReadWriterData, mode fs.FileMode) {
+	m.Data[name] = data
+	meta, ok := m.meta[name]
+	if !ok {
+		meta = &fileMeta{mode: mode}
+		m.meta[name] = meta
+	}
+	meta.modTime = time.Now()
+}
+
+// metaOf returns the metadata of a file, also for files that were added to Data directly. Must be called with Mu held.
+func (m *MockMemoryStorage) metaOf(name string) *fileMeta {
+	meta, ok := m.meta[name]
+	if !ok {
+		meta = &fileMeta{mode: DEFAULT_FILE_MODE}
+		m.meta[name] = meta
+	}
+	return meta
+}
+
+func notExistError(name string) error {
+	return fmt.Errorf("%s: %w", name, fs.ErrNotExist)
 }
 
 func (m *MockMemoryStorage) ConfigPath(filename string) string {
 	return path.Join("config", filename)
 }
 func (m *MockMemoryStorage) Rename(oldName, newName string) error {
-	m.Mu.Lock()
-	defer m.Mu.Unlock()
-	if m.Data == nil {
-		m.Data = make(map[string]*MockReadWriterData)
+	if err := m.faultErr(OpRename, oldName); err != nil {
+		return err
 	}
+	m.Mu.Lock()
+	m.init()
 	_, ok := m.Data[oldName]
 	if !ok {
-		return fmt.Errorf("file doesn't exist")
+		m.Mu.Unlock()
+		return notExistError(oldName)
 	}
 	m.Data[newName] = m.Data[oldName]
+	m.meta[newName] = m.metaOf(oldName)
+	m.meta[newName].modTime = time.Now()
 	delete(m.Data, oldName)
+	delete(m.meta, oldName)
+	m.Mu.Unlock()
+	m.notify(storage.Event{Name: oldName, Type: storage.EventRemove}, storage.Event{Name: newName, Type: storage.EventWrite})
 	return nil
 }
+
+// FileExists returns true for files and directories
 func (m *MockMemoryStorage) FileExists(name string) bool {
 	m.Mu.Lock()
 	defer m.Mu.Unlock()
-	if m.Data == nil {
-		m.Data = make(map[string]*MockReadWriterData)
-	}
+	m.init()
 	_, ok := m.Data[name]
-	return ok
+	return ok || m.isDir(name)
 }
 
 func (m *MockMemoryStorage) ReadFile(name string) ([]byte, error) {
+	if err := m.faultErr(OpRead, name); err != nil {
+		return nil, err
+	}
 	m.Mu.Lock()
 	defer m.Mu.Unlock()
-	if m.Data == nil {
-		m.Data = make(map[string]*MockReadWriterData)
-	}
+	m.init()
 	val, ok := m.Data[name]
 	if !ok {
-		return nil, fmt.Errorf("file does not exist")
+		return nil, notExistError(name)
 	}
-	return *val, nil
+	return bytes.Clone(*val), nil
 }
 func (m *MockMemoryStorage) WriteFile(name string, data []byte) error {
+	fault := m.fault(OpWrite, name)
+	if fault != nil {
+		if fault.Partial <= 0 {
+			return fault.Err
+		}
+		data = data[:min(fault.Partial, len(data))]
+	}
 	m.Mu.Lock()
-	defer m.Mu.Unlock()
-	if m.Data == nil {
-		m.Data = make(map[string]*MockReadWriterData)
+	m.init()
+	m.setData(name, (*MockReadWriterData)(&data), DEFAULT_FILE_MODE)
+	m.meta[name].mode = DEFAULT_FILE_MODE // the local storage replaces the file
+	m.Mu.Unlock()
+	m.notify(storage.Event{Name: name, Type: storage.EventWrite})
+	if fault != nil {
+		return fault.Err
 	}
-	m.Data[name] = (*MockReadWriterData)(&data)
 	return nil
 }
 func (m *MockMemoryStorage) AppendFile(name string, data []byte) error {
-	m.Mu.Lock()
-	defer m.Mu.Unlock()
-	if m.Data == nil {
-		m.Data = make(map[string]*MockReadWriterData)
+	fault := m.fault(OpWrite, name)
+	if fault != nil {
+		if fault.Partial <= 0 {
+			return fault.Err
+		}
+		data = data[:min(fault.Partial, len(data))]
 	}
+	m.Mu.Lock()
+	m.init()
 	if m.Data[name] == nil {
-		m.Data[name] = (*MockReadWriterData)(&data)
+		m.setData(name, (*MockReadWriterData)(&data), DEFAULT_APPEND_FILE_MODE)
 	} else {
 		*m.Data[name] = append(*m.Data[name], data...)
+		m.setData(name, m.Data[name], DEFAULT_APPEND_FILE_MODE)
+	}
+	m.Mu.Unlock()
+	m.notify(storage.Event{Name: name, Type: storage.EventWrite})
+	if fault != nil {
+		return fault.Err
 	}
-
 	return nil
 }
 
@@ -95,107 +180,182 @@ func (m *MockMemoryStorage) GetPath() string {
 	return path.Dir(pwd)
 }
 
+// EnsurePath creates the directory. Unlike the local storage, the parent directories don't need to exist.
 func (m *MockMemoryStorage) EnsurePath(pathname string) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+	m.init()
+	pathname = path.Clean(pathname)
+	if _, ok := m.Data[pathname]; ok {
+		return fmt.Errorf("create directory error: %s is a file", pathname)
+	}
+	if !m.isDir(pathname) {
+		m.dirs[pathname] = time.Now()
+	}
 	return nil
 }
 
+// EnsureOwnership records the owner of the file, see Owner. Files that don't exist are ignored.
 func (m *MockMemoryStorage) EnsureOwnership(filename, login string) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+	m.init()
+	if _, ok := m.Data[filename]; ok {
+		m.metaOf(filename).owner = login
+	}
 	return nil
 }
 
-func (m *MockMemoryStorage) ReadDir(path string) ([]string, error) {
+// Owner returns the owner set with EnsureOwnership
+func (m *MockMemoryStorage) Owner(filename string) string {
 	m.Mu.Lock()
 	defer m.Mu.Unlock()
-	if m.Data == nil {
-		m.Data = make(map[string]*MockReadWriterData)
-	}
-	res := []string{}
-	for k := range m.Data {
-		if path == "" {
-			res = append(res, strings.TrimSuffix(k, "/"))
-		} else if strings.HasPrefix(k, path+"/") {
-			res = append(res, strings.ReplaceAll(k, path+"/", ""))
-		}
+	m.init()
+	meta, ok := m.meta[filename]
+	if !ok {
+		return ""
 	}
-	return res, nil
+	return meta.owner
 }
 
-func (m *MockMemoryStorage) Remove(name string) error {
+// ReadDir returns the names of the files and directories directly under pathname, sorted by name
+func (m *MockMemoryStorage) ReadDir(pathname string) ([]string, error) {
+	if err := m.faultErr(OpReadDir, pathname); err != nil {
+		return []string{}, err
+	}
 	m.Mu.Lock()
 	defer m.Mu.Unlock()
-	if m.Data == nil {
-		m.Data = make(map[string]*MockReadWriterData)
+	m.init()
+	pathname = path.Clean(pathname)
+	if !m.isDir(pathname) {
+		return []string{}, notExistError(pathname)
 	}
+	return m.children(pathname), nil
+}
+
+func (m *MockMemoryStorage) Remove(name string) error {
+	if err := m.faultErr(OpRemove, name); err != nil {
+		return err
+	}
+	m.Mu.Lock()
+	m.init()
 	_, ok := m.Data[name]
 	if !ok {
-		return fmt.Errorf("file does not exist")
+		if _, isDir := m.dirs[name]; isDir && len(m.children(name)) == 0 {
+			delete(m.dirs, name)
+			m.Mu.Unlock()
+			return nil
+		}
+		m.Mu.Unlock()
+		return notExistError(name)
 	}
 	delete(m.Data, name)
+	delete(m.meta, name)
+	m.Mu.Unlock()
+	m.notify(storage.Event{Name: name, Type: storage.EventRemove})
 	return nil
 }
 
 func (m *MockMemoryStorage) OpenFilesFromPos(names []string, pos int64) ([]io.ReadCloser, error) {
-	m.Mu.Lock()
-	defer m.Mu.Unlock()
-	if m.Data == nil {
-		m.Data = make(map[string]*MockReadWriterData)
+	readClosers := []io.ReadCloser{}
+	if pos < 0 {
+		return readClosers, nil
 	}
-	if pos > 0 {
-		return nil, fmt.Errorf("pos > 0 not implemented")
+	for _, name := range names {
+		if err := m.faultErr(OpRead, name); err != nil {
+			return nil, err
+		}
 	}
-	readClosers := []io.ReadCloser{}
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+	m.init()
 	for _, name := range names {
 		val, ok := m.Data[name]
+		if compressed, isCompressed := m.Data[name+storage.COMPRESSED_SUFFIX]; !ok && isCompressed {
+			var reader io.ReadCloser
+			var err error
+			reader, pos, err = storage.OpenCompressedFromPos(name+storage.COMPRESSED_SUFFIX, *compressed, pos)
+			if err != nil {
+				return nil, err
+			}
+			if reader != nil {
+				readClosers = append(readClosers, reader)
+			}
+			continue
+		}
 		if !ok {
-			return nil, fmt.Errorf("file does not exist")
+			return nil, fmt.Errorf("cannot open file (%s): %w", name, notExistError(name))
 		}
-		readClosers = append(readClosers, io.NopCloser(bytes.NewBuffer(*val)))
+		if int64(len(*val)) <= pos {
+			pos -= int64(len(*val))
+			continue
+		}
+		readClosers = append(readClosers, io.NopCloser(bytes.NewReader(bytes.Clone((*val)[pos:]))))
+		pos = 0
 	}
 	return readClosers, nil
 }
 func (m *MockMemoryStorage) OpenFile(name string) (io.ReadCloser, error) {
+	if err := m.faultErr(OpRead, name); err != nil {
+		return nil, err
+	}
 	m.Mu.Lock()
 	defer m.Mu.Unlock()
-	if m.Data == nil {
-		m.Data = make(map[string]*MockReadWriterData)
-	}
+	m.init()
 	val, ok := m.Data[name]
 	if !ok {
-		return nil, fmt.Errorf("file does not exist")
+		return nil, fmt.Errorf("cannot open file (%s): %w", name, notExistError(name))
 	}
-
-	return io.NopCloser(bytes.NewBuffer(*val)), nil
+	return io.NopCloser(bytes.NewReader(bytes.Clone(*val))), nil
 }
 func (m *MockMemoryStorage) OpenFileForWriting(name string) (io.WriteCloser, error) {
-	if m.Data == nil {
-		m.Data = make(map[string]*MockReadWriterData)
+	if err := m.faultErr(OpWrite, name); err != nil {
+		return nil, err
 	}
-	m.Data[name] = (*MockReadWriterData)(&[]byte{})
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+	m.init()
+	m.setData(name, (*MockReadWriterData)(&[]byte{}), DEFAULT_FILE_MODE)
 	return m.Data[name], nil
 }
 func (m *MockMemoryStorage) OpenFileForAppending(name string) (io.WriteCloser, error) {
+	if err := m.faultErr(OpWrite, name); err != nil {
+		return nil, err
+	}
 	m.Mu.Lock()
 	defer m.Mu.Unlock()
-	if m.Data == nil {
-		m.Data = make(map[string]*MockReadWriterData)
-	}
+	m.init()
 	val, ok := m.Data[name]
 	if !ok {
-		m.Data[name] = (*MockReadWriterData)(&[]byte{})
-		return m.Data[name], nil
+		val = (*MockReadWriterData)(&[]byte{})
 	}
-	m.Data[name] = (*MockReadWriterData)(val)
+	m.setData(name, val, DEFAULT_APPEND_FILE_MODE)
 	return m.Data[name], nil
 }
 func (m *MockMemoryStorage) EnsurePermissions(name string, mode fs.FileMode) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+	m.init()
+	if _, ok := m.Data[name]; ok { // files that don't exist are ignored
+		m.metaOf(name).mode = mode.Perm()
+	}
 	return nil
 }
+
+// FileInfo returns the file info set in FileInfoData, or else the size, mode and modification time of the file or directory
 func (m *MockMemoryStorage) FileInfo(name string) (fs.FileInfo, error) {
 	m.Mu.Lock()
 	defer m.Mu.Unlock()
-	val, ok := m.FileInfoData[name]
-	if !ok {
-		return FileInfo{}, fmt.Errorf("couldn't get file info for: %s", name)
+	m.init()
+	if val, ok := m.FileInfoData[name]; ok {
+		return val, nil
+	}
+	if val, ok := m.Data[name]; ok {
+		meta := m.metaOf(name)
+		return FileInfo{NameOut: path.Base(name), SizeOut: int64(len(*val)), ModeOut: meta.mode, ModTimeOut: meta.modTime}, nil
+	}
+	if m.isDir(name) {
+		return FileInfo{NameOut: path.Base(name), ModeOut: fs.ModeDir | 0700, ModTimeOut: m.dirs[path.Clean(name)], IsDirOut: true}, nil
 	}
-	return val, nil
+	return FileInfo{}, fmt.Errorf("couldn't get file info: %w", notExistError(name))
 }
@@ -0,0 +1,217 @@
+package memorystorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFileInfoAndReadDir(t *testing.T) {
+	m := &MockMemoryStorage{}
+	if err := m.WriteFile(m.ConfigPath("config.json"), []byte("12345")); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	if err := m.WriteFile(m.ConfigPath("pki/private.pem"), []byte("key")); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	if err := m.EnsurePath("stats"); err != nil {
+		t.Fatalf("ensure path error: %s", err)
+	}
+
+	fileInfo, err := m.FileInfo(m.ConfigPath("config.json"))
+	if err != nil {
+		t.Fatalf("file info error: %s", err)
+	}
+	if fileInfo.Name() != "config.json" || fileInfo.Size() != 5 || fileInfo.IsDir() || fileInfo.Mode().Perm() != DEFAULT_FILE_MODE {
+		t.Fatalf("unexpected file info: %+v", fileInfo)
+	}
+	if time.Since(fileInfo.ModTime()) > time.Minute {
+		t.Fatalf("unexpected modtime: %s", fileInfo.ModTime())
+	}
+	dirInfo, err := m.FileInfo(m.ConfigPath("pki"))
+	if err != nil {
+		t.Fatalf("file info error: %s", err)
+	}
+	if !dirInfo.IsDir() {
+		t.Fatalf("expected directory")
+	}
+	_, err = m.FileInfo(m.ConfigPath("users.json"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist error, got: %v", err)
+	}
+
+	entries, err := m.ReadDir("")
+	if err != nil {
+		t.Fatalf("readdir error: %s", err)
+	}
+	if !slices.Equal(entries, []string{"config", "stats"}) {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+	entries, err = m.ReadDir("config")
+	if err != nil {
+		t.Fatalf("readdir error: %s", err)
+	}
+	if !slices.Equal(entries, []string{"config.json", "pki"}) {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+	entries, err = m.ReadDir("stats")
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected empty directory, got: %v (err: %v)", entries, err)
+	}
+	_, err = m.ReadDir("doesnotexist")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist error, got: %v", err)
+	}
+
+	if err := m.EnsurePermissions(m.ConfigPath("pki/private.pem"), 0400); err != nil {
+		t.Fatalf("ensure permissions error: %s", err)
+	}
+	if err := m.EnsureOwnership(m.ConfigPath("pki/private.pem"), "vpn"); err != nil {
+		t.Fatalf("ensure ownership error: %s", err)
+	}
+	fileInfo, err = m.FileInfo(m.ConfigPath("pki/private.pem"))
+	if err != nil {
+		t.Fatalf("file info error: %s", err)
+	}
+	if fileInfo.Mode().Perm() != 0400 || m.Owner(m.ConfigPath("pki/private.pem")) != "vpn" {
+		t.Fatalf("unexpected mode or owner: %s %s", fileInfo.Mode(), m.Owner(m.ConfigPath("pki/private.pem")))
+	}
+	// like the other mocks, missing files are ignored
+	if err := m.EnsurePermissions(m.ConfigPath("users.json"), 0400); err != nil {
+		t.Fatalf("expected no error for a missing file, got: %v", err)
+	}
+	if err := m.EnsureOwnership(m.ConfigPath("users.json"), "vpn"); err != nil {
+		t.Fatalf("expected no error for a missing file, got: %v", err)
+	}
+}
+
+func TestSeededData(t *testing.T) {
+	data := MockReadWriterData("seeded")
+	m := &MockMemoryStorage{Data: map[string]*MockReadWriterData{"a": &data}}
+	if err := m.Rename("a", "b"); err != nil {
+		t.Fatalf("rename error: %s", err)
+	}
+	fileInfo, err := m.FileInfo("b")
+	if err != nil || fileInfo.Size() != 6 || fileInfo.Mode().Perm() != DEFAULT_FILE_MODE {
+		t.Fatalf("unexpected file info: %+v (%v)", fileInfo, err)
+	}
+	m.Data["c"] = &data
+	if _, err := m.FileInfo("c"); err != nil {
+		t.Fatalf("file info error: %s", err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	m := &MockMemoryStorage{}
+	m.InjectFault(Fault{Op: OpWrite, Nth: 2, Err: ErrInjectedFault})
+	if err := m.WriteFile("a", []byte("1")); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	if err := m.WriteFile("a", []byte("2")); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("expected injected fault, got: %v", err)
+	}
+	if err := m.WriteFile("a", []byte("3")); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	m.ClearFaults()
+
+	// partial write, with and without error
+	m.InjectFault(Fault{Op: OpWrite, Prefix: "b", Partial: 3, Err: ErrInjectedFault})
+	if err := m.WriteFile("b", []byte("123456")); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("expected injected fault, got: %v", err)
+	}
+	if body, _ := m.ReadFile("b"); string(body) != "123" {
+		t.Fatalf("expected partial write, got: %s", body)
+	}
+	m.ClearFaults()
+	m.InjectFault(Fault{Op: OpWrite, Partial: 2})
+	if err := m.AppendFile("b", []byte("456")); err != nil {
+		t.Fatalf("append error: %s", err)
+	}
+	if body, _ := m.ReadFile("b"); string(body) != "12345" {
+		t.Fatalf("expected partial append, got: %s", body)
+	}
+	m.ClearFaults()
+
+	// read faults and latency
+	m.InjectFault(Fault{Op: OpRead, Err: ErrInjectedFault, Latency: 20 * time.Millisecond})
+	start := time.Now()
+	if _, err := m.OpenFile("a"); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("expected injected fault, got: %v", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Fatalf("expected latency")
+	}
+	m.ClearFaults()
+	file, err := m.OpenFile("a")
+	if err != nil {
+		t.Fatalf("open error: %s", err)
+	}
+	body, err := io.ReadAll(file)
+	if err != nil || string(body) != "3" {
+		t.Fatalf("unexpected body: %s (err: %v)", body, err)
+	}
+}
+
+func TestOpenFilesFromPos(t *testing.T) {
+	m := &MockMemoryStorage{}
+	m.WriteFile("1.log", []byte("abc"))
+	m.WriteFile("2.log", []byte("def"))
+	files, err := m.OpenFilesFromPos([]string{"1.log", "2.log"}, 4)
+	if err != nil {
+		t.Fatalf("open error: %s", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+	body, _ := io.ReadAll(files[0])
+	if string(body) != "ef" {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
+
+func TestWatchWithoutReader(t *testing.T) {
+	m := &MockMemoryStorage{}
+	events, err := m.Watch(context.Background(), "")
+	if err != nil {
+		t.Fatalf("watch error: %s", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 200; i++ { // more than the buffer of the watcher
+			m.WriteFile("file", []byte("data"))
+		}
+		m.Remove("file")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("writes blocked by a watcher that doesn't read")
+	}
+	if len(events) != cap(events) {
+		t.Fatalf("expected a full buffer, got: %d", len(events))
+	}
+}
+
+func TestWatchStop(t *testing.T) {
+	m := &MockMemoryStorage{}
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := m.Watch(ctx, "")
+	if err != nil {
+		t.Fatalf("watch error: %s", err)
+	}
+	cancel()
+	for range events { // closed after the watcher is removed
+	}
+	m.WriteFile("file", []byte("data")) // doesn't send on the closed channel
+	m.watchMu.Lock()
+	defer m.watchMu.Unlock()
+	if len(m.watchers) != 0 {
+		t.Fatalf("expected the watcher to be removed")
+	}
+}
@@ -0,0 +1,47 @@
+package memorystorage
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+type watcher struct {
+	prefix string
+	events chan storage.Event
+}
+
+// Watch sends an event for every write or remove under prefix, directly when it happens. The watcher is removed when ctx is done.
+func (m *MockMemoryStorage) Watch(ctx context.Context, prefix string) (<-chan storage.Event, error) {
+	m.watchMu.Lock()
+	defer m.watchMu.Unlock()
+	w := &watcher{prefix: prefix, events: make(chan storage.Event, 100)}
+	m.watchers = append(m.watchers, w)
+	go func() {
+		<-ctx.Done()
+		m.watchMu.Lock()
+		defer m.watchMu.Unlock()
+		m.watchers = slices.DeleteFunc(m.watchers, func(existing *watcher) bool { return existing == w })
+		close(w.events)
+	}()
+	return w.events, nil
+}
+
+// notify must be called without holding Mu, so a watcher can use the storage while handling the event.
+// Events are dropped when the buffer of a watcher is full, so a watcher that doesn't read can't block the storage.
+func (m *MockMemoryStorage) notify(events ...storage.Event) {
+	m.watchMu.Lock()
+	defer m.watchMu.Unlock()
+	for _, w := range m.watchers {
+		for _, event := range events {
+			if strings.HasPrefix(event.Name, w.prefix) {
+				select {
+				case w.events <- event:
+				default:
+				}
+			}
+		}
+	}
+}
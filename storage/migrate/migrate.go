@@ -0,0 +1,108 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+// STATS_DIR is the directory of the login stats / audit logs, next to the config directory
+const STATS_DIR = "stats"
+
+type Options struct {
+	DryRun bool     // only report what would be copied
+	Roots  []string // directories to migrate. Defaults to the config directory (incl. pki and saml) and the stats directory
+}
+
+type Result struct {
+	Copied  []string // files copied (or that would be copied in a dry-run)
+	Skipped []string // files that already exist in the destination with the same checksum
+}
+
+// Migrate copies every file under the roots from src to dst. Every copied file is read back and its checksum verified.
+// Files that already exist in dst with the same checksum are skipped, so an interrupted migration can be resumed
+// by running it again. File contents are copied as-is (encrypted files stay encrypted).
+func Migrate(src, dst storage.Iface, options Options) (Result, error) {
+	return MigrateContext(context.Background(), src, dst, options)
+}
+
+// MigrateContext is Migrate with a context. When the context is done, the migration stops after the current file.
+func MigrateContext(ctx context.Context, src, dst storage.Iface, options Options) (Result, error) {
+	result := Result{Copied: []string{}, Skipped: []string{}}
+	roots := options.Roots
+	if len(roots) == 0 {
+		roots = []string{strings.TrimSuffix(src.ConfigPath(""), "/"), STATS_DIR}
+	}
+	for _, root := range roots {
+		if _, err := src.FileInfo(root); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		err := storage.WalkContext(ctx, src, root, func(name string) error {
+			if strings.HasSuffix(name, storage.LOCK_SUFFIX) {
+				return nil
+			}
+			copied, err := migrateFile(ctx, src, dst, name, options.DryRun)
+			if err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+			if copied {
+				result.Copied = append(result.Copied, name)
+			} else {
+				result.Skipped = append(result.Skipped, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func migrateFile(ctx context.Context, src, dst storage.Iface, name string, dryRun bool) (bool, error) {
+	srcContext, dstContext := storage.WithContext(src), storage.WithContext(dst)
+	data, err := srcContext.ReadFileContext(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("read error: %s", err)
+	}
+	checksum := sha256.Sum256(data)
+	if dstContext.FileExistsContext(ctx, name) {
+		existing, err := dstContext.ReadFileContext(ctx, name)
+		if err == nil && sha256.Sum256(existing) == checksum {
+			return false, nil
+		}
+	}
+	if dryRun {
+		return true, nil
+	}
+	err = storage.EnsurePathAll(dst, path.Dir(name))
+	if err != nil {
+		return false, fmt.Errorf("ensure path error: %s", err)
+	}
+	err = dstContext.WriteFileContext(ctx, name, data)
+	if err != nil {
+		return false, fmt.Errorf("write error: %s", err)
+	}
+	written, err := dstContext.ReadFileContext(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("read back error: %s", err)
+	}
+	if sha256.Sum256(written) != checksum {
+		return false, fmt.Errorf("checksum mismatch after copy")
+	}
+	// keep permissions (e.g. of private keys) when the source storage knows them
+	fileInfo, err := src.FileInfo(name)
+	if err == nil && fileInfo.Mode().Perm() != 0 {
+		err = dst.EnsurePermissions(name, fileInfo.Mode().Perm())
+		if err != nil {
+			return false, fmt.Errorf("ensure permissions error: %s", err)
+		}
+	}
+	return true, nil
+}
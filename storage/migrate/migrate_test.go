@@ -0,0 +1,78 @@
+package migrate
+
+import (
+	"slices"
+	"testing"
+
+	localstorage "github.com/in4it/go-devops-platform/storage/local"
+)
+
+func TestMigrate(t *testing.T) {
+	src, err := localstorage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("new storage error: %s", err)
+	}
+	dst, err := localstorage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("new storage error: %s", err)
+	}
+	for _, dir := range []string{"config", "config/pki", "config/saml", "stats"} {
+		if err := src.EnsurePath(dir); err != nil {
+			t.Fatalf("ensure path error: %s", err)
+		}
+	}
+	files := map[string]string{
+		"config/config.json":     `{"setupCompleted": true}`,
+		"config/pki/private.pem": "private key",
+		"config/saml/saml.crt":   "certificate",
+		"stats/logins-2024.log":  "login",
+	}
+	for name, contents := range files {
+		if err := src.WriteFile(name, []byte(contents)); err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	if err := src.EnsurePermissions("config/pki/private.pem", 0600); err != nil {
+		t.Fatalf("ensure permissions error: %s", err)
+	}
+
+	// dry-run doesn't write anything
+	result, err := Migrate(src, dst, Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("migrate error: %s", err)
+	}
+	if len(result.Copied) != len(files) {
+		t.Fatalf("expected %d files to copy, got: %v", len(files), result.Copied)
+	}
+	if dst.FileExists("config/config.json") {
+		t.Fatalf("dry-run wrote files")
+	}
+
+	// simulate an interrupted migration: only one file was copied
+	if _, err := Migrate(src, dst, Options{Roots: []string{"stats"}}); err != nil {
+		t.Fatalf("migrate error: %s", err)
+	}
+	result, err = Migrate(src, dst, Options{})
+	if err != nil {
+		t.Fatalf("migrate error: %s", err)
+	}
+	if !slices.Equal(result.Skipped, []string{"stats/logins-2024.log"}) {
+		t.Fatalf("expected stats file to be skipped, got: %v", result.Skipped)
+	}
+	for name, contents := range files {
+		body, err := dst.ReadFile(name)
+		if err != nil {
+			t.Fatalf("read file error: %s", err)
+		}
+		if string(body) != contents {
+			t.Fatalf("unexpected contents of %s: %s", name, body)
+		}
+	}
+	fileInfo, err := dst.FileInfo("config/pki/private.pem")
+	if err != nil {
+		t.Fatalf("file info error: %s", err)
+	}
+	if fileInfo.Mode().Perm() != 0600 {
+		t.Fatalf("permissions not preserved: %s", fileInfo.Mode().Perm())
+	}
+}
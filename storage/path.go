@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"path"
+	"strings"
+)
+
+// EnsurePathAll creates every directory of the given path (EnsurePath only creates the last one)
+func EnsurePathAll(storage Iface, dir string) error {
+	current := ""
+	for _, part := range strings.Split(path.Clean(dir), "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		current = path.Join(current, part)
+		if err := storage.EnsurePath(current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
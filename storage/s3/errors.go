@@ -0,0 +1,26 @@
+package s3storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+func isNotFound(err error) bool {
+	var responseError *awshttp.ResponseError
+	return errors.As(err, &responseError) && responseError.HTTPStatusCode() == http.StatusNotFound
+}
+
+// isConditionFailed returns true when a conditional write failed (or conflicted with another conditional write)
+func isConditionFailed(err error) bool {
+	var responseError *awshttp.ResponseError
+	return errors.As(err, &responseError) && (responseError.HTTPStatusCode() == http.StatusPreconditionFailed || responseError.HTTPStatusCode() == http.StatusConflict)
+}
+
+// notFoundError wraps fs.ErrNotExist, so callers can use errors.Is like with local storage
+func notFoundError(name string) error {
+	return fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+}
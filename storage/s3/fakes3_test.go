@@ -0,0 +1,227 @@
+package s3storage
+
+import (
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeS3 is an in-process, S3-compatible stand-in (path-style, single bucket)
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data    []byte
+	modTime time.Time
+	etag    string
+}
+
+type listBucketResult struct {
+	XMLName        xml.Name             `xml:"ListBucketResult"`
+	Name           string               `xml:"Name"`
+	Prefix         string               `xml:"Prefix"`
+	KeyCount       int                  `xml:"KeyCount"`
+	MaxKeys        int                  `xml:"MaxKeys"`
+	IsTruncated    bool                 `xml:"IsTruncated"`
+	Contents       []listBucketContents `xml:"Contents"`
+	CommonPrefixes []listBucketPrefix   `xml:"CommonPrefixes"`
+}
+type listBucketContents struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+}
+type listBucketPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+func newTestStorage(t *testing.T, prefix string) (*S3Storage, *fakeS3) {
+	fake := &fakeS3{bucket: "test-bucket", objects: make(map[string]fakeObject)}
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+
+	client := s3.New(s3.Options{
+		BaseEndpoint:               aws.String(ts.URL),
+		UsePathStyle:               true,
+		Region:                     "us-east-1",
+		Credentials:                aws.AnonymousCredentials{},
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	})
+	storage, err := NewWithClient(client, fake.bucket, prefix)
+	if err != nil {
+		t.Fatalf("new storage error: %s", err)
+	}
+	return storage, fake
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r)
+	case r.Method == http.MethodGet:
+		f.get(w, r, key)
+	case r.Method == http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeObjectHeaders(w, object)
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+		object, ok := f.objects[strings.TrimPrefix(strings.TrimPrefix(source, "/"), f.bucket+"/")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		f.objects[key] = object
+		w.Write([]byte(`<CopyObjectResult><ETag>` + object.etag + `</ETag></CopyObjectResult>`))
+	case r.Method == http.MethodPut:
+		if _, exists := f.objects[key]; exists && r.Header.Get("If-None-Match") == "*" {
+			writeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		object := fakeObject{
+			data:    data,
+			modTime: time.Now().UTC().Truncate(time.Second),
+			etag:    fmt.Sprintf(`"%x"`, md5.Sum(data)),
+		}
+		f.objects[key] = object
+		w.Header().Set("ETag", object.etag)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodDelete:
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			object, ok := f.objects[key]
+			if !ok {
+				writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			if object.etag != ifMatch {
+				writeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+		}
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) get(w http.ResponseWriter, r *http.Request, key string) {
+	object, ok := f.objects[key]
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	writeObjectHeaders(w, object)
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rangeHeader, "bytes="), "-"))
+		if err != nil || start >= len(object.data) {
+			writeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(object.data)-1, len(object.data)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(object.data[start:])
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(object.data)
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	delimiter := r.URL.Query().Get("delimiter")
+	maxKeys, err := strconv.Atoi(r.URL.Query().Get("max-keys"))
+	if err != nil {
+		maxKeys = 1000
+	}
+	keys := []string{}
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	result := listBucketResult{Name: f.bucket, Prefix: prefix, MaxKeys: maxKeys}
+	seenPrefixes := map[string]bool{}
+	for _, key := range keys {
+		if result.KeyCount >= maxKeys {
+			break
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i != -1 {
+				commonPrefix := key[:len(prefix)+i+len(delimiter)]
+				if !seenPrefixes[commonPrefix] {
+					seenPrefixes[commonPrefix] = true
+					result.CommonPrefixes = append(result.CommonPrefixes, listBucketPrefix{Prefix: commonPrefix})
+					result.KeyCount++
+				}
+				continue
+			}
+		}
+		result.Contents = append(result.Contents, listBucketContents{
+			Key:          key,
+			LastModified: f.objects[key].modTime.Format("2006-01-02T15:04:05.000Z"),
+			ETag:         f.objects[key].etag,
+			Size:         len(f.objects[key].data),
+		})
+		result.KeyCount++
+	}
+	out, err := xml.Marshal(result)
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError")
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write(out)
+}
+
+func writeObjectHeaders(w http.ResponseWriter, object fakeObject) {
+	w.Header().Set("ETag", object.etag)
+	w.Header().Set("Last-Modified", object.modTime.Format(http.TimeFormat))
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>` + code + `</Code><Message>` + code + `</Message></Error>`))
+}
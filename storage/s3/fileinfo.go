@@ -0,0 +1,35 @@
+package s3storage
+
+import (
+	"io/fs"
+	"time"
+)
+
+type FileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (f FileInfo) Name() string {
+	return f.name
+}
+func (f FileInfo) Size() int64 {
+	return f.size
+}
+func (f FileInfo) Mode() fs.FileMode {
+	if f.isDir {
+		return fs.ModeDir | 0700
+	}
+	return 0600
+}
+func (f FileInfo) ModTime() time.Time {
+	return f.modTime
+}
+func (f FileInfo) IsDir() bool {
+	return f.isDir
+}
+func (f FileInfo) Sys() any {
+	return nil
+}
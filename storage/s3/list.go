@@ -9,17 +9,33 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
+// ReadDir returns the file and directory names directly under pathname, like os.ReadDir
 func (s *S3Storage) ReadDir(pathname string) ([]string, error) {
-	objectList, err := s.s3Client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
-		Bucket: aws.String(s.bucketname),
-		Prefix: aws.String(s.prefix + "/" + strings.TrimLeft(pathname, "/")),
-	})
-	if err != nil {
-		return []string{}, fmt.Errorf("list object error: %s", err)
+	return s.ReadDirContext(context.Background(), pathname)
+}
+
+func (s *S3Storage) ReadDirContext(ctx context.Context, pathname string) ([]string, error) {
+	prefix := s.key(pathname)
+	if prefix != "" {
+		prefix += "/"
 	}
-	res := make([]string, len(objectList.Contents))
-	for k, object := range objectList.Contents {
-		res[k] = *object.Key
+	res := []string{}
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucketname),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		objectList, err := paginator.NextPage(ctx)
+		if err != nil {
+			return []string{}, fmt.Errorf("list object error: %w", err)
+		}
+		for _, commonPrefix := range objectList.CommonPrefixes {
+			res = append(res, strings.TrimSuffix(strings.TrimPrefix(aws.ToString(commonPrefix.Prefix), prefix), "/"))
+		}
+		for _, object := range objectList.Contents {
+			res = append(res, strings.TrimPrefix(aws.ToString(object.Key), prefix))
+		}
 	}
 	return res, nil
 }
@@ -0,0 +1,98 @@
+package s3storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+type lockObject struct {
+	ID      string    `json:"id"`
+	Expires time.Time `json:"expires"`
+}
+
+type s3Lease struct {
+	storage *S3Storage
+	key     string
+	etag    string
+}
+
+// Lock creates name.lock with a conditional write (If-None-Match), so only one instance can create it.
+// An expired lock object is removed with a conditional delete (If-Match), so only one instance can take it over.
+func (s *S3Storage) Lock(name string, ttl time.Duration) (storage.Lease, error) {
+	key := s.key(name + storage.LOCK_SUFFIX)
+	return storage.RetryLock(storage.DEFAULT_LOCK_TIMEOUT, func() (storage.Lease, error) {
+		body, err := json.Marshal(lockObject{ID: uuid.NewString(), Expires: time.Now().Add(ttl)})
+		if err != nil {
+			return nil, fmt.Errorf("lock marshal error: %s", err)
+		}
+		out, err := s.s3Client.PutObject(context.Background(), &s3.PutObjectInput{
+			Bucket:      aws.String(s.bucketname),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(body),
+			IfNoneMatch: aws.String("*"),
+		})
+		if err == nil {
+			return &s3Lease{storage: s, key: key, etag: aws.ToString(out.ETag)}, nil
+		}
+		if !isConditionFailed(err) {
+			return nil, fmt.Errorf("put lock error: %s", err)
+		}
+		return nil, s.removeExpiredLock(key)
+	})
+}
+
+func (s *S3Storage) removeExpiredLock(key string) error {
+	out, err := s.s3Client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketname),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) { // just unlocked
+			return nil
+		}
+		return fmt.Errorf("get lock error: %s", err)
+	}
+	defer out.Body.Close()
+	body, err := io.ReadAll(out.Body)
+	if err != nil {
+		return fmt.Errorf("read lock error: %s", err)
+	}
+	var lock lockObject
+	err = json.Unmarshal(body, &lock)
+	if err == nil && time.Now().Before(lock.Expires) {
+		return nil
+	}
+	_, err = s.s3Client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+		Bucket:  aws.String(s.bucketname),
+		Key:     aws.String(key),
+		IfMatch: out.ETag,
+	})
+	if err != nil && !isConditionFailed(err) && !isNotFound(err) {
+		return fmt.Errorf("delete expired lock error: %s", err)
+	}
+	return nil
+}
+
+func (l *s3Lease) Unlock() error {
+	_, err := l.storage.s3Client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+		Bucket:  aws.String(l.storage.bucketname),
+		Key:     aws.String(l.key),
+		IfMatch: aws.String(l.etag),
+	})
+	if err != nil {
+		if isConditionFailed(err) || isNotFound(err) {
+			return fmt.Errorf("lock expired and was taken over")
+		}
+		return fmt.Errorf("delete lock error: %s", err)
+	}
+	return nil
+}
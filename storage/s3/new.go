@@ -9,12 +9,20 @@ import (
 )
 
 func New(bucketname, prefix string) (*S3Storage, error) {
-	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	sdkConfig, err := config.LoadDefaultConfig(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("config load error: %s", err)
 	}
 	s3Client := s3.NewFromConfig(sdkConfig)
 
+	return NewWithClient(s3Client, bucketname, prefix)
+}
+
+// NewWithClient returns S3 storage using an existing client (e.g. for S3-compatible endpoints)
+func NewWithClient(s3Client *s3.Client, bucketname, prefix string) (*S3Storage, error) {
+	if bucketname == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
 	return &S3Storage{
 		bucketname: bucketname,
 		prefix:     prefix,
@@ -1,15 +1,35 @@
 package s3storage
 
 import (
+	"context"
+	"fmt"
 	"io/fs"
+	"net/url"
+	"path"
 	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
-func (l *S3Storage) FileExists(filename string) bool {
-	return false
+// key returns the object key of a file, including the prefix
+func (s *S3Storage) key(name string) string {
+	return strings.TrimLeft(path.Join(s.prefix, name), "/")
+}
+
+func (s *S3Storage) FileExists(filename string) bool {
+	return s.FileExistsContext(context.Background(), filename)
 }
 
-func (l *S3Storage) ConfigPath(filename string) string {
+func (s *S3Storage) FileExistsContext(ctx context.Context, filename string) bool {
+	_, err := s.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketname),
+		Key:    aws.String(s.key(filename)),
+	})
+	return err == nil
+}
+
+func (s *S3Storage) ConfigPath(filename string) string {
 	return CONFIG_PATH + "/" + strings.TrimLeft(filename, "/")
 }
 
@@ -17,26 +37,96 @@ func (s *S3Storage) GetPath() string {
 	return s.prefix
 }
 
-func (l *S3Storage) EnsurePath(pathname string) error {
-	return nil
+func (s *S3Storage) EnsurePath(pathname string) error {
+	return nil // directories don't exist in object storage
 }
 
-func (l *S3Storage) EnsureOwnership(filename, login string) error {
+func (s *S3Storage) EnsureOwnership(filename, login string) error {
 	return nil
 }
 
-func (l *S3Storage) Remove(name string) error {
+func (s *S3Storage) Remove(name string) error {
+	return s.RemoveContext(context.Background(), name)
+}
+
+func (s *S3Storage) RemoveContext(ctx context.Context, name string) error {
+	if !s.FileExistsContext(ctx, name) {
+		return notFoundError(name)
+	}
+	_, err := s.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucketname),
+		Key:    aws.String(s.key(name)),
+	})
+	if err != nil {
+		return fmt.Errorf("delete object error: %w", err)
+	}
 	return nil
 }
 
-func (l *S3Storage) Rename(oldName, newName string) error {
+func (s *S3Storage) Rename(oldName, newName string) error {
+	return s.RenameContext(context.Background(), oldName, newName)
+}
+
+func (s *S3Storage) RenameContext(ctx context.Context, oldName, newName string) error {
+	copySource := &url.URL{Path: s.bucketname + "/" + s.key(oldName)}
+	_, err := s.s3Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucketname),
+		Key:        aws.String(s.key(newName)),
+		CopySource: aws.String(copySource.EscapedPath()),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return notFoundError(oldName)
+		}
+		return fmt.Errorf("copy object error: %w", err)
+	}
+	_, err = s.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucketname),
+		Key:    aws.String(s.key(oldName)),
+	})
+	if err != nil {
+		return fmt.Errorf("delete object error: %w", err)
+	}
 	return nil
 }
 
-func (l *S3Storage) EnsurePermissions(name string, mode fs.FileMode) error {
+func (s *S3Storage) EnsurePermissions(name string, mode fs.FileMode) error {
 	return nil
 }
 
-func (l *S3Storage) FileInfo(name string) (fs.FileInfo, error) {
-	return nil, nil
+func (s *S3Storage) FileInfo(name string) (fs.FileInfo, error) {
+	return s.FileInfoContext(context.Background(), name)
+}
+
+func (s *S3Storage) FileInfoContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	object, err := s.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketname),
+		Key:    aws.String(s.key(name)),
+	})
+	if err == nil {
+		return FileInfo{
+			name:    path.Base(name),
+			size:    aws.ToInt64(object.ContentLength),
+			modTime: aws.ToTime(object.LastModified),
+		}, nil
+	}
+	if !isNotFound(err) {
+		return nil, fmt.Errorf("head object error: %w", err)
+	}
+	// no object found, check whether it's a directory (a prefix with objects)
+	objectList, err := s.s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucketname),
+		Prefix:  aws.String(s.key(name) + "/"),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list object error: %w", err)
+	}
+	if len(objectList.Contents) == 0 {
+		return nil, notFoundError(name)
+	}
+	return FileInfo{
+		name:  path.Base(name),
+		isDir: true,
+	}, nil
 }
@@ -1,17 +1,113 @@
 package s3storage
 
 import (
+	"context"
+	"fmt"
 	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/in4it/go-devops-platform/storage"
 )
 
-func (l *S3Storage) ReadFile(name string) ([]byte, error) {
-	return nil, nil
+func (s *S3Storage) ReadFile(name string) ([]byte, error) {
+	return s.ReadFileContext(context.Background(), name)
+}
+
+func (s *S3Storage) ReadFileContext(ctx context.Context, name string) ([]byte, error) {
+	body, err := s.OpenFileContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("read object error (%s): %w", name, err)
+	}
+	return data, nil
+}
+
+func (s *S3Storage) OpenFilesFromPos(names []string, pos int64) ([]io.ReadCloser, error) {
+	return s.OpenFilesFromPosContext(context.Background(), names, pos)
+}
+
+func (s *S3Storage) OpenFilesFromPosContext(ctx context.Context, names []string, pos int64) ([]io.ReadCloser, error) {
+	readers := []io.ReadCloser{}
+	if pos < 0 {
+		return readers, nil
+	}
+	for _, name := range names {
+		object, err := s.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucketname),
+			Key:    aws.String(s.key(name)),
+		})
+		if err != nil && isNotFound(err) && s.FileExistsContext(ctx, name+storage.COMPRESSED_SUFFIX) {
+			data, err := s.ReadFileContext(ctx, name+storage.COMPRESSED_SUFFIX)
+			if err != nil {
+				closeAll(readers)
+				return nil, fmt.Errorf("cannot read file (%s): %w", name+storage.COMPRESSED_SUFFIX, err)
+			}
+			var reader io.ReadCloser
+			reader, pos, err = storage.OpenCompressedFromPos(name+storage.COMPRESSED_SUFFIX, data, pos)
+			if err != nil {
+				closeAll(readers)
+				return nil, err
+			}
+			if reader != nil {
+				readers = append(readers, reader)
+			}
+			continue
+		}
+		if err != nil {
+			closeAll(readers)
+			if isNotFound(err) {
+				return nil, fmt.Errorf("cannot open file (%s): %w", name, notFoundError(name))
+			}
+			return nil, fmt.Errorf("cannot get file stat (%s): %w", name, err)
+		}
+		size := aws.ToInt64(object.ContentLength)
+		if size <= pos {
+			pos -= size
+			continue
+		}
+		input := &s3.GetObjectInput{
+			Bucket: aws.String(s.bucketname),
+			Key:    aws.String(s.key(name)),
+		}
+		if pos > 0 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", pos))
+		}
+		file, err := s.s3Client.GetObject(ctx, input)
+		if err != nil {
+			closeAll(readers)
+			return nil, fmt.Errorf("cannot open file (%s): %w", name, err)
+		}
+		pos = 0
+		readers = append(readers, file.Body)
+	}
+	return readers, nil
+}
+
+func (s *S3Storage) OpenFile(name string) (io.ReadCloser, error) {
+	return s.OpenFileContext(context.Background(), name)
 }
 
-func (l *S3Storage) OpenFilesFromPos(names []string, pos int64) ([]io.ReadCloser, error) {
-	return nil, nil
+func (s *S3Storage) OpenFileContext(ctx context.Context, name string) (io.ReadCloser, error) {
+	object, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketname),
+		Key:    aws.String(s.key(name)),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, notFoundError(name)
+		}
+		return nil, fmt.Errorf("get object error (%s): %w", name, err)
+	}
+	return object.Body, nil
 }
 
-func (l *S3Storage) OpenFile(name string) (io.ReadCloser, error) {
-	return nil, nil
+func closeAll(readers []io.ReadCloser) {
+	for _, reader := range readers {
+		reader.Close()
+	}
 }
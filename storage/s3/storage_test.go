@@ -0,0 +1,351 @@
+package s3storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"testing"
+	"time"
+
+	storagepkg "github.com/in4it/go-devops-platform/storage"
+)
+
+func TestReadWriteFile(t *testing.T) {
+	storage, fake := newTestStorage(t, "myprefix")
+
+	err := storage.WriteFile(storage.ConfigPath("config.json"), []byte(`{"setupCompleted": true}`))
+	if err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if _, ok := fake.objects["myprefix/config/config.json"]; !ok {
+		t.Fatalf("object not written with prefix")
+	}
+	if !storage.FileExists(storage.ConfigPath("config.json")) {
+		t.Fatalf("expected file to exist")
+	}
+	body, err := storage.ReadFile(storage.ConfigPath("config.json"))
+	if err != nil {
+		t.Fatalf("read file error: %s", err)
+	}
+	if string(body) != `{"setupCompleted": true}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+	fileInfo, err := storage.FileInfo(storage.ConfigPath("config.json"))
+	if err != nil {
+		t.Fatalf("file info error: %s", err)
+	}
+	if fileInfo.Size() != int64(len(body)) || fileInfo.Name() != "config.json" || fileInfo.IsDir() {
+		t.Fatalf("unexpected file info: %+v", fileInfo)
+	}
+	if fileInfo.ModTime().IsZero() {
+		t.Fatalf("modtime is zero")
+	}
+	dirInfo, err := storage.FileInfo("config")
+	if err != nil {
+		t.Fatalf("file info error (dir): %s", err)
+	}
+	if !dirInfo.IsDir() {
+		t.Fatalf("expected directory")
+	}
+
+	if storage.FileExists(storage.ConfigPath("users.json")) {
+		t.Fatalf("expected file not to exist")
+	}
+	_, err = storage.ReadFile(storage.ConfigPath("users.json"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist error, got: %s", err)
+	}
+	_, err = storage.FileInfo(storage.ConfigPath("users.json"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist error, got: %s", err)
+	}
+}
+
+func TestAppendFile(t *testing.T) {
+	storage, _ := newTestStorage(t, "")
+
+	for _, line := range []string{"line1\n", "line2\n"} {
+		err := storage.AppendFile("stats/logins.log", []byte(line))
+		if err != nil {
+			t.Fatalf("append error: %s", err)
+		}
+	}
+	writer, err := storage.OpenFileForAppending("stats/logins.log")
+	if err != nil {
+		t.Fatalf("open for appending error: %s", err)
+	}
+	writer.Write([]byte("line3\n"))
+	err = writer.Close()
+	if err != nil {
+		t.Fatalf("close error: %s", err)
+	}
+	body, err := storage.ReadFile("stats/logins.log")
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	if string(body) != "line1\nline2\nline3\n" {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	writer, err = storage.OpenFileForWriting("stats/logins.log")
+	if err != nil {
+		t.Fatalf("open for writing error: %s", err)
+	}
+	writer.Write([]byte("overwritten"))
+	writer.Close()
+	body, err = storage.ReadFile("stats/logins.log")
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	if string(body) != "overwritten" {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
+
+func TestReadDirRemoveRename(t *testing.T) {
+	storage, _ := newTestStorage(t, "prefix")
+
+	for _, name := range []string{"config/config.json", "config/users.json", "config/pki/private.pem", "config/pki/public.pem"} {
+		err := storage.WriteFile(name, []byte(name))
+		if err != nil {
+			t.Fatalf("write error: %s", err)
+		}
+	}
+	entries, err := storage.ReadDir("config")
+	if err != nil {
+		t.Fatalf("readdir error: %s", err)
+	}
+	slices.Sort(entries)
+	if !slices.Equal(entries, []string{"config.json", "pki", "users.json"}) {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+
+	err = storage.Rename("config/users.json", "config/users.json.old")
+	if err != nil {
+		t.Fatalf("rename error: %s", err)
+	}
+	if storage.FileExists("config/users.json") || !storage.FileExists("config/users.json.old") {
+		t.Fatalf("rename didn't move file")
+	}
+	err = storage.Rename("config/doesnotexist", "config/new")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist error, got: %s", err)
+	}
+
+	err = storage.Remove("config/users.json.old")
+	if err != nil {
+		t.Fatalf("remove error: %s", err)
+	}
+	if storage.FileExists("config/users.json.old") {
+		t.Fatalf("file not removed")
+	}
+	err = storage.Remove("config/users.json.old")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist error, got: %s", err)
+	}
+}
+
+func TestOpenFilesFromPos(t *testing.T) {
+	storage, _ := newTestStorage(t, "prefix")
+
+	contents1 := []byte(`this is the first file`)
+	contents2 := []byte(`this is the second file`)
+	if err := storage.WriteFile("1.txt", contents1); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if err := storage.WriteFile("2.txt", contents2); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	tests := []struct {
+		pos       int64
+		expected  string
+		openFiles int
+	}{
+		{0, "this is the first filethis is the second file", 2},
+		{5, "is the first filethis is the second file", 2},
+		{int64(len(contents1)), "this is the second file", 1},
+		{int64(len(contents1) - 1), "ethis is the second file", 2},
+		{int64(len(contents1) + 1), "his is the second file", 1},
+		{int64(len(contents1) + len(contents2)), "", 0},
+		{-5, "", 0},
+	}
+	for _, test := range tests {
+		files, err := storage.OpenFilesFromPos([]string{"1.txt", "2.txt"}, test.pos)
+		if err != nil {
+			t.Fatalf("open file error: %s", err)
+		}
+		contents := bytes.NewBuffer([]byte{})
+		for _, file := range files {
+			body, err := io.ReadAll(file)
+			if err != nil {
+				t.Fatalf("could not read file: %s", err)
+			}
+			file.Close()
+			contents.Write(body)
+		}
+		if test.expected != contents.String() {
+			t.Fatalf("unexpected output (pos %d): expected '%s' got '%s'", test.pos, test.expected, contents.String())
+		}
+		if test.openFiles != len(files) {
+			t.Fatalf("unexpected open files (pos %d): expected %d got %d", test.pos, test.openFiles, len(files))
+		}
+	}
+	_, err := storage.OpenFilesFromPos([]string{"1.txt", "3.txt"}, 0)
+	if err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
+
+func TestOpenFilesFromPosCompressed(t *testing.T) {
+	storage, _ := newTestStorage(t, "prefix")
+
+	compressed := bytes.NewBuffer([]byte{})
+	gzipWriter := gzip.NewWriter(compressed)
+	gzipWriter.Write([]byte("first file"))
+	gzipWriter.Close()
+	if err := storage.WriteFile("1.txt"+storagepkg.COMPRESSED_SUFFIX, compressed.Bytes()); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if err := storage.WriteFile("2.txt", []byte("second file")); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	files, err := storage.OpenFilesFromPos([]string{"1.txt", "2.txt"}, 6)
+	if err != nil {
+		t.Fatalf("open file error: %s", err)
+	}
+	contents := bytes.NewBuffer([]byte{})
+	for _, file := range files {
+		body, err := io.ReadAll(file)
+		if err != nil {
+			t.Fatalf("could not read file: %s", err)
+		}
+		file.Close()
+		contents.Write(body)
+	}
+	if contents.String() != "filesecond file" {
+		t.Fatalf("unexpected output: %s", contents.String())
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s, _ := newTestStorage(t, "prefix")
+	s.SetWatchInterval(10 * time.Millisecond)
+
+	if err := s.WriteFile("config/config.json", []byte("1")); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	events, err := s.Watch(context.Background(), "config")
+	if err != nil {
+		t.Fatalf("watch error: %s", err)
+	}
+	if err := s.WriteFile("config/config.json", []byte("2")); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	if err := s.WriteFile("other/file.txt", []byte("2")); err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	select {
+	case event := <-events:
+		if event.Name != "config/config.json" || event.Type != storagepkg.EventWrite {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for event")
+	}
+	if err := s.Remove("config/config.json"); err != nil {
+		t.Fatalf("remove error: %s", err)
+	}
+	select {
+	case event := <-events:
+		if event.Name != "config/config.json" || event.Type != storagepkg.EventRemove {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for event")
+	}
+}
+
+func TestLock(t *testing.T) {
+	s, fake := newTestStorage(t, "prefix")
+
+	lease, err := s.Lock("config/users.json", time.Minute)
+	if err != nil {
+		t.Fatalf("lock error: %s", err)
+	}
+	if _, ok := fake.objects["prefix/config/users.json.lock"]; !ok {
+		t.Fatalf("lock object not found")
+	}
+	acquired := make(chan storagepkg.Lease)
+	go func() {
+		lease2, err := s.Lock("config/users.json", time.Minute)
+		if err != nil {
+			t.Errorf("lock error: %s", err)
+		}
+		acquired <- lease2
+	}()
+	select {
+	case <-acquired:
+		t.Fatalf("lock acquired while held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := lease.Unlock(); err != nil {
+		t.Fatalf("unlock error: %s", err)
+	}
+	select {
+	case lease2 := <-acquired:
+		if err := lease2.Unlock(); err != nil {
+			t.Fatalf("unlock error: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for lock")
+	}
+
+	// an expired lock is taken over
+	expired, err := s.Lock("config/users.json", -time.Second)
+	if err != nil {
+		t.Fatalf("lock error: %s", err)
+	}
+	lease, err = s.Lock("config/users.json", time.Minute)
+	if err != nil {
+		t.Fatalf("lock error: %s", err)
+	}
+	if err := expired.Unlock(); err == nil {
+		t.Fatalf("expected error when unlocking an expired lock that was taken over")
+	}
+	if err := lease.Unlock(); err != nil {
+		t.Fatalf("unlock error: %s", err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	storage, _ := newTestStorage(t, "myprefix")
+
+	if _, ok := storagepkg.WithContext(storage).(*S3Storage); !ok {
+		t.Fatalf("expected s3 storage to support a context natively")
+	}
+	err := storage.WriteFileContext(context.Background(), storage.ConfigPath("config.json"), []byte("1"))
+	if err != nil {
+		t.Fatalf("write file error: %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = storage.ReadFileContext(ctx, storage.ConfigPath("config.json"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled error, got: %v", err)
+	}
+	err = storage.WriteFileContext(ctx, storage.ConfigPath("config.json"), []byte("2"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled error, got: %v", err)
+	}
+	err = storagepkg.WalkContext(ctx, storage, "config", func(name string) error {
+		t.Fatalf("unexpected file in canceled walk: %s", name)
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("expected error walking with a canceled context")
+	}
+}
@@ -0,0 +1,58 @@
+package s3storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+// SetWatchInterval sets how often the bucket is polled for changes
+func (s *S3Storage) SetWatchInterval(interval time.Duration) {
+	s.watchInterval = interval
+}
+
+// Watch polls the ETags of the objects under prefix and sends an event when an object is written or removed
+func (s *S3Storage) Watch(ctx context.Context, prefix string) (<-chan storage.Event, error) {
+	interval := s.watchInterval
+	if interval == 0 {
+		interval = storage.DEFAULT_WATCH_INTERVAL
+	}
+	return storage.Poll(ctx, interval, func() (storage.WatchState, error) {
+		// a scan shouldn't take longer than the interval, otherwise the next scan is delayed
+		scanCtx, cancel := context.WithTimeout(ctx, interval)
+		defer cancel()
+		return s.scan(scanCtx, prefix)
+	})
+}
+
+func (s *S3Storage) scan(ctx context.Context, prefix string) (storage.WatchState, error) {
+	state := storage.WatchState{}
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucketname),
+		Prefix: aws.String(s.key(prefix)),
+	})
+	for paginator.HasMorePages() {
+		objectList, err := paginator.NextPage(ctx)
+		if err != nil {
+			return state, fmt.Errorf("list object error: %s", err)
+		}
+		for _, object := range objectList.Contents {
+			state[s.name(aws.ToString(object.Key))] = aws.ToString(object.ETag)
+		}
+	}
+	return state, nil
+}
+
+// name returns the storage name of an object key (the inverse of key)
+func (s *S3Storage) name(key string) string {
+	prefix := strings.Trim(s.prefix, "/")
+	if prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, prefix+"/")
+}
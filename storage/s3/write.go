@@ -3,33 +3,70 @@ package s3storage
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 func (s *S3Storage) WriteFile(name string, data []byte) error {
-	_, err := s.s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
+	return s.WriteFileContext(context.Background(), name, data)
+}
+
+func (s *S3Storage) WriteFileContext(ctx context.Context, name string, data []byte) error {
+	_, err := s.s3Client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket: aws.String(s.bucketname),
-		Key:    aws.String(name),
+		Key:    aws.String(s.key(name)),
 		Body:   bytes.NewReader(data),
 	})
 	if err != nil {
-		return fmt.Errorf("put object error: %s", err)
+		return fmt.Errorf("put object error: %w", err)
 	}
 	return nil
 }
 
+// AppendFile appends using read-modify-write, as objects can't be appended to
 func (s *S3Storage) AppendFile(name string, data []byte) error {
-	return nil
+	return s.AppendFileContext(context.Background(), name, data)
 }
 
+func (s *S3Storage) AppendFileContext(ctx context.Context, name string, data []byte) error {
+	existing, err := s.ReadFileContext(ctx, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return s.WriteFileContext(ctx, name, append(existing, data...))
+}
+
+// OpenFileForWriting returns a writer that uploads the object on Close()
 func (s *S3Storage) OpenFileForWriting(name string) (io.WriteCloser, error) {
-	return nil, nil
+	return &objectWriter{storage: s, name: name}, nil
 }
 
+// OpenFileForAppending returns a writer that uploads the existing and the newly written data on Close()
 func (s *S3Storage) OpenFileForAppending(name string) (io.WriteCloser, error) {
-	return nil, nil
+	existing, err := s.ReadFile(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	writer := &objectWriter{storage: s, name: name}
+	writer.buf.Write(existing)
+	return writer, nil
+}
+
+type objectWriter struct {
+	storage *S3Storage
+	name    string
+	buf     bytes.Buffer
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *objectWriter) Close() error {
+	return w.storage.WriteFile(w.name, w.buf.Bytes())
 }
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/in4it/go-devops-platform/logging"
+)
+
+// Snapshotter is implemented by storage that keeps snapshots of previous versions of a file
+type Snapshotter interface {
+	Snapshots(name string) ([]string, error) // most recent snapshot first
+}
+
+// ReadFileWithFallback reads a file and passes it to decode. When reading or decoding fails,
+// the most recent snapshot that can be decoded is used (if the storage keeps snapshots).
+func ReadFileWithFallback(storage Iface, name string, decode func(data []byte) error) error {
+	data, err := storage.ReadFile(name)
+	if err == nil {
+		err = decode(data)
+		if err == nil {
+			return nil
+		}
+	}
+	snapshotter, ok := storage.(Snapshotter)
+	if !ok {
+		return err
+	}
+	snapshots, snapshotErr := snapshotter.Snapshots(name)
+	if snapshotErr != nil {
+		logging.ErrorLog(fmt.Errorf("could not list snapshots of %s: %s", name, snapshotErr))
+		return err
+	}
+	for _, snapshot := range snapshots {
+		snapshotData, readErr := storage.ReadFile(snapshot)
+		if readErr != nil {
+			continue
+		}
+		if decode(snapshotData) == nil {
+			logging.ErrorLog(fmt.Errorf("could not load %s (%s). Using last good snapshot: %s", name, err, snapshot))
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path"
+)
+
+// Walk calls fn for every file under root (recursively), using only ReadDir and FileInfo
+func Walk(storage Iface, root string, fn func(name string) error) error {
+	return WalkContext(context.Background(), storage, root, fn)
+}
+
+// WalkContext is Walk with a context. The walk stops when the context is done.
+func WalkContext(ctx context.Context, storage Iface, root string, fn func(name string) error) error {
+	contextStorage := WithContext(storage)
+	entries, err := contextStorage.ReadDirContext(ctx, root)
+	if err != nil {
+		return fmt.Errorf("readdir error (%s): %s", root, err)
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := path.Join(root, entry)
+		fileInfo, err := contextStorage.FileInfoContext(ctx, name)
+		if err == nil && fileInfo.IsDir() {
+			err = WalkContext(ctx, storage, name, fn)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err = fn(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
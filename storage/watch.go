@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/in4it/go-devops-platform/logging"
+)
+
+const DEFAULT_WATCH_INTERVAL = 5 * time.Second
+
+type EventType string
+
+const (
+	EventWrite  EventType = "write"
+	EventRemove EventType = "remove"
+)
+
+// Event is sent when a file changes (possibly by another instance sharing the same storage)
+type Event struct {
+	Name string
+	Type EventType
+}
+
+// Watcher is implemented by storage that can notify about changed files. The channel is closed when ctx is done.
+type Watcher interface {
+	Watch(ctx context.Context, prefix string) (<-chan Event, error)
+}
+
+// WatchState keeps a fingerprint (e.g. modification time or ETag) per file, for polling based watchers
+type WatchState map[string]string
+
+// Diff returns the events to go from the previous state to the new state
+func (previous WatchState) Diff(current WatchState) []Event {
+	events := []Event{}
+	for name, fingerprint := range current {
+		if previousFingerprint, ok := previous[name]; !ok || previousFingerprint != fingerprint {
+			events = append(events, Event{Name: name, Type: EventWrite})
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			events = append(events, Event{Name: name, Type: EventRemove})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Name < events[j].Name
+	})
+	return events
+}
+
+// Poll calls scan every interval and sends an event for every file that changed between two scans, until ctx is done
+func Poll(ctx context.Context, interval time.Duration, scan func() (WatchState, error)) (<-chan Event, error) {
+	state, err := scan()
+	if err != nil {
+		return nil, fmt.Errorf("initial scan error: %s", err)
+	}
+	events := make(chan Event, 10)
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			newState, err := scan()
+			if err != nil {
+				logging.ErrorLog(fmt.Errorf("watch scan error: %s", err))
+				continue
+			}
+			for _, event := range state.Diff(newState) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			state = newState
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,196 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/in4it/go-devops-platform/logging"
+)
+
+type EventType string
+
+const (
+	EVENT_USER_CREATED          EventType = "user.created"
+	EVENT_USER_UPDATED          EventType = "user.updated"
+	EVENT_USER_ROLE_CHANGED     EventType = "user.role-changed"
+	EVENT_USER_PASSWORD_CHANGED EventType = "user.password-changed"
+	EVENT_USER_LOGIN            EventType = "user.login"
+	EVENT_USER_DISABLED         EventType = "user.disabled"    // the access of the user needs to be disabled (suspended, deleted with a grace period or failed re-authentication)
+	EVENT_USER_REACTIVATED      EventType = "user.reactivated" // the access of the user can be enabled again
+	EVENT_USER_DELETED          EventType = "user.deleted"
+)
+
+// Event is a user lifecycle event. The user is without password.
+type Event struct {
+	Type         EventType `json:"type"`
+	Time         time.Time `json:"time"`
+	User         User      `json:"user"`
+	PreviousRole string    `json:"previousRole,omitempty"` // role changed
+	Method       string    `json:"method,omitempty"`       // login: local, oidc or saml
+}
+
+type Subscriber func(event Event) error
+
+type subscription struct {
+	name       string
+	eventTypes []EventType
+	fn         Subscriber
+	queued     bool
+}
+
+func (s subscription) matches(eventType EventType) bool {
+	return len(s.eventTypes) == 0 || slices.Contains(s.eventTypes, eventType)
+}
+
+// Dispatcher delivers the user lifecycle events to the subscribers (e.g. the apps)
+type Dispatcher struct {
+	mu            sync.RWMutex
+	subscriptions []subscription
+	outbox        *Outbox
+}
+
+// NewDispatcher returns a dispatcher that delivers to the queued subscribers through the outbox. Without outbox, queued subscribers are called directly.
+func NewDispatcher(outbox *Outbox) *Dispatcher {
+	return &Dispatcher{outbox: outbox}
+}
+
+// Outbox returns the outbox of the queued subscribers (can be nil)
+func (d *Dispatcher) Outbox() *Outbox {
+	return d.outbox
+}
+
+// Subscribe calls fn for the event types, or for every event when no event types are given. The name identifies the subscriber in errors.
+func (d *Dispatcher) Subscribe(name string, fn Subscriber, eventTypes ...EventType) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions = append(d.subscriptions, subscription{name: name, eventTypes: eventTypes, fn: fn})
+}
+
+// SubscribeQueued is like Subscribe, but fn is called by the outbox worker (see ProcessOutbox), and retried with backoff when it fails.
+// Use it for subscribers that change state outside of the user store, like disabling the access of a user.
+func (d *Dispatcher) SubscribeQueued(name string, fn Subscriber, eventTypes ...EventType) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions = append(d.subscriptions, subscription{name: name, eventTypes: eventTypes, fn: fn, queued: d.outbox != nil})
+}
+
+// Unsubscribe removes the subscriptions with the name
+func (d *Dispatcher) Unsubscribe(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions = slices.DeleteFunc(d.subscriptions, func(s subscription) bool { return s.name == name })
+}
+
+// Dispatch calls the subscribers of the event, in the order they subscribed, and adds the event to the outbox for the queued subscribers.
+// The password and the mfa factors of the user are removed from the event.
+// Every subscriber is called, the errors are joined. Errors of queued subscribers are retried by the outbox worker and not returned.
+func (d *Dispatcher) Dispatch(event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.User = withoutPassword(event.User)
+	event.User.Factors = nil // the mfa secrets don't belong in the outbox (or in a backup of it)
+	d.mu.RLock()
+	subscriptions := slices.Clone(d.subscriptions)
+	d.mu.RUnlock()
+	var errs []error
+	queued := []string{}
+	for _, s := range subscriptions {
+		if !s.matches(event.Type) {
+			continue
+		}
+		if s.queued {
+			if !slices.Contains(queued, s.name) {
+				queued = append(queued, s.name)
+			}
+			continue
+		}
+		if err := s.fn(event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", s.name, err))
+		}
+	}
+	if len(queued) > 0 {
+		if err := d.outbox.enqueue(event, queued); err != nil {
+			errs = append(errs, fmt.Errorf("outbox: %s", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver calls the queued subscriptions of the subscriber for the event
+func (d *Dispatcher) deliver(entry OutboxEntry) error {
+	d.mu.RLock()
+	subscriptions := slices.Clone(d.subscriptions)
+	d.mu.RUnlock()
+	var errs []error
+	for _, s := range subscriptions {
+		if s.queued && s.name == entry.Subscriber && s.matches(entry.Event.Type) {
+			if err := s.fn(entry.Event); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ProcessOutbox delivers the outbox entries that are due to the queued subscribers of this dispatcher. It returns the number of delivered entries.
+func (d *Dispatcher) ProcessOutbox() (int, error) {
+	if d.outbox == nil {
+		return 0, nil
+	}
+	d.mu.RLock()
+	subscribers := []string{}
+	for _, s := range d.subscriptions {
+		if s.queued && !slices.Contains(subscribers, s.name) {
+			subscribers = append(subscribers, s.name)
+		}
+	}
+	d.mu.RUnlock()
+	if len(subscribers) == 0 {
+		return 0, nil
+	}
+	entries, err := d.outbox.claim(subscribers)
+	if err != nil {
+		return 0, fmt.Errorf("outbox claim error: %s", err)
+	}
+	delivered := 0
+	var errs []error
+	for _, entry := range entries {
+		deliveryErr := d.deliver(entry)
+		if deliveryErr == nil {
+			delivered++
+		} else {
+			logging.ErrorLog(fmt.Errorf("event %s for user %s to %s (attempt %d): %s", entry.Event.Type, entry.Event.User.ID, entry.Subscriber, entry.Attempts, deliveryErr))
+		}
+		if err := d.outbox.complete(entry, deliveryErr); err != nil {
+			errs = append(errs, fmt.Errorf("outbox complete error: %s", err))
+		}
+	}
+	return delivered, errors.Join(errs...)
+}
+
+// Events returns the dispatcher of the user lifecycle events
+func (u *UserStore) Events() *Dispatcher {
+	return u.events
+}
+
+// emit dispatches events of changes that are already saved. Errors are logged.
+func (u *UserStore) emit(events ...Event) {
+	for _, event := range events {
+		if err := u.events.Dispatch(event); err != nil {
+			logging.ErrorLog(fmt.Errorf("event %s for user %s: %s", event.Type, event.User.ID, err))
+		}
+	}
+}
+
+// updateEvents returns the events of an update of the user
+func updateEvents(previous, user User) []Event {
+	events := []Event{{Type: EVENT_USER_UPDATED, User: user}}
+	if previous.Role != user.Role {
+		events = append(events, Event{Type: EVENT_USER_ROLE_CHANGED, User: user, PreviousRole: previous.Role})
+	}
+	return events
+}
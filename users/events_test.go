@@ -0,0 +1,94 @@
+package users
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestDispatcher(t *testing.T) {
+	d := NewDispatcher(nil)
+	received := []string{}
+	d.Subscribe("all", func(event Event) error {
+		received = append(received, "all:"+string(event.Type))
+		return nil
+	})
+	d.Subscribe("deleted", func(event Event) error {
+		received = append(received, "deleted:"+event.User.Login)
+		return fmt.Errorf("cleanup failed")
+	}, EVENT_USER_DELETED)
+	d.Subscribe("failing", func(event Event) error {
+		return fmt.Errorf("unavailable")
+	}, EVENT_USER_DELETED)
+
+	if err := d.Dispatch(Event{Type: EVENT_USER_CREATED, User: User{Login: "john"}}); err != nil {
+		t.Fatalf("dispatch error: %s", err)
+	}
+	err := d.Dispatch(Event{Type: EVENT_USER_DELETED, User: User{Login: "john", Password: "secret"}})
+	if err == nil || !strings.Contains(err.Error(), "deleted: cleanup failed") || !strings.Contains(err.Error(), "failing: unavailable") {
+		t.Fatalf("expected joined errors of all subscribers, got: %v", err)
+	}
+	if strings.Join(received, ",") != "all:user.created,all:user.deleted,deleted:john" {
+		t.Fatalf("unexpected events received: %v", received)
+	}
+
+	d.Unsubscribe("failing")
+	d.Unsubscribe("deleted")
+	if err := d.Dispatch(Event{Type: EVENT_USER_DELETED}); err != nil {
+		t.Fatalf("expected no error after unsubscribe, got: %s", err)
+	}
+}
+
+func TestUserStoreEvents(t *testing.T) {
+	store, err := NewUserStore(&memorystorage.MockMemoryStorage{}, -1)
+	if err != nil {
+		t.Fatalf("new user store error: %s", err)
+	}
+	events := []Event{}
+	store.Events().Subscribe("test", func(event Event) error {
+		if event.User.Password != "" {
+			t.Errorf("password not stripped from %s event", event.Type)
+		}
+		if _, err := store.GetUserByLogin(event.User.Login); err != nil && event.Type != EVENT_USER_DELETED { // subscribers can use the store
+			t.Errorf("get user error: %s", err)
+		}
+		events = append(events, event)
+		return nil
+	})
+
+	user, err := store.AddUser(User{Login: "john", Password: "secret", Role: ROLE_USER})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	user.Role = ROLE_ADMIN
+	if err := store.UpdateUser(user); err != nil {
+		t.Fatalf("update user error: %s", err)
+	}
+	if err := store.UpdatePassword(user.ID, "secret2"); err != nil {
+		t.Fatalf("update password error: %s", err)
+	}
+	if err := store.RecordLogin(user.ID, "local"); err != nil {
+		t.Fatalf("record login error: %s", err)
+	}
+	if err := store.DeleteUserByID(user.ID); err != nil {
+		t.Fatalf("delete user error: %s", err)
+	}
+
+	expected := []EventType{EVENT_USER_CREATED, EVENT_USER_UPDATED, EVENT_USER_ROLE_CHANGED, EVENT_USER_PASSWORD_CHANGED, EVENT_USER_LOGIN, EVENT_USER_DELETED}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got: %+v", len(expected), events)
+	}
+	for k, event := range events {
+		if event.Type != expected[k] || event.User.ID != user.ID || event.Time.IsZero() {
+			t.Fatalf("unexpected event %d: %+v", k, event)
+		}
+	}
+	if events[2].PreviousRole != ROLE_USER || events[2].User.Role != ROLE_ADMIN {
+		t.Fatalf("unexpected role changed event: %+v", events[2])
+	}
+	if events[4].Method != "local" || events[4].User.LastLogin.IsZero() {
+		t.Fatalf("unexpected login event: %+v", events[4])
+	}
+}
@@ -0,0 +1,193 @@
+package users
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+const GROUPSTORE_FILENAME = "groups.json"
+
+type Group struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	ExternalID  string `json:"externalID,omitempty"` // id of the group in the identity provider (SCIM)
+}
+
+// GroupStore keeps the group definitions. Membership is stored in the users (see UserStore.AddUserToGroup).
+type GroupStore struct {
+	Groups  []Group `json:"groups"`
+	mu      sync.Mutex
+	storage storage.Iface
+	hash    [sha256.Size]byte // hash of the last loaded or saved groups.json
+}
+
+func NewGroupStore(storageClient storage.Iface) (*GroupStore, error) {
+	groupStore := &GroupStore{
+		Groups:  []Group{},
+		storage: storageClient,
+	}
+	if !storageClient.FileExists(storageClient.ConfigPath(GROUPSTORE_FILENAME)) {
+		return groupStore, nil
+	}
+	err := storage.ReadFileWithFallback(storageClient, storageClient.ConfigPath(GROUPSTORE_FILENAME), func(data []byte) error {
+		groupStore.Groups = nil
+		groupStore.hash = sha256.Sum256(data)
+		return json.NewDecoder(bytes.NewBuffer(data)).Decode(&groupStore.Groups)
+	})
+	if err != nil {
+		return groupStore, fmt.Errorf("config read error: %s", err)
+	}
+	return groupStore, nil
+}
+
+// Reload reads groups.json again when it was changed outside of this group store. Returns true when the groups were reloaded.
+func (g *GroupStore) Reload() (bool, error) {
+	if !g.storage.FileExists(g.storage.ConfigPath(GROUPSTORE_FILENAME)) {
+		return false, nil
+	}
+	data, err := g.storage.ReadFile(g.storage.ConfigPath(GROUPSTORE_FILENAME))
+	if err != nil {
+		return false, fmt.Errorf("config read error: %s", err)
+	}
+	hash := sha256.Sum256(data)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if hash == g.hash {
+		return false, nil
+	}
+	var groups []Group
+	err = json.NewDecoder(bytes.NewBuffer(data)).Decode(&groups)
+	if err != nil {
+		return false, fmt.Errorf("decode input error: %s", err)
+	}
+	g.Groups = groups
+	g.hash = hash
+	return true, nil
+}
+
+// modify reloads the groups, runs fn and saves the groups, while holding the storage lock
+func (g *GroupStore) modify(fn func() error) error {
+	return storage.WithLock(g.storage, g.storage.ConfigPath(GROUPSTORE_FILENAME), func() error {
+		_, err := g.Reload()
+		if err != nil {
+			return fmt.Errorf("reload error: %s", err)
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		err = fn()
+		if err != nil {
+			return err
+		}
+		out, err := json.Marshal(g.Groups)
+		if err != nil {
+			return fmt.Errorf("group store marshal error: %s", err)
+		}
+		err = g.storage.WriteFile(g.storage.ConfigPath(GROUPSTORE_FILENAME), out)
+		if err != nil {
+			return fmt.Errorf("group store write error: %s", err)
+		}
+		g.hash = sha256.Sum256(out)
+		return nil
+	})
+}
+
+func (g *GroupStore) ListGroups() []Group {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	groups := make([]Group, len(g.Groups))
+	copy(groups, g.Groups)
+	return groups
+}
+
+func (g *GroupStore) GetGroupByID(id string) (Group, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, group := range g.Groups {
+		if group.ID == id {
+			return group, nil
+		}
+	}
+	return Group{}, fmt.Errorf("group not found")
+}
+
+func (g *GroupStore) GetGroupByName(name string) (Group, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, group := range g.Groups {
+		if group.Name == name {
+			return group, nil
+		}
+	}
+	return Group{}, fmt.Errorf("group not found")
+}
+
+func (g *GroupStore) GetGroupByExternalID(externalID string) (Group, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, group := range g.Groups {
+		if group.ExternalID != "" && group.ExternalID == externalID {
+			return group, nil
+		}
+	}
+	return Group{}, fmt.Errorf("group not found")
+}
+
+func (g *GroupStore) AddGroup(group Group) (Group, error) {
+	if group.Name == "" {
+		return group, fmt.Errorf("group name cannot be empty")
+	}
+	group.ID = uuid.NewString()
+	err := g.modify(func() error {
+		for _, existingGroup := range g.Groups {
+			if existingGroup.Name == group.Name {
+				return fmt.Errorf("group with name '%s' already exists", group.Name)
+			}
+		}
+		g.Groups = append(g.Groups, group)
+		return nil
+	})
+	if err != nil {
+		return Group{}, err
+	}
+	return group, nil
+}
+
+func (g *GroupStore) UpdateGroup(group Group) error {
+	if group.Name == "" {
+		return fmt.Errorf("group name cannot be empty")
+	}
+	return g.modify(func() error {
+		for _, existingGroup := range g.Groups {
+			if existingGroup.Name == group.Name && existingGroup.ID != group.ID {
+				return fmt.Errorf("group with name '%s' already exists", group.Name)
+			}
+		}
+		for k, existingGroup := range g.Groups {
+			if existingGroup.ID == group.ID {
+				g.Groups[k] = group
+				return nil
+			}
+		}
+		return fmt.Errorf("group not found")
+	})
+}
+
+// deleteGroup removes the group definition. Use UserStore.DeleteGroup to also remove the memberships.
+func (g *GroupStore) deleteGroup(id string) error {
+	return g.modify(func() error {
+		for k, group := range g.Groups {
+			if group.ID == id {
+				g.Groups = append(g.Groups[:k], g.Groups[k+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("group not found")
+	})
+}
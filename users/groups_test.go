@@ -0,0 +1,83 @@
+package users
+
+import (
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestGroupMembership(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	store, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	user, err := store.AddUser(User{Login: "john", Password: "mypass"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	developers, err := store.Groups().AddGroup(Group{Name: "developers"})
+	if err != nil {
+		t.Fatalf("add group error: %s", err)
+	}
+	_, err = store.Groups().AddGroup(Group{Name: "developers"})
+	if err == nil {
+		t.Fatalf("expected error adding a group with the same name")
+	}
+	operations, err := store.Groups().AddGroup(Group{Name: "operations"})
+	if err != nil {
+		t.Fatalf("add group error: %s", err)
+	}
+	if err = store.AddUserToGroup(user.ID, "doesnotexist"); err == nil {
+		t.Fatalf("expected error adding user to a group that doesn't exist")
+	}
+	for _, groupID := range []string{developers.ID, operations.ID, developers.ID} {
+		if err = store.AddUserToGroup(user.ID, groupID); err != nil {
+			t.Fatalf("add user to group error: %s", err)
+		}
+	}
+
+	// groups and membership are persisted
+	store2, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	user, err = store2.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("get user error: %s", err)
+	}
+	if len(store2.GetUserGroups(user)) != 2 {
+		t.Fatalf("expected 2 groups, got: %v", store2.GetUserGroups(user))
+	}
+	if !store2.IsMemberOf(user, "operations") || store2.IsMemberOf(user, "admins") {
+		t.Fatalf("unexpected group membership")
+	}
+	if len(store2.ListGroupMembers(developers.ID)) != 1 {
+		t.Fatalf("expected 1 member")
+	}
+
+	// deleting a group removes the membership
+	err = store2.DeleteGroup(operations.ID)
+	if err != nil {
+		t.Fatalf("delete group error: %s", err)
+	}
+	user, _ = store2.GetUserByID(user.ID)
+	if store2.IsMemberOf(user, "operations") || len(user.Groups) != 1 {
+		t.Fatalf("expected membership to be removed, got: %v", user.Groups)
+	}
+	err = store2.RemoveUserFromGroup(user.ID, developers.ID)
+	if err != nil {
+		t.Fatalf("remove user from group error: %s", err)
+	}
+	if len(store2.ListGroupMembers(developers.ID)) != 0 {
+		t.Fatalf("expected no members")
+	}
+
+	// the first store picks up the changes of the second store
+	if _, err := store.Groups().Reload(); err != nil {
+		t.Fatalf("reload error: %s", err)
+	}
+	if len(store.Groups().ListGroups()) != 1 {
+		t.Fatalf("expected 1 group after reload, got: %v", store.Groups().ListGroups())
+	}
+}
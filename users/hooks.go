@@ -0,0 +1,27 @@
+package users
+
+import "github.com/in4it/go-devops-platform/storage"
+
+type DisableFunc func(storage.Iface, User) error
+type ReactivateFunc func(storage.Iface, User) error
+type DeleteFunc func(storage.Iface, User) error
+
+// UserHooks are called on the disabled, reactivated and deleted events, through the outbox. Use Events().Subscribe for new subscribers.
+type UserHooks struct {
+	DisableFunc    DisableFunc    `json:"-"`
+	ReactivateFunc ReactivateFunc `json:"-"`
+	DeleteFunc     DeleteFunc     `json:"-"`
+}
+
+// subscribe adds the hooks as queued subscribers of the events
+func (h UserHooks) subscribe(events *Dispatcher, storageClient storage.Iface) {
+	if h.DisableFunc != nil {
+		events.SubscribeQueued("hooks", func(event Event) error { return h.DisableFunc(storageClient, event.User) }, EVENT_USER_DISABLED)
+	}
+	if h.ReactivateFunc != nil {
+		events.SubscribeQueued("hooks", func(event Event) error { return h.ReactivateFunc(storageClient, event.User) }, EVENT_USER_REACTIVATED)
+	}
+	if h.DeleteFunc != nil {
+		events.SubscribeQueued("hooks", func(event Event) error { return h.DeleteFunc(storageClient, event.User) }, EVENT_USER_DELETED)
+	}
+}
@@ -0,0 +1,287 @@
+package users
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hash formats that can be imported with AddUsers (User.PasswordHash). These hashes are verified on login and replaced by a native hash.
+const (
+	HASH_FORMAT_BCRYPT        = "bcrypt"        // $2a$, $2b$ or $2y$
+	HASH_FORMAT_PBKDF2_SHA256 = "pbkdf2-sha256" // $pbkdf2-sha256$iterations$salt$hash (passlib) or pbkdf2_sha256$iterations$salt$hash (django)
+	HASH_FORMAT_SHA512_CRYPT  = "sha512-crypt"  // $6$rounds=N$salt$hash or $6$salt$hash
+	HASH_FORMAT_ARGON2        = "argon2"        // $argon2id$ or $argon2i$ (PHC string format)
+)
+
+// HashFormat returns the format of the password hash, or an error when the format is not supported
+func HashFormat(hash string) (string, error) {
+	switch {
+	case strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$"):
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return "", fmt.Errorf("invalid bcrypt hash: %s", err)
+		}
+		if cost > bcryptMaxCost {
+			return "", fmt.Errorf("bcrypt cost can't be more than %d", bcryptMaxCost)
+		}
+		return HASH_FORMAT_BCRYPT, nil
+	case strings.HasPrefix(hash, "$pbkdf2-sha256$") || strings.HasPrefix(hash, "pbkdf2_sha256$"):
+		if _, err := parsePBKDF2Hash(hash); err != nil {
+			return "", err
+		}
+		return HASH_FORMAT_PBKDF2_SHA256, nil
+	case strings.HasPrefix(hash, "$6$"):
+		if _, err := parseSHA512CryptHash(hash); err != nil {
+			return "", err
+		}
+		return HASH_FORMAT_SHA512_CRYPT, nil
+	case strings.HasPrefix(hash, "$argon2id$") || strings.HasPrefix(hash, "$argon2i$"):
+		if _, err := parseArgon2Hash(hash); err != nil {
+			return "", err
+		}
+		return HASH_FORMAT_ARGON2, nil
+	}
+	return "", fmt.Errorf("unsupported password hash format")
+}
+
+// verifyForeignHash verifies the password against the imported hash formats (except bcrypt and argon2id, which are native)
+func verifyForeignHash(hash, password string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$pbkdf2-sha256$") || strings.HasPrefix(hash, "pbkdf2_sha256$"):
+		parsed, err := parsePBKDF2Hash(hash)
+		if err != nil {
+			return false
+		}
+		key, err := pbkdf2.Key(sha256.New, password, parsed.salt, parsed.iterations, len(parsed.key))
+		if err != nil {
+			return false
+		}
+		return subtle.ConstantTimeCompare(key, parsed.key) == 1
+	case strings.HasPrefix(hash, "$6$"):
+		parsed, err := parseSHA512CryptHash(hash)
+		if err != nil {
+			return false
+		}
+		return subtle.ConstantTimeCompare([]byte(sha512Crypt([]byte(password), parsed.salt, parsed.rounds)), []byte(parsed.hash)) == 1
+	case strings.HasPrefix(hash, "$argon2i$"):
+		parsed, err := parseArgon2Hash(hash)
+		if err != nil {
+			return false
+		}
+		key := argon2.Key([]byte(password), parsed.salt, parsed.params.Iterations, parsed.params.Memory, parsed.params.Parallelism, uint32(len(parsed.key)))
+		return subtle.ConstantTimeCompare(key, parsed.key) == 1
+	}
+	return false
+}
+
+type pbkdf2Hash struct {
+	iterations int
+	salt       []byte
+	key        []byte
+}
+
+const pbkdf2MaxIterations = 10000000 // limits the cost of a login with an imported hash
+
+func parsePBKDF2Hash(hash string) (pbkdf2Hash, error) {
+	var res pbkdf2Hash
+	django := strings.HasPrefix(hash, "pbkdf2_sha256$")
+	parts := strings.Split(strings.TrimPrefix(hash, "$"), "$")
+	if len(parts) != 4 {
+		return res, fmt.Errorf("invalid pbkdf2-sha256 hash")
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 || iterations > pbkdf2MaxIterations {
+		return res, fmt.Errorf("invalid pbkdf2-sha256 iterations")
+	}
+	res.iterations = iterations
+	if django { // salt is stored as is, the hash is standard base64
+		res.salt = []byte(parts[2])
+		res.key, err = base64.StdEncoding.DecodeString(parts[3])
+	} else { // passlib uses adapted base64 ('.' instead of '+', no padding)
+		res.salt, err = base64.RawStdEncoding.DecodeString(strings.ReplaceAll(parts[2], ".", "+"))
+		if err == nil {
+			res.key, err = base64.RawStdEncoding.DecodeString(strings.ReplaceAll(parts[3], ".", "+"))
+		}
+	}
+	if err != nil || len(res.key) == 0 {
+		return res, fmt.Errorf("invalid pbkdf2-sha256 hash encoding")
+	}
+	return res, nil
+}
+
+// parseArgon2Hash parses argon2id and argon2i hashes in the PHC string format
+func parseArgon2Hash(hash string) (argon2Hash, error) {
+	if strings.HasPrefix(hash, "$argon2i$") {
+		parsed, err := parseArgon2idHash("$argon2id$" + strings.TrimPrefix(hash, "$argon2i$"))
+		if err != nil {
+			return parsed, fmt.Errorf("%s", strings.ReplaceAll(err.Error(), "argon2id", "argon2i"))
+		}
+		parsed.params.Algorithm = "argon2i"
+		return parsed, nil
+	}
+	return parseArgon2idHash(hash)
+}
+
+type sha512CryptHash struct {
+	rounds int
+	salt   []byte
+	hash   string // encoded hash, without rounds and salt
+}
+
+const (
+	sha512CryptDefaultRounds = 5000
+	sha512CryptMinRounds     = 1000
+	sha512CryptMaxRounds     = 1000000 // limits the cost of a login with an imported hash
+	sha512CryptMaxSalt       = 16
+)
+
+func parseSHA512CryptHash(hash string) (sha512CryptHash, error) {
+	res := sha512CryptHash{rounds: sha512CryptDefaultRounds}
+	parts := strings.Split(strings.TrimPrefix(hash, "$6$"), "$")
+	if len(parts) == 3 && strings.HasPrefix(parts[0], "rounds=") {
+		rounds, err := strconv.Atoi(strings.TrimPrefix(parts[0], "rounds="))
+		if err != nil {
+			return res, fmt.Errorf("invalid sha512-crypt rounds")
+		}
+		if rounds > sha512CryptMaxRounds {
+			return res, fmt.Errorf("sha512-crypt rounds can't be more than %d", sha512CryptMaxRounds)
+		}
+		res.rounds = max(rounds, sha512CryptMinRounds)
+		parts = parts[1:]
+	}
+	if len(parts) != 2 || len(parts[1]) != 86 {
+		return res, fmt.Errorf("invalid sha512-crypt hash")
+	}
+	res.salt = []byte(parts[0])
+	res.hash = parts[1]
+	return res, nil
+}
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// sha512Crypt implements the SHA-512 based crypt of glibc ($6$), see https://www.akkadia.org/drepper/SHA-crypt.txt
+// Returns the encoded hash (the last part of the crypt string).
+func sha512Crypt(password, salt []byte, rounds int) string {
+	if len(salt) > sha512CryptMaxSalt {
+		salt = salt[:sha512CryptMaxSalt]
+	}
+	digestB := sha512.New()
+	digestB.Write(password)
+	digestB.Write(salt)
+	digestB.Write(password)
+	b := digestB.Sum(nil)
+
+	digestA := sha512.New()
+	digestA.Write(password)
+	digestA.Write(salt)
+	digestA.Write(repeatBytes(b, len(password)))
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			digestA.Write(b)
+		} else {
+			digestA.Write(password)
+		}
+	}
+	a := digestA.Sum(nil)
+
+	digestP := sha512.New()
+	for range password {
+		digestP.Write(password)
+	}
+	p := repeatBytes(digestP.Sum(nil), len(password))
+
+	digestS := sha512.New()
+	for range 16 + int(a[0]) {
+		digestS.Write(salt)
+	}
+	s := repeatBytes(digestS.Sum(nil), len(salt))
+
+	for i := range rounds {
+		digestC := sha512.New()
+		if i&1 != 0 {
+			digestC.Write(p)
+		} else {
+			digestC.Write(a)
+		}
+		if i%3 != 0 {
+			digestC.Write(s)
+		}
+		if i%7 != 0 {
+			digestC.Write(p)
+		}
+		if i&1 != 0 {
+			digestC.Write(a)
+		} else {
+			digestC.Write(p)
+		}
+		a = digestC.Sum(nil)
+	}
+
+	var out strings.Builder
+	encode := func(b2, b1, b0 byte, n int) {
+		w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+		for range n {
+			out.WriteByte(cryptAlphabet[w&0x3f])
+			w >>= 6
+		}
+	}
+	for i := range 21 { // the bytes are encoded in groups of (i, i+21, i+42), rotated by i%3
+		group := [3]byte{a[i], a[i+21], a[i+42]}
+		switch i % 3 {
+		case 1:
+			group = [3]byte{a[i+21], a[i+42], a[i]}
+		case 2:
+			group = [3]byte{a[i+42], a[i], a[i+21]}
+		}
+		encode(group[0], group[1], group[2], 4)
+	}
+	encode(0, 0, a[63], 2)
+	return out.String()
+}
+
+// repeatBytes repeats b until it's n bytes long
+func repeatBytes(b []byte, n int) []byte {
+	res := make([]byte, 0, n)
+	for len(res) < n {
+		res = append(res, b[:min(len(b), n-len(res))]...)
+	}
+	return res
+}
+
+// hashImportedPasswords hashes the Password of the users, or moves the PasswordHash to the Password. The hashing is done outside of the lock.
+func (u *UserStore) hashImportedPasswords(users []User) error {
+	for k := range users {
+		if users[k].PasswordHash != "" { // imported hash, replaced by a native hash on the first login
+			if _, err := HashFormat(users[k].PasswordHash); err != nil {
+				return fmt.Errorf("user %s: %s", users[k].Login, err)
+			}
+			users[k].Password = users[k].PasswordHash
+			users[k].PasswordHash = ""
+			users[k].PasswordChangedAt = TimeOrEmpty(time.Now())
+			continue
+		}
+		if users[k].Password == "" {
+			continue
+		}
+		if err := u.CheckPassword(users[k].Password); err != nil {
+			return fmt.Errorf("user %s: %w", users[k].Login, err)
+		}
+		users[k].PasswordChangedAt = TimeOrEmpty(time.Now())
+		hashedPassword, err := u.hashPassword(users[k].Password)
+		if err != nil {
+			return fmt.Errorf("HashPassword error: %s", err)
+		}
+		users[k].Password = hashedPassword
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package users
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSHA512Crypt(t *testing.T) {
+	// test vectors from https://www.akkadia.org/drepper/SHA-crypt.txt, verified with glibc crypt(3)
+	for hash, password := range map[string]string{
+		"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1":                    "Hello world!",
+		"$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.": "Hello world!",
+		"$6$rounds=1400$anotherlongsalts$POfYwTEok97VWcjxIiSOjiykti.o/pQs.wPvMxQ6Fm7I6IoYN3CmLs66x9t0oSwbtEW7o7UmJEiDwGqd8p4ur1":  "a very much longer text to encrypt.  This one even stretches over morethan one line.",
+		"$6$rounds=77777$short$5G47exGNp2Eaj4PV3ZivQ9U4w9knbS2LVWL93.QF.VbgOlSYP1yWDSamjnwq4.2YqseR3jsWxbQK7RkY6iRTf0":            "a short string",
+		"$6$rounds=1000$toolongsaltstrin$bnWU.mhP9MWd1VTFLg67DGmAXBwjlG2eTLrV4Oh42onYzW7Yb4LdQu8I8/wHJ/hvsaBc8fP.d3Oi9eiBxXZH5.":  "short",
+	} {
+		if format, err := HashFormat(hash); err != nil || format != HASH_FORMAT_SHA512_CRYPT {
+			t.Fatalf("hash format error: %s (%s)", format, err)
+		}
+		if !VerifyPassword(hash, password) {
+			t.Fatalf("password doesn't match hash %s", hash)
+		}
+		if VerifyPassword(hash, password+"x") {
+			t.Fatalf("wrong password matches hash %s", hash)
+		}
+	}
+}
+
+func TestImportHashFormats(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	key, err := pbkdf2.Key(sha256.New, "secret", salt, 1000, 32)
+	if err != nil {
+		t.Fatalf("pbkdf2 error: %s", err)
+	}
+	ab64 := func(b []byte) string { return strings.ReplaceAll(base64.RawStdEncoding.EncodeToString(b), "+", ".") }
+	djangoKey, err := pbkdf2.Key(sha256.New, "secret", []byte("djangosalt"), 1000, 32)
+	if err != nil {
+		t.Fatalf("pbkdf2 error: %s", err)
+	}
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt error: %s", err)
+	}
+	argon2iKey := argon2.Key([]byte("secret"), salt, 3, 4096, 1, 32)
+	argon2idHash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatalf("hash error: %s", err)
+	}
+	for hash, format := range map[string]string{
+		"$pbkdf2-sha256$1000$" + ab64(salt) + "$" + ab64(key):                           HASH_FORMAT_PBKDF2_SHA256,
+		"pbkdf2_sha256$1000$djangosalt$" + base64.StdEncoding.EncodeToString(djangoKey): HASH_FORMAT_PBKDF2_SHA256,
+		"$2y$" + strings.TrimPrefix(string(bcryptHash), "$2a$"):                         HASH_FORMAT_BCRYPT,
+		fmt.Sprintf("$argon2i$v=19$m=4096,t=3,p=1$%s$%s", ab64(salt), ab64(argon2iKey)): HASH_FORMAT_ARGON2,
+		argon2idHash: HASH_FORMAT_ARGON2,
+	} {
+		hashFormat, err := HashFormat(hash)
+		if err != nil {
+			t.Fatalf("hash format error (%s): %s", hash, err)
+		}
+		if hashFormat != format {
+			t.Fatalf("wrong format for %s: %s", hash, hashFormat)
+		}
+		if !VerifyPassword(hash, "secret") || VerifyPassword(hash, "wrong") {
+			t.Fatalf("verify mismatch for %s", hash)
+		}
+	}
+	for _, hash := range []string{"", "secret", "$1$md5$hash", "$pbkdf2-sha256$abc$salt$hash", "$6$salt$tooshort"} {
+		if _, err := HashFormat(hash); err == nil {
+			t.Fatalf("expected error for hash %q", hash)
+		}
+	}
+}
+
+func TestImportHashParameterBounds(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	key := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	for _, hash := range []string{
+		fmt.Sprintf("$argon2id$v=19$m=65536,t=0,p=1$%s$%s", salt, key),
+		fmt.Sprintf("$argon2id$v=19$m=65536,t=2,p=0$%s$%s", salt, key),
+		fmt.Sprintf("$argon2id$v=19$m=4294967295,t=2,p=1$%s$%s", salt, key),
+		fmt.Sprintf("$argon2id$v=19$m=4,t=2,p=1$%s$%s", salt, key),
+		fmt.Sprintf("$argon2i$v=19$m=65536,t=0,p=1$%s$%s", salt, key),
+		"$pbkdf2-sha256$0$" + salt + "$" + key,
+		"$pbkdf2-sha256$2000000000$" + salt + "$" + key,
+		fmt.Sprintf("$argon2id$v=19$m=1048576,t=2,p=1$%s$%s", salt, key),
+		"$2a$31$" + strings.Repeat("a", 53),
+		"$6$rounds=999999999$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+	} {
+		if _, err := HashFormat(hash); err == nil {
+			t.Fatalf("expected error for hash %q", hash)
+		}
+		if VerifyPassword(hash, "secret") {
+			t.Fatalf("expected verify to fail for hash %q", hash)
+		}
+	}
+}
+
+func TestAddUsersWithPasswordHash(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	store, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	sha512CryptHash := "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"
+	_, err = store.AddUsers([]User{{Login: "john", PasswordHash: "$1$unsupported"}})
+	if err == nil {
+		t.Fatalf("expected unsupported hash error")
+	}
+	_, err = store.AddUsers([]User{{Login: "john", PasswordHash: sha512CryptHash}, {Login: "jane", Password: "plaintext"}})
+	if err != nil {
+		t.Fatalf("add users error: %s", err)
+	}
+	if _, ok := store.AuthUser("jane", "plaintext"); !ok {
+		t.Fatalf("expected auth to succeed for plain text password")
+	}
+	if _, ok := store.AuthUser("john", "Hello world!"); !ok {
+		t.Fatalf("expected auth to succeed with imported hash")
+	}
+	store2, err := NewUserStore(storage, 99) // the rehash is saved
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	store2.mu.RLock()
+	user, _ := store2.lookup(store2.index.byLogin, "john")
+	store2.mu.RUnlock()
+	if !strings.HasPrefix(user.Password, "$argon2id$") {
+		t.Fatalf("expected imported hash to be replaced by argon2id: %s", user.Password)
+	}
+	if _, ok := store2.AuthUser("john", "Hello world!"); !ok {
+		t.Fatalf("expected auth to succeed after rehash")
+	}
+}
@@ -0,0 +1,208 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	SUSPENDED_REASON_INACTIVE = "inactive"
+	SUSPENDED_REASON_EXPIRED  = "expired"
+)
+
+const (
+	INACTIVITY_ACTION_SUSPEND = "suspend"
+	INACTIVITY_ACTION_WARN    = "warn"
+)
+
+// InactivityPolicy suspends the users that didn't login for InactiveDays, and the users of which the account expired (see User.ExpiresAt).
+// The exemptions only apply to the inactivity: an expiry date is always enforced.
+type InactivityPolicy struct {
+	InactiveDays int      `json:"inactiveDays,omitempty"` // 0 means users are not suspended for inactivity
+	WarningDays  int      `json:"warningDays,omitempty"`  // warn the user this many days before the suspension, 0 means no warning
+	ExemptUsers  []string `json:"exemptUsers,omitempty"`  // logins
+	ExemptRoles  []string `json:"exemptRoles,omitempty"`
+}
+
+func (p InactivityPolicy) Validate() error {
+	if p.InactiveDays < 0 || p.WarningDays < 0 {
+		return fmt.Errorf("inactivity policy values can't be negative")
+	}
+	return nil
+}
+
+func (p InactivityPolicy) exempt(user User) bool {
+	return slices.Contains(p.ExemptUsers, user.Login) || slices.Contains(p.ExemptRoles, user.Role)
+}
+
+// InactivityAction is a suspension or a warning of the inactivity policy
+type InactivityAction struct {
+	UserID       string    `json:"userID"`
+	Login        string    `json:"login"`
+	Action       string    `json:"action"`
+	Reason       string    `json:"reason"`
+	SuspendAt    time.Time `json:"suspendAt"`
+	LastActivity time.Time `json:"lastActivity,omitzero"`
+}
+
+// lastActivity returns the last login, creation or reactivation of the user. It's zero for users that were never seen.
+func lastActivity(user User) time.Time {
+	last := time.Time(user.LastLogin)
+	for _, t := range []TimeOrEmpty{user.CreatedAt, user.ReactivatedAt} {
+		if time.Time(t).After(last) {
+			last = time.Time(t)
+		}
+	}
+	return last
+}
+
+// evaluate returns the action for the user, if any
+func (p InactivityPolicy) evaluate(user User, now time.Time) (InactivityAction, bool) {
+	if user.Suspended || user.Deleted() || user.Invited {
+		return InactivityAction{}, false
+	}
+	action := InactivityAction{UserID: user.ID, Login: user.Login, LastActivity: lastActivity(user)}
+	if p.InactiveDays > 0 && !p.exempt(user) && !action.LastActivity.IsZero() {
+		action.SuspendAt = action.LastActivity.AddDate(0, 0, p.InactiveDays)
+		action.Reason = SUSPENDED_REASON_INACTIVE
+	}
+	if !user.ExpiresAt.IsZero() && (action.SuspendAt.IsZero() || time.Time(user.ExpiresAt).Before(action.SuspendAt)) {
+		action.SuspendAt = time.Time(user.ExpiresAt)
+		action.Reason = SUSPENDED_REASON_EXPIRED
+	}
+	if action.SuspendAt.IsZero() {
+		return action, false
+	}
+	if !action.SuspendAt.After(now) {
+		action.Action = INACTIVITY_ACTION_SUSPEND
+		return action, true
+	}
+	warnFrom := action.SuspendAt.AddDate(0, 0, -p.WarningDays)
+	if p.WarningDays > 0 && !now.Before(warnFrom) && time.Time(user.InactivityWarningSentAt).Before(warnFrom) { // one warning per suspension date
+		action.Action = INACTIVITY_ACTION_WARN
+		return action, true
+	}
+	return action, false
+}
+
+// InactivityReport returns the actions the policy would take now, without taking them
+func (u *UserStore) InactivityReport(policy InactivityPolicy) []InactivityAction {
+	now := time.Now()
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	actions := []InactivityAction{}
+	for _, user := range u.Users {
+		if action, ok := policy.evaluate(user, now); ok {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// errInactivityActionOutdated is returned when the user changed since the report, e.g. by another instance
+var errInactivityActionOutdated = fmt.Errorf("inactivity action is outdated")
+
+// ApplyInactivityPolicy suspends the users with the reason, and dispatches the disabled event.
+// warn is called for the users that get a warning (can be nil). The warning is reserved before warn is called, so users are warned once per suspension date,
+// also when multiple instances apply the policy. The reservation is undone when warn fails.
+// Every action is evaluated again on the latest users before it is taken.
+func (u *UserStore) ApplyInactivityPolicy(policy InactivityPolicy, warn func(user User, action InactivityAction) error) ([]InactivityAction, error) {
+	actions := u.InactivityReport(policy)
+	applied := []InactivityAction{}
+	var errs []error
+	for _, action := range actions {
+		var err error
+		switch action.Action {
+		case INACTIVITY_ACTION_SUSPEND:
+			err = u.suspend(policy, action)
+		case INACTIVITY_ACTION_WARN:
+			if warn == nil {
+				continue
+			}
+			err = u.warn(policy, action, warn)
+		}
+		if errors.Is(err, errInactivityActionOutdated) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %s", action.Action, action.Login, err))
+			continue
+		}
+		applied = append(applied, action)
+	}
+	return applied, errors.Join(errs...)
+}
+
+// evaluateLatest returns the position of the user and the action, when it's still the same as the reported action. Must be called in modify.
+func (u *UserStore) evaluateLatest(policy InactivityPolicy, action InactivityAction) (int, InactivityAction, error) {
+	pos, ok := u.index.byID[action.UserID]
+	if !ok {
+		return pos, action, errInactivityActionOutdated
+	}
+	latest, ok := policy.evaluate(u.Users[pos], time.Now())
+	if !ok || latest.Action != action.Action {
+		return pos, action, errInactivityActionOutdated
+	}
+	return pos, latest, nil
+}
+
+func (u *UserStore) suspend(policy InactivityPolicy, action InactivityAction) error {
+	var previous, user User
+	err := u.modify(func() error {
+		pos, latest, err := u.evaluateLatest(policy, action)
+		if err != nil {
+			return err
+		}
+		previous = u.Users[pos]
+		u.Users[pos].Suspended = true
+		u.Users[pos].SuspendedReason = latest.Reason
+		user = u.Users[pos]
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	u.emit(updateEvents(previous, user)...)
+	err = u.events.Dispatch(Event{Type: EVENT_USER_DISABLED, User: user})
+	if err != nil {
+		return fmt.Errorf("disabled event error for user %s: %s", user.ID, err)
+	}
+	return nil
+}
+
+func (u *UserStore) warn(policy InactivityPolicy, action InactivityAction, warn func(user User, action InactivityAction) error) error {
+	var user User
+	var previousSentAt TimeOrEmpty
+	sentAt := TimeOrEmpty(time.Now())
+	err := u.modify(func() error {
+		pos, latest, err := u.evaluateLatest(policy, action)
+		if err != nil {
+			return err
+		}
+		action = latest
+		previousSentAt = u.Users[pos].InactivityWarningSentAt
+		u.Users[pos].InactivityWarningSentAt = sentAt
+		user = withoutPassword(u.Users[pos])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	warnErr := warn(user, action)
+	if warnErr == nil {
+		return nil
+	}
+	err = u.modify(func() error {
+		pos, ok := u.index.byID[action.UserID]
+		if ok && time.Time(u.Users[pos].InactivityWarningSentAt).Equal(time.Time(sentAt)) {
+			u.Users[pos].InactivityWarningSentAt = previousSentAt
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s (undo warning reservation error: %s)", warnErr, err)
+	}
+	return warnErr
+}
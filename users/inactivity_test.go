@@ -0,0 +1,126 @@
+package users
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestInactivityPolicy(t *testing.T) {
+	disabled := []string{}
+	store, err := NewUserStoreWithHooks(&memorystorage.MockMemoryStorage{}, -1, UserHooks{DisableFunc: func(_ storage.Iface, user User) error {
+		disabled = append(disabled, user.Login)
+		return nil
+	}})
+	if err != nil {
+		t.Fatalf("new user store error: %s", err)
+	}
+	now := time.Now()
+	for _, user := range []User{
+		{Login: "inactive", Role: ROLE_USER, LastLogin: TimeOrEmpty(now.AddDate(0, 0, -31))},
+		{Login: "warned", Role: ROLE_USER, LastLogin: TimeOrEmpty(now.AddDate(0, 0, -25))},
+		{Login: "active", Role: ROLE_USER, LastLogin: TimeOrEmpty(now.AddDate(0, 0, -1))},
+		{Login: "exempt", Role: ROLE_USER, LastLogin: TimeOrEmpty(now.AddDate(0, 0, -100))},
+		{Login: "admin", Role: ROLE_ADMIN, LastLogin: TimeOrEmpty(now.AddDate(0, 0, -100))},
+		{Login: "contractor", Role: ROLE_ADMIN, LastLogin: TimeOrEmpty(now), ExpiresAt: TimeOrEmpty(now.Add(-time.Hour))},
+	} {
+		if _, err := store.AddUser(user); err != nil {
+			t.Fatalf("add user error: %s", err)
+		}
+	}
+	err = store.modify(func() error { // created before the last login
+		for k := range store.Users {
+			store.Users[k].CreatedAt = TimeOrEmpty(now.AddDate(-1, 0, 0))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("modify error: %s", err)
+	}
+	policy := InactivityPolicy{InactiveDays: 30, WarningDays: 7, ExemptUsers: []string{"exempt"}, ExemptRoles: []string{ROLE_ADMIN}}
+	otherInstance, err := NewUserStore(store.storage, -1) // loaded before the policy is applied
+	if err != nil {
+		t.Fatalf("new user store error: %s", err)
+	}
+
+	report := store.InactivityReport(policy)
+	expected := map[string]InactivityAction{
+		"inactive":   {Action: INACTIVITY_ACTION_SUSPEND, Reason: SUSPENDED_REASON_INACTIVE},
+		"warned":     {Action: INACTIVITY_ACTION_WARN, Reason: SUSPENDED_REASON_INACTIVE},
+		"contractor": {Action: INACTIVITY_ACTION_SUSPEND, Reason: SUSPENDED_REASON_EXPIRED},
+	}
+	if len(report) != len(expected) {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	for _, action := range report {
+		if action.Action != expected[action.Login].Action || action.Reason != expected[action.Login].Reason {
+			t.Fatalf("unexpected action: %+v", action)
+		}
+	}
+	if user, _ := store.GetUserByLogin("inactive"); user.Suspended {
+		t.Fatalf("expected the report not to suspend users")
+	}
+
+	warnings := []string{}
+	warn := func(user User, action InactivityAction) error {
+		warnings = append(warnings, user.Login)
+		return nil
+	}
+	if _, err := store.ApplyInactivityPolicy(policy, warn); err != nil {
+		t.Fatalf("apply inactivity policy error: %s", err)
+	}
+	if _, err := store.Events().ProcessOutbox(); err != nil {
+		t.Fatalf("process outbox error: %s", err)
+	}
+	for _, login := range []string{"inactive", "contractor"} {
+		user, _ := store.GetUserByLogin(login)
+		if !user.Suspended || user.SuspendedReason != expected[login].Reason {
+			t.Fatalf("expected %s to be suspended: %+v", login, user)
+		}
+	}
+	if len(disabled) != 2 || len(warnings) != 1 || warnings[0] != "warned" {
+		t.Fatalf("unexpected disabled users %v or warnings %v", disabled, warnings)
+	}
+
+	// the warning is only sent once
+	if actions, err := store.ApplyInactivityPolicy(policy, warn); err != nil || len(actions) != 0 || len(warnings) != 1 {
+		t.Fatalf("expected no new actions: %+v (%v)", actions, err)
+	}
+	// another instance doesn't suspend or warn the same users again
+	if actions, err := otherInstance.ApplyInactivityPolicy(policy, warn); err != nil || len(actions) != 0 || len(warnings) != 1 {
+		t.Fatalf("expected no actions on the other instance: %+v (%v)", actions, err)
+	}
+
+	// reactivation restarts the inactivity period and clears the reason
+	user, _ := store.GetUserByLogin("inactive")
+	user.Suspended = false
+	if err := store.UpdateUser(user); err != nil {
+		t.Fatalf("update user error: %s", err)
+	}
+	user, _ = store.GetUserByLogin("inactive")
+	if user.SuspendedReason != "" || user.ReactivatedAt.IsZero() {
+		t.Fatalf("expected reactivated user: %+v", user)
+	}
+	if report := store.InactivityReport(policy); len(report) != 0 {
+		t.Fatalf("expected empty report after reactivation, got: %+v", report)
+	}
+
+	// the warning is sent again when it failed
+	err = store.modify(func() error {
+		pos := store.index.byLogin["active"]
+		store.Users[pos].LastLogin = TimeOrEmpty(now.AddDate(0, 0, -25))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("modify error: %s", err)
+	}
+	if _, err := store.ApplyInactivityPolicy(policy, func(user User, action InactivityAction) error { return fmt.Errorf("mail error") }); err == nil {
+		t.Fatalf("expected warning error")
+	}
+	if actions, err := store.ApplyInactivityPolicy(policy, warn); err != nil || len(actions) != 1 || warnings[len(warnings)-1] != "active" {
+		t.Fatalf("expected the warning to be sent again: %+v (%v)", actions, err)
+	}
+}
@@ -0,0 +1,47 @@
+package users
+
+// userIndex maps the ID, login and external identifiers to the position of the user in UserStore.Users.
+// When a value is not unique, the first user wins (like a linear scan would).
+type userIndex struct {
+	byID         map[string]int
+	byLogin      map[string]int
+	byOIDCID     map[string]int
+	bySAMLID     map[string]int
+	byExternalID map[string]int
+}
+
+// reindex must be called with mu held, after every change of Users
+func (u *UserStore) reindex() {
+	u.index = userIndex{
+		byID:         make(map[string]int, len(u.Users)),
+		byLogin:      make(map[string]int, len(u.Users)),
+		byOIDCID:     make(map[string]int),
+		bySAMLID:     make(map[string]int),
+		byExternalID: make(map[string]int),
+	}
+	for k, user := range u.Users {
+		addToIndex(u.index.byID, user.ID, k)
+		addToIndex(u.index.byLogin, user.Login, k)
+		addToIndex(u.index.byOIDCID, user.OIDCID, k)
+		addToIndex(u.index.bySAMLID, user.SAMLID, k)
+		addToIndex(u.index.byExternalID, user.ExternalID, k)
+	}
+}
+
+func addToIndex(index map[string]int, key string, pos int) {
+	if key == "" {
+		return
+	}
+	if _, ok := index[key]; !ok {
+		index[key] = pos
+	}
+}
+
+// lookup returns a copy of the user at the position in the index. Must be called with mu held.
+func (u *UserStore) lookup(index map[string]int, key string) (User, bool) {
+	pos, ok := index[key]
+	if !ok || key == "" {
+		return User{}, false
+	}
+	return u.Users[pos], true
+}
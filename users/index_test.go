@@ -0,0 +1,169 @@
+package users
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestIndexLookups(t *testing.T) {
+	store, err := NewUserStore(&memorystorage.MockMemoryStorage{}, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	user, err := store.AddUser(User{Login: "john", OIDCID: "oidc-1", SAMLID: "saml-1", ExternalID: "ext-1"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	_, err = store.AddUser(User{Login: "jane"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	if _, err := store.AddUser(User{Login: "john"}); err == nil {
+		t.Fatalf("expected duplicate login error")
+	}
+	lookups := map[string]func() (User, error){
+		"id":          func() (User, error) { return store.GetUserByID(user.ID) },
+		"login":       func() (User, error) { return store.GetUserByLogin("john") },
+		"oidc":        func() (User, error) { return store.GetUserByOIDCIDs([]string{"unknown", "oidc-1"}) },
+		"saml":        func() (User, error) { return store.GetUserBySAMLID("saml-1") },
+		"external id": func() (User, error) { return store.GetUserByExternalID("ext-1") },
+	}
+	for name, lookup := range lookups {
+		found, err := lookup()
+		if err != nil {
+			t.Fatalf("lookup by %s: %s", name, err)
+		}
+		if found.ID != user.ID {
+			t.Fatalf("lookup by %s: got user %s, expected %s", name, found.ID, user.ID)
+		}
+	}
+
+	// the index must follow deletes
+	if err := store.DeleteUserByLogin("john"); err != nil {
+		t.Fatalf("delete error: %s", err)
+	}
+	if _, err := store.GetUserBySAMLID("saml-1"); err == nil {
+		t.Fatalf("expected user to be deleted")
+	}
+	jane, err := store.GetUserByLogin("jane")
+	if err != nil {
+		t.Fatalf("get user error: %s", err)
+	}
+	if found, err := store.GetUserByID(jane.ID); err != nil || found.Login != "jane" {
+		t.Fatalf("wrong user after delete: %v (%s)", found, err)
+	}
+}
+
+func TestIndexAfterReload(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	store1, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	store2, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	user, err := store1.AddUser(User{Login: "john", SAMLID: "saml-1"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	reloaded, err := store2.Reload()
+	if err != nil {
+		t.Fatalf("reload error: %s", err)
+	}
+	if !reloaded {
+		t.Fatalf("expected store to be reloaded")
+	}
+	found, err := store2.GetUserBySAMLID("saml-1")
+	if err != nil {
+		t.Fatalf("get user error: %s", err)
+	}
+	if found.ID != user.ID {
+		t.Fatalf("wrong user: %s", found.ID)
+	}
+}
+
+func TestAddUsersRollback(t *testing.T) {
+	store, err := NewUserStore(&memorystorage.MockMemoryStorage{}, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	_, err = store.AddUsers([]User{{Login: "a", Password: "pw"}, {Login: "b", Password: "pw"}, {Login: "a", Password: "pw"}})
+	if err == nil {
+		t.Fatalf("expected duplicate login error")
+	}
+	if store.UserCount() != 0 {
+		t.Fatalf("expected no users to be added, got %d", store.UserCount())
+	}
+	if store.LoginExists("a") {
+		t.Fatalf("login a shouldn't be in the index")
+	}
+}
+
+// run with -race
+func TestConcurrentAccess(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	store, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	_, err = store.AddUser(User{Login: "admin", Password: "secret"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := store.AddUser(User{Login: fmt.Sprintf("user-%d", i), SAMLID: fmt.Sprintf("saml-%d", i)})
+			if err != nil {
+				errs <- err
+				return
+			}
+			user.Suspended = true
+			if err := store.UpdateUser(user); err != nil {
+				errs <- err
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				if _, ok := store.AuthUser("admin", "secret"); !ok {
+					errs <- fmt.Errorf("auth failed")
+					return
+				}
+				store.ListUsers()
+				store.LoginExists(fmt.Sprintf("user-%d", i))
+				store.GetUserBySAMLID(fmt.Sprintf("saml-%d", i))
+				if _, err := store.Reload(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent access error: %s", err)
+	}
+	if store.UserCount() != 11 {
+		t.Fatalf("expected 11 users, got %d", store.UserCount())
+	}
+	for i := range 10 {
+		user, err := store.GetUserBySAMLID(fmt.Sprintf("saml-%d", i))
+		if err != nil {
+			t.Fatalf("get user error: %s", err)
+		}
+		if !user.Suspended {
+			t.Fatalf("user %s: expected suspended", user.Login)
+		}
+	}
+}
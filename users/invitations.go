@@ -0,0 +1,63 @@
+package users
+
+import (
+	"fmt"
+	"time"
+)
+
+// InviteUser adds a local user without a password. The user sets the password when accepting the invitation.
+func (u *UserStore) InviteUser(user User) (User, error) {
+	user.Password = ""
+	user.PasswordHash = ""
+	user.Invited = true
+	return u.AddUser(user)
+}
+
+// AcceptInvitation sets the password and adds the factors of an invited user
+func (u *UserStore) AcceptInvitation(userID, password string, factors []Factor) error {
+	err := u.CheckPassword(password)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := u.hashPassword(password)
+	if err != nil {
+		return fmt.Errorf("HashPassword error: %s", err)
+	}
+	var acceptedUser User
+	err = u.modify(func() error {
+		pos, ok := u.index.byID[userID]
+		if !ok {
+			return fmt.Errorf("user not found in database: userID %s", userID)
+		}
+		if !u.Users[pos].Invited {
+			return fmt.Errorf("invitation already accepted")
+		}
+		if u.Users[pos].Suspended || u.Users[pos].Deleted() {
+			return fmt.Errorf("user is suspended or deleted")
+		}
+		u.Users[pos].Password = hashedPassword
+		u.Users[pos].PasswordChangedAt = TimeOrEmpty(time.Now())
+		u.Users[pos].Factors = append(u.Users[pos].Factors, factors...)
+		u.Users[pos].Invited = false
+		acceptedUser = u.Users[pos]
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	u.emit(Event{Type: EVENT_USER_PASSWORD_CHANGED, User: acceptedUser})
+	return nil
+}
+
+// ListInvitedUsers returns the users that didn't accept their invitation yet
+func (u *UserStore) ListInvitedUsers() []User {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	users := []User{}
+	for _, user := range u.Users {
+		if user.Invited {
+			users = append(users, withoutPassword(user))
+		}
+	}
+	return users
+}
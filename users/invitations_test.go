@@ -0,0 +1,44 @@
+package users
+
+import (
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestInviteUser(t *testing.T) {
+	store, err := NewUserStore(&memorystorage.MockMemoryStorage{}, -1)
+	if err != nil {
+		t.Fatalf("new user store error: %s", err)
+	}
+	user, err := store.InviteUser(User{Login: "john", Password: "ignored", Role: ROLE_USER})
+	if err != nil {
+		t.Fatalf("invite user error: %s", err)
+	}
+	if store.UserCount() != 1 {
+		t.Fatalf("invited user should count as a user")
+	}
+	if invited := store.ListInvitedUsers(); len(invited) != 1 || invited[0].ID != user.ID {
+		t.Fatalf("expected user in list of invited users: %+v", invited)
+	}
+	if _, ok := store.AuthUser("john", ""); ok {
+		t.Fatalf("invited user shouldn't be able to login")
+	}
+	err = store.AcceptInvitation(user.ID, "mypassword", []Factor{{Name: "phone", Type: "totp", Secret: "secret"}})
+	if err != nil {
+		t.Fatalf("accept invitation error: %s", err)
+	}
+	if err := store.AcceptInvitation(user.ID, "otherpassword", nil); err == nil {
+		t.Fatalf("expected error when accepting twice")
+	}
+	authUser, ok := store.AuthUser("john", "mypassword")
+	if !ok {
+		t.Fatalf("expected login to work after accepting invitation")
+	}
+	if authUser.Invited || len(authUser.Factors) != 1 || authUser.PasswordChangedAt.IsZero() {
+		t.Fatalf("unexpected user after accepting invitation: %+v", authUser)
+	}
+	if len(store.ListInvitedUsers()) != 0 {
+		t.Fatalf("expected no invited users")
+	}
+}
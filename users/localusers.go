@@ -2,184 +2,278 @@ package users
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func (u *UserStore) AddUser(user User) (User, error) {
 	if user.Login == "" {
 		return user, fmt.Errorf("login cannot be empty")
 	}
-	existingUsers := u.ListUsers()
-	for _, existingUser := range existingUsers {
-		if existingUser.Login == user.Login {
-			return User{}, fmt.Errorf("user with login '%s' already exists", user.Login)
-		}
-	}
 	user.ID = uuid.NewString()
+	user.CreatedAt = TimeOrEmpty(time.Now())
 	if user.Password != "" {
-		hashedPassword, err := HashPassword(user.Password)
+		if err := u.CheckPassword(user.Password); err != nil {
+			return user, err
+		}
+		user.PasswordChangedAt = TimeOrEmpty(time.Now())
+		hashedPassword, err := u.hashPassword(user.Password)
 		if err != nil {
 			return user, fmt.Errorf("HashPassword error: %s", err)
 		}
 		user.Password = hashedPassword
 	}
-	u.Users = append(u.Users, user)
-	if u.autoSave {
-		return user, u.SaveUsers()
+	var existsErr error
+	err := u.modify(func() error {
+		if _, exists := u.index.byLogin[user.Login]; exists {
+			existsErr = fmt.Errorf("user with login '%s' already exists", user.Login)
+			return existsErr
+		}
+		u.Users = append(u.Users, user)
+		return nil
+	})
+	if existsErr != nil {
+		return User{}, existsErr
+	}
+	if err != nil {
+		return user, err
 	}
+	u.emit(Event{Type: EVENT_USER_CREATED, User: user})
 	return user, nil
 }
 
+// AddUsers adds the users in one write. No user is added when one of them fails.
+// Users with a PasswordHash are imported with that hash, other users get their Password hashed.
 func (u *UserStore) AddUsers(users []User) ([]User, error) {
-	createdUsers := []User{}
-	existingUsers := u.ListUsers()
 	for k := range users {
-		for _, existingUser := range existingUsers {
-			if existingUser.Login == users[k].Login {
-				return createdUsers, fmt.Errorf("user with login '%s' already exists", users[k].Login)
-			}
-		}
 		users[k].ID = uuid.NewString()
-		hashedPassword, err := HashPassword(users[k].Password)
-		if err != nil {
-			return createdUsers, fmt.Errorf("HashPassword error: %s", err)
+		users[k].CreatedAt = TimeOrEmpty(time.Now())
+	}
+	err := u.hashImportedPasswords(users)
+	if err != nil {
+		return []User{}, err
+	}
+	createdUsers := []User{}
+	err = u.modify(func() error {
+		createdUsers = []User{}
+		logins := make(map[string]bool)
+		for k := range users {
+			if _, exists := u.index.byLogin[users[k].Login]; exists || logins[users[k].Login] {
+				return fmt.Errorf("user with login '%s' already exists", users[k].Login)
+			}
+			logins[users[k].Login] = true
+			u.Users = append(u.Users, users[k])
+			createdUsers = append(createdUsers, users[k])
 		}
-		users[k].Password = hashedPassword
-		u.Users = append(u.Users, users[k])
-		existingUsers = append(existingUsers, users[k])
-		createdUsers = append(createdUsers, users[k])
+		return nil
+	})
+	if err != nil {
+		return []User{}, err
 	}
-	if u.autoSave {
-		return createdUsers, u.SaveUsers()
+	for _, user := range createdUsers {
+		u.emit(Event{Type: EVENT_USER_CREATED, User: user})
 	}
 	return createdUsers, nil
 }
 
 func (u *UserStore) GetUserByID(id string) (User, error) {
-	for _, user := range u.Users {
-		if user.ID == id {
-			user.Password = ""
-			return user, nil
-		}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	user, ok := u.lookup(u.index.byID, id)
+	if !ok {
+		return User{}, fmt.Errorf("User not found")
 	}
-	return User{}, fmt.Errorf("User not found")
+	return withoutPassword(user), nil
 }
 
 func (u *UserStore) GetUserByLogin(login string) (User, error) {
-	for _, user := range u.Users {
-		if user.Login == login {
-			user.Password = ""
-			return user, nil
-		}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	user, ok := u.lookup(u.index.byLogin, login)
+	if !ok {
+		return User{}, fmt.Errorf("User not found")
 	}
-	return User{}, fmt.Errorf("User not found")
+	return withoutPassword(user), nil
 }
+
+var errUserNotFound = fmt.Errorf("User not found")
+
+// DeleteUserByLogin removes the user and dispatches the deleted event. DeleteUser applies the deletion grace period.
 func (u *UserStore) DeleteUserByLogin(login string) error {
-	for k, user := range u.Users {
-		if user.Login == login {
-			u.Users = append(u.Users[:k], u.Users[k+1:]...)
-			if u.autoSave {
-				return u.SaveUsers()
-			}
-			return nil
-		}
+	user, err := u.deleteUser(func() (int, bool) {
+		pos, ok := u.index.byLogin[login]
+		return pos, ok
+	})
+	if err != nil {
+		return err
 	}
-	return fmt.Errorf("User not found")
+	u.emit(Event{Type: EVENT_USER_DELETED, User: user})
+	return nil
 }
 
+// DeleteUserByID removes the user and dispatches the deleted event. DeleteUser applies the deletion grace period.
 func (u *UserStore) DeleteUserByID(id string) error {
-	for k, user := range u.Users {
-		if user.ID == id {
-			u.Users = append(u.Users[:k], u.Users[k+1:]...)
-			if u.autoSave {
-				return u.SaveUsers()
-			}
-			return nil
-		}
+	user, err := u.deleteUser(func() (int, bool) {
+		pos, ok := u.index.byID[id]
+		return pos, ok
+	})
+	if err != nil {
+		return err
 	}
-	return fmt.Errorf("User not found")
+	u.emit(Event{Type: EVENT_USER_DELETED, User: user})
+	return nil
 }
 
-func (u *UserStore) AuthUser(login, password string) (User, bool) {
-	for _, user := range u.Users {
-		if user.Login == login {
-			passwordMatch := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-			if passwordMatch == nil {
-				return user, true
-			}
+// deleteUser removes the user at the position returned by find (called with the lock held)
+func (u *UserStore) deleteUser(find func() (int, bool)) (User, error) {
+	var deletedUser User
+	err := u.modify(func() error {
+		pos, ok := find()
+		if !ok {
+			return errUserNotFound
 		}
+		deletedUser = u.Users[pos]
+		u.Users = append(u.Users[:pos], u.Users[pos+1:]...)
+		return nil
+	})
+	return deletedUser, err
+}
+
+// AuthUser checks the password of the user. The password hash is compared without holding the lock.
+// Hashes with outdated parameters are replaced.
+func (u *UserStore) AuthUser(login, password string) (User, bool) {
+	u.mu.RLock()
+	user, ok := u.lookup(u.index.byLogin, login)
+	u.mu.RUnlock()
+	if !ok || user.Invited {
+		return User{}, false
+	}
+	if !VerifyPassword(user.Password, password) {
+		return User{}, false
 	}
-	return User{}, false
+	u.rehash(user, password) // best effort: the login succeeds when the new hash can't be saved
+	return user, true
 }
 
-func (u *UserStore) LoginExists(login string) bool {
-	for _, user := range u.Users {
-		if user.Login == login {
-			return true
+// RecordLogin sets the last login of the user and dispatches the login event. The method is local, oidc or saml.
+func (u *UserStore) RecordLogin(userID, method string) error {
+	var loggedInUser User
+	err := u.modify(func() error {
+		pos, ok := u.index.byID[userID]
+		if !ok {
+			return fmt.Errorf("user not found in database: userID %s", userID)
 		}
+		u.Users[pos].LastLogin = TimeOrEmpty(time.Now())
+		loggedInUser = u.Users[pos]
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	return false
+	u.emit(Event{Type: EVENT_USER_LOGIN, User: loggedInUser, Method: method})
+	return nil
+}
+
+func (u *UserStore) LoginExists(login string) bool {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	_, ok := u.index.byLogin[login]
+	return ok
 }
 func (u *UserStore) UpdateUser(user User) error {
-	for k, existingUser := range u.Users {
-		if existingUser.Login == user.Login {
-			password := existingUser.Password // we keep the password
-			user.Password = password
-			u.Users[k] = user
-			if u.autoSave {
-				return u.SaveUsers()
-			} else {
-				return nil
-			}
+	var previous User
+	err := u.modify(func() error {
+		pos, ok := u.index.byLogin[user.Login]
+		if !ok {
+			return fmt.Errorf("user not found in database: %s", user.Login)
 		}
-	}
-	return fmt.Errorf("user not found in database: %s", user.Login)
-}
-func (u *UserStore) UpdatePassword(userID string, password string) error {
-	for k, existingUser := range u.Users {
-		if existingUser.ID == userID {
-			hashedPassword, err := HashPassword(password)
-			if err != nil {
-				return fmt.Errorf("HashPassword error: %s", err)
-			}
-			u.Users[k].Password = hashedPassword
-			if u.autoSave {
-				return u.SaveUsers()
-			} else {
-				return nil
-			}
+		// we keep the password
+		user.Password = u.Users[pos].Password
+		user.PasswordHistory = u.Users[pos].PasswordHistory
+		user.PasswordChangedAt = u.Users[pos].PasswordChangedAt
+		user.CreatedAt = u.Users[pos].CreatedAt
+		if u.Users[pos].Suspended && !user.Suspended {
+			user.ReactivatedAt = TimeOrEmpty(time.Now())
+			user.SuspendedReason = ""
 		}
+		previous = u.Users[pos]
+		u.Users[pos] = user
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	return fmt.Errorf("user not found in database: userID %s", userID)
+	u.emit(updateEvents(previous, user)...)
+	return nil
 }
 
-func HashPassword(password string) (string, error) {
-	adminPasswordHashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+// UpdatePassword sets a new password, after checking it against the password policy
+func (u *UserStore) UpdatePassword(userID string, password string) error {
+	err := u.CheckPassword(password)
+	if err != nil {
+		return err
+	}
+	u.mu.RLock()
+	user, ok := u.lookup(u.index.byID, userID)
+	historySize := u.passwordPolicy.HistorySize
+	u.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("user not found in database: userID %s", userID)
+	}
+	if passwordReused(user, password, historySize) { // compare without holding the lock
+		return PasswordPolicyError{Reason: fmt.Sprintf("password can't be one of the last %d passwords", historySize)}
+	}
+	hashedPassword, err := u.hashPassword(password)
 	if err != nil {
-		return "", fmt.Errorf("unable to set password: %s", err)
+		return fmt.Errorf("HashPassword error: %s", err)
 	}
-	return string(adminPasswordHashed), nil
+	var updatedUser User
+	err = u.modify(func() error {
+		pos, ok := u.index.byID[userID]
+		if !ok {
+			return fmt.Errorf("user not found in database: userID %s", userID)
+		}
+		u.Users[pos].PasswordHistory = addToHistory(u.Users[pos].PasswordHistory, u.Users[pos].Password, historySize)
+		u.Users[pos].Password = hashedPassword
+		u.Users[pos].PasswordChangedAt = TimeOrEmpty(time.Now())
+		u.Users[pos].Invited = false // an admin can set the password of an invited user
+		updatedUser = u.Users[pos]
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	u.emit(Event{Type: EVENT_USER_PASSWORD_CHANGED, User: updatedUser})
+	return nil
 }
 
 func (u *UserStore) ListUsers() []User {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
 	users := make([]User, len(u.Users))
 	for k, user := range u.Users {
-		user.Password = ""
-		users[k] = user
+		users[k] = withoutPassword(user)
 	}
 	return users
 }
 
+// withoutPassword removes the password hashes from the user
+func withoutPassword(user User) User {
+	user.Password = ""
+	user.PasswordHistory = nil
+	return user
+}
+
 func (u *UserStore) UserCount() int {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
 	return len(u.Users)
 }
 
 func (u *UserStore) Empty() error {
-	u.Users = []User{}
-	if u.autoSave {
-		return u.SaveUsers()
-	}
-	return nil
+	return u.modify(func() error {
+		u.Users = []User{}
+		return nil
+	})
 }
@@ -0,0 +1,102 @@
+package users
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Groups returns the group store
+func (u *UserStore) Groups() *GroupStore {
+	return u.groups
+}
+
+func (u *UserStore) AddUserToGroup(userID, groupID string) error {
+	if _, err := u.groups.GetGroupByID(groupID); err != nil {
+		return err
+	}
+	return u.modify(func() error {
+		pos, ok := u.index.byID[userID]
+		if !ok {
+			return fmt.Errorf("user not found in database: userID %s", userID)
+		}
+		if !slices.Contains(u.Users[pos].Groups, groupID) {
+			u.Users[pos].Groups = append(slices.Clone(u.Users[pos].Groups), groupID)
+		}
+		return nil
+	})
+}
+
+func (u *UserStore) RemoveUserFromGroup(userID, groupID string) error {
+	return u.modify(func() error {
+		pos, ok := u.index.byID[userID]
+		if !ok {
+			return fmt.Errorf("user not found in database: userID %s", userID)
+		}
+		u.Users[pos].Groups = slices.DeleteFunc(slices.Clone(u.Users[pos].Groups), func(id string) bool { return id == groupID })
+		return nil
+	})
+}
+
+// SetUserGroups replaces the groups of the user
+func (u *UserStore) SetUserGroups(userID string, groupIDs []string) error {
+	for _, groupID := range groupIDs {
+		if _, err := u.groups.GetGroupByID(groupID); err != nil {
+			return fmt.Errorf("%s: %s", groupID, err)
+		}
+	}
+	return u.modify(func() error {
+		pos, ok := u.index.byID[userID]
+		if !ok {
+			return fmt.Errorf("user not found in database: userID %s", userID)
+		}
+		u.Users[pos].Groups = slices.Compact(slices.Sorted(slices.Values(groupIDs)))
+		return nil
+	})
+}
+
+// ListGroupMembers returns the users in the group
+func (u *UserStore) ListGroupMembers(groupID string) []User {
+	members := []User{}
+	for _, user := range u.ListUsers() {
+		if slices.Contains(user.Groups, groupID) {
+			members = append(members, user)
+		}
+	}
+	return members
+}
+
+// GetUserGroups returns the groups of the user. Groups that don't exist anymore are skipped.
+func (u *UserStore) GetUserGroups(user User) []Group {
+	groups := []Group{}
+	for _, groupID := range user.Groups {
+		group, err := u.groups.GetGroupByID(groupID)
+		if err == nil {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// IsMemberOf returns true when the user is a member of one of the groups (by name)
+func (u *UserStore) IsMemberOf(user User, groupNames ...string) bool {
+	for _, group := range u.GetUserGroups(user) {
+		if slices.Contains(groupNames, group.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteGroup deletes the group and removes it from all users
+func (u *UserStore) DeleteGroup(groupID string) error {
+	err := u.groups.deleteGroup(groupID)
+	if err != nil {
+		return err
+	}
+	return u.modify(func() error {
+		for k := range u.Users {
+			u.Users[k].Groups = slices.DeleteFunc(slices.Clone(u.Users[k].Groups), func(id string) bool { return id == groupID })
+		}
+		return nil
+	})
+}
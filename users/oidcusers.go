@@ -2,14 +2,40 @@ package users
 
 import "fmt"
 
+// GetUserByOIDCIDs returns the first user with one of the OIDC IDs
 func (u *UserStore) GetUserByOIDCIDs(oidcIDs []string) (User, error) {
-	for _, user := range u.Users {
-		for _, oidcID := range oidcIDs {
-			if user.OIDCID == oidcID {
-				return user, nil
-			}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	first := -1
+	for _, oidcID := range oidcIDs {
+		if pos, ok := u.index.byOIDCID[oidcID]; ok && (first == -1 || pos < first) {
+			first = pos
 		}
+	}
+	if first == -1 {
+		return User{}, fmt.Errorf("User not found")
+	}
+	return u.Users[first], nil
+}
+
+func (u *UserStore) GetUserBySAMLID(samlID string) (User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	user, ok := u.lookup(u.index.bySAMLID, samlID)
+	if !ok {
+		return User{}, fmt.Errorf("User not found")
+	}
+	user.Password = ""
+	return user, nil
+}
 
+func (u *UserStore) GetUserByExternalID(externalID string) (User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	user, ok := u.lookup(u.index.byExternalID, externalID)
+	if !ok {
+		return User{}, fmt.Errorf("User not found")
 	}
-	return User{}, fmt.Errorf("User not found")
+	user.Password = ""
+	return user, nil
 }
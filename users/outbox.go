@@ -0,0 +1,227 @@
+package users
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+const OUTBOX_FILENAME = "user-events-outbox.json"
+
+const (
+	OUTBOX_MAX_ATTEMPTS    = 8
+	OUTBOX_INITIAL_BACKOFF = 10 * time.Second // doubled after every failed attempt
+	OUTBOX_MAX_BACKOFF     = time.Hour
+	OUTBOX_CLAIM_TIMEOUT   = 5 * time.Minute // a claimed entry is retried when the worker didn't complete it in time
+)
+
+var ErrOutboxEntryNotFound = fmt.Errorf("outbox entry not found")
+
+// OutboxEntry is the delivery of an event to a queued subscriber
+type OutboxEntry struct {
+	ID          string    `json:"id"`
+	Subscriber  string    `json:"subscriber"`
+	Event       Event     `json:"event"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	FailedAt    time.Time `json:"failedAt,omitzero"` // dead letters: when the last attempt failed
+}
+
+type outboxFile struct {
+	Pending     []OutboxEntry `json:"pending"`
+	DeadLetters []OutboxEntry `json:"deadLetters"`
+}
+
+// Outbox keeps the deliveries to the queued subscribers in storage until they succeed.
+// Deliveries that fail OUTBOX_MAX_ATTEMPTS times are moved to the dead letters, where they can be replayed.
+type Outbox struct {
+	mu      sync.Mutex
+	storage storage.Iface
+	now     func() time.Time
+	notify  chan struct{}
+}
+
+func NewOutbox(storageClient storage.Iface) *Outbox {
+	return &Outbox{storage: storageClient, now: time.Now, notify: make(chan struct{}, 1)}
+}
+
+func outboxBackoff(attempts int) time.Duration {
+	backoff := OUTBOX_INITIAL_BACKOFF
+	for i := 1; i < attempts && backoff < OUTBOX_MAX_BACKOFF; i++ {
+		backoff *= 2
+	}
+	return min(backoff, OUTBOX_MAX_BACKOFF)
+}
+
+func (o *Outbox) read() (outboxFile, error) {
+	file := outboxFile{Pending: []OutboxEntry{}, DeadLetters: []OutboxEntry{}}
+	if !o.storage.FileExists(o.storage.ConfigPath(OUTBOX_FILENAME)) {
+		return file, nil
+	}
+	data, err := o.storage.ReadFile(o.storage.ConfigPath(OUTBOX_FILENAME))
+	if err != nil {
+		return file, fmt.Errorf("outbox read error: %s", err)
+	}
+	err = json.NewDecoder(bytes.NewBuffer(data)).Decode(&file)
+	if err != nil {
+		return file, fmt.Errorf("outbox decode error: %s", err)
+	}
+	return file, nil
+}
+
+// modify reads the outbox, runs fn and writes the outbox, while holding the storage lock
+func (o *Outbox) modify(fn func(file *outboxFile) error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return storage.WithLock(o.storage, o.storage.ConfigPath(OUTBOX_FILENAME), func() error {
+		file, err := o.read()
+		if err != nil {
+			return err
+		}
+		err = fn(&file)
+		if err != nil {
+			return err
+		}
+		out, err := json.Marshal(file)
+		if err != nil {
+			return fmt.Errorf("outbox marshal error: %s", err)
+		}
+		err = o.storage.WriteFile(o.storage.ConfigPath(OUTBOX_FILENAME), out)
+		if err != nil {
+			return fmt.Errorf("outbox write error: %s", err)
+		}
+		return nil
+	})
+}
+
+// enqueue adds a delivery of the event for every subscriber
+func (o *Outbox) enqueue(event Event, subscribers []string) error {
+	now := o.now()
+	err := o.modify(func(file *outboxFile) error {
+		for _, subscriber := range subscribers {
+			file.Pending = append(file.Pending, OutboxEntry{ID: uuid.NewString(), Subscriber: subscriber, Event: event, NextAttempt: now, CreatedAt: now})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	o.wake()
+	return nil
+}
+
+func (o *Outbox) wake() {
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Wait blocks until a delivery is enqueued or the timeout passed
+func (o *Outbox) Wait(timeout time.Duration) {
+	select {
+	case <-o.notify:
+	case <-time.After(timeout):
+	}
+}
+
+// claim returns the deliveries that are due for the subscribers and counts the attempt
+func (o *Outbox) claim(subscribers []string) ([]OutboxEntry, error) {
+	claimed := []OutboxEntry{}
+	now := o.now()
+	err := o.modify(func(file *outboxFile) error {
+		for k := range file.Pending {
+			if file.Pending[k].NextAttempt.After(now) || !slices.Contains(subscribers, file.Pending[k].Subscriber) {
+				continue
+			}
+			file.Pending[k].Attempts++
+			file.Pending[k].NextAttempt = now.Add(OUTBOX_CLAIM_TIMEOUT)
+			claimed = append(claimed, file.Pending[k])
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+// complete removes a delivered entry, or schedules the next attempt. The entry is moved to the dead letters after the last attempt.
+func (o *Outbox) complete(entry OutboxEntry, deliveryErr error) error {
+	now := o.now()
+	return o.modify(func(file *outboxFile) error {
+		index := slices.IndexFunc(file.Pending, func(pending OutboxEntry) bool { return pending.ID == entry.ID })
+		if index == -1 {
+			return nil
+		}
+		if deliveryErr == nil {
+			file.Pending = slices.Delete(file.Pending, index, index+1)
+			return nil
+		}
+		file.Pending[index].LastError = deliveryErr.Error()
+		if file.Pending[index].Attempts >= OUTBOX_MAX_ATTEMPTS {
+			file.Pending[index].FailedAt = now
+			file.DeadLetters = append(file.DeadLetters, file.Pending[index])
+			file.Pending = slices.Delete(file.Pending, index, index+1)
+			return nil
+		}
+		file.Pending[index].NextAttempt = now.Add(outboxBackoff(file.Pending[index].Attempts))
+		return nil
+	})
+}
+
+// Pending returns the deliveries that didn't succeed yet
+func (o *Outbox) Pending() ([]OutboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	file, err := o.read()
+	return file.Pending, err
+}
+
+// DeadLetters returns the deliveries that failed after the last attempt
+func (o *Outbox) DeadLetters() ([]OutboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	file, err := o.read()
+	return file.DeadLetters, err
+}
+
+// Replay moves a dead letter back to the pending deliveries, with the attempts reset
+func (o *Outbox) Replay(id string) (OutboxEntry, error) {
+	var entry OutboxEntry
+	err := o.modify(func(file *outboxFile) error {
+		index := slices.IndexFunc(file.DeadLetters, func(deadLetter OutboxEntry) bool { return deadLetter.ID == id })
+		if index == -1 {
+			return ErrOutboxEntryNotFound
+		}
+		entry = file.DeadLetters[index]
+		entry.Attempts = 0
+		entry.NextAttempt = o.now()
+		entry.FailedAt = time.Time{}
+		file.DeadLetters = slices.Delete(file.DeadLetters, index, index+1)
+		file.Pending = append(file.Pending, entry)
+		return nil
+	})
+	if err != nil {
+		return entry, err
+	}
+	o.wake()
+	return entry, nil
+}
+
+// Discard removes a dead letter
+func (o *Outbox) Discard(id string) error {
+	return o.modify(func(file *outboxFile) error {
+		index := slices.IndexFunc(file.DeadLetters, func(deadLetter OutboxEntry) bool { return deadLetter.ID == id })
+		if index == -1 {
+			return ErrOutboxEntryNotFound
+		}
+		file.DeadLetters = slices.Delete(file.DeadLetters, index, index+1)
+		return nil
+	})
+}
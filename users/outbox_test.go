@@ -0,0 +1,115 @@
+package users
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestOutboxRetry(t *testing.T) {
+	now := time.Now()
+	outbox := NewOutbox(&memorystorage.MockMemoryStorage{})
+	outbox.now = func() time.Time { return now }
+	d := NewDispatcher(outbox)
+	calls := 0
+	fail := true
+	d.SubscribeQueued("app", func(event Event) error {
+		calls++
+		if fail {
+			return fmt.Errorf("app unavailable")
+		}
+		return nil
+	}, EVENT_USER_DISABLED)
+
+	if err := d.Dispatch(Event{Type: EVENT_USER_DISABLED, User: User{ID: "1", Login: "john"}}); err != nil {
+		t.Fatalf("dispatch error: %s", err)
+	}
+	if err := d.Dispatch(Event{Type: EVENT_USER_CREATED, User: User{ID: "1", Login: "john"}}); err != nil {
+		t.Fatalf("dispatch error: %s", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected queued subscriber not to be called by dispatch")
+	}
+	for attempt := 1; attempt <= OUTBOX_MAX_ATTEMPTS; attempt++ {
+		delivered, err := d.ProcessOutbox()
+		if err != nil || delivered != 0 {
+			t.Fatalf("process outbox: %d delivered (%v)", delivered, err)
+		}
+		if calls != attempt {
+			t.Fatalf("expected %d calls, got: %d", attempt, calls)
+		}
+		if _, err := d.ProcessOutbox(); err != nil || calls != attempt {
+			t.Fatalf("expected no retry before the backoff, got %d calls (%v)", calls, err)
+		}
+		now = now.Add(outboxBackoff(attempt))
+	}
+	pending, err := outbox.Pending()
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("expected no pending entries: %v (%v)", pending, err)
+	}
+	deadLetters, err := outbox.DeadLetters()
+	if err != nil || len(deadLetters) != 1 {
+		t.Fatalf("expected a dead letter: %v (%v)", deadLetters, err)
+	}
+	if deadLetters[0].Subscriber != "app" || deadLetters[0].Event.User.Login != "john" || deadLetters[0].LastError != "app unavailable" || deadLetters[0].Attempts != OUTBOX_MAX_ATTEMPTS {
+		t.Fatalf("unexpected dead letter: %+v", deadLetters[0])
+	}
+
+	// replay after the app is fixed
+	fail = false
+	if _, err := outbox.Replay(deadLetters[0].ID); err != nil {
+		t.Fatalf("replay error: %s", err)
+	}
+	delivered, err := d.ProcessOutbox()
+	if err != nil || delivered != 1 {
+		t.Fatalf("expected replayed entry to be delivered: %d (%v)", delivered, err)
+	}
+	if pending, _ := outbox.Pending(); len(pending) != 0 {
+		t.Fatalf("expected no pending entries after delivery: %v", pending)
+	}
+	if _, err := outbox.Replay(deadLetters[0].ID); err != ErrOutboxEntryNotFound {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+}
+
+func TestOutboxSubscribers(t *testing.T) {
+	if outboxBackoff(1) != OUTBOX_INITIAL_BACKOFF || outboxBackoff(2) != 2*OUTBOX_INITIAL_BACKOFF || outboxBackoff(100) != OUTBOX_MAX_BACKOFF {
+		t.Fatalf("unexpected backoff")
+	}
+	storage := &memorystorage.MockMemoryStorage{}
+	d1 := NewDispatcher(NewOutbox(storage))
+	d2 := NewDispatcher(NewOutbox(storage))
+	d1.SubscribeQueued("app1", func(event Event) error { return fmt.Errorf("unavailable") })
+	if err := d1.Dispatch(Event{Type: EVENT_USER_DELETED, User: User{Login: "john", Password: "password-hash", Factors: []Factor{{Name: "phone", Type: "totp", Secret: "JBSWY3DPEHPK3PXP"}}}}); err != nil {
+		t.Fatalf("dispatch error: %s", err)
+	}
+	// secrets aren't persisted in the outbox
+	data, err := storage.ReadFile(storage.ConfigPath(OUTBOX_FILENAME))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	if strings.Contains(string(data), "JBSWY3DPEHPK3PXP") || strings.Contains(string(data), "password-hash") {
+		t.Fatalf("secrets in the outbox: %s", data)
+	}
+	// a dispatcher without the subscriber doesn't claim the entry
+	if _, err := d2.ProcessOutbox(); err != nil {
+		t.Fatalf("process outbox error: %s", err)
+	}
+	pending, err := d1.Outbox().Pending()
+	if err != nil || len(pending) != 1 || pending[0].Attempts != 0 {
+		t.Fatalf("expected the entry to be pending without attempts: %v (%v)", pending, err)
+	}
+	if _, err := d1.ProcessOutbox(); err != nil {
+		t.Fatalf("process outbox error: %s", err)
+	}
+	if pending, _ := d1.Outbox().Pending(); len(pending) != 1 || pending[0].Attempts != 1 {
+		t.Fatalf("expected one attempt: %v", pending)
+	}
+	// only dead letters can be discarded
+	if err := d1.Outbox().Discard(pending[0].ID); err != ErrOutboxEntryNotFound {
+		t.Fatalf("expected pending entry not to be discarded, got: %v", err)
+	}
+}
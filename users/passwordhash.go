@@ -0,0 +1,231 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HASH_ALGORITHM_ARGON2ID = "argon2id"
+	HASH_ALGORITHM_BCRYPT   = "bcrypt"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+
+	// upper bounds of the hash parameters, to limit the cost of a login with an imported or configured hash
+	argon2MaxMemory     = 64 * 1024 // KiB
+	argon2MaxIterations = 64
+	bcryptMaxCost       = 14
+)
+
+// PasswordHashParams are the parameters used to hash new passwords. Zero values are replaced by the defaults.
+type PasswordHashParams struct {
+	Algorithm   string `json:"algorithm,omitempty"`   // argon2id (default) or bcrypt
+	Memory      uint32 `json:"memory,omitempty"`      // argon2id: memory in KiB
+	Iterations  uint32 `json:"iterations,omitempty"`  // argon2id
+	Parallelism uint8  `json:"parallelism,omitempty"` // argon2id
+	BcryptCost  int    `json:"bcryptCost,omitempty"`
+}
+
+// DefaultPasswordHashParams returns argon2id with the OWASP recommended parameters (19 MiB, 2 iterations, 1 thread)
+func DefaultPasswordHashParams() PasswordHashParams {
+	return PasswordHashParams{
+		Algorithm:   HASH_ALGORITHM_ARGON2ID,
+		Memory:      19 * 1024,
+		Iterations:  2,
+		Parallelism: 1,
+		BcryptCost:  12,
+	}
+}
+
+func (p PasswordHashParams) withDefaults() PasswordHashParams {
+	defaults := DefaultPasswordHashParams()
+	if p.Algorithm == "" {
+		p.Algorithm = defaults.Algorithm
+	}
+	if p.Memory == 0 {
+		p.Memory = defaults.Memory
+	}
+	if p.Iterations == 0 {
+		p.Iterations = defaults.Iterations
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = defaults.Parallelism
+	}
+	if p.BcryptCost == 0 {
+		p.BcryptCost = defaults.BcryptCost
+	}
+	return p
+}
+
+func (p PasswordHashParams) Validate() error {
+	p = p.withDefaults()
+	switch p.Algorithm {
+	case HASH_ALGORITHM_ARGON2ID:
+		return p.validateArgon2()
+	case HASH_ALGORITHM_BCRYPT:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcryptMaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcryptMaxCost)
+		}
+	default:
+		return fmt.Errorf("unknown hash algorithm: %s", p.Algorithm)
+	}
+	return nil
+}
+
+func (p PasswordHashParams) validateArgon2() error {
+	if p.Iterations < 1 || p.Iterations > argon2MaxIterations {
+		return fmt.Errorf("argon2 iterations must be between 1 and %d", argon2MaxIterations)
+	}
+	if p.Parallelism < 1 {
+		return fmt.Errorf("argon2 parallelism must be at least 1")
+	}
+	if p.Memory < 8*uint32(p.Parallelism) {
+		return fmt.Errorf("argon2 memory must be at least 8 KiB per thread")
+	}
+	if p.Memory > argon2MaxMemory {
+		return fmt.Errorf("argon2 memory can't be more than %d KiB", argon2MaxMemory)
+	}
+	return nil
+}
+
+// HashPassword hashes the password with the default parameters
+func HashPassword(password string) (string, error) {
+	return HashPasswordWithParams(password, DefaultPasswordHashParams())
+}
+
+// HashPasswordWithParams returns a self-describing hash: the PHC string format for argon2id
+// ($argon2id$v=19$m=19456,t=2,p=1$salt$hash) or the modular crypt format for bcrypt ($2a$cost$...)
+func HashPasswordWithParams(password string, params PasswordHashParams) (string, error) {
+	params = params.withDefaults()
+	switch params.Algorithm {
+	case HASH_ALGORITHM_ARGON2ID:
+		salt := make([]byte, argon2SaltLength)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return "", fmt.Errorf("unable to generate salt: %s", err)
+		}
+		key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, argon2KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	case HASH_ALGORITHM_BCRYPT:
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), params.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("unable to set password: %s", err)
+		}
+		return string(hashed), nil
+	}
+	return "", fmt.Errorf("unknown hash algorithm: %s", params.Algorithm)
+}
+
+type argon2Hash struct {
+	params PasswordHashParams
+	salt   []byte
+	key    []byte
+}
+
+func parseArgon2idHash(hash string) (argon2Hash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HASH_ALGORITHM_ARGON2ID {
+		return argon2Hash{}, fmt.Errorf("not an argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Hash{}, fmt.Errorf("unsupported argon2 version")
+	}
+	res := argon2Hash{params: PasswordHashParams{Algorithm: HASH_ALGORITHM_ARGON2ID}}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &res.params.Memory, &res.params.Iterations, &res.params.Parallelism); err != nil {
+		return argon2Hash{}, fmt.Errorf("invalid argon2id parameters: %s", err)
+	}
+	if err := res.params.validateArgon2(); err != nil {
+		return argon2Hash{}, fmt.Errorf("invalid argon2id parameters: %s", err)
+	}
+	var err error
+	res.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2Hash{}, fmt.Errorf("invalid argon2id salt: %s", err)
+	}
+	res.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(res.key) == 0 {
+		return argon2Hash{}, fmt.Errorf("invalid argon2id hash")
+	}
+	return res, nil
+}
+
+// VerifyPassword checks the password against a hash created by HashPasswordWithParams or an imported hash (see HashFormat)
+func VerifyPassword(hash, password string) bool {
+	if strings.HasPrefix(hash, "$"+HASH_ALGORITHM_ARGON2ID+"$") {
+		parsed, err := parseArgon2idHash(hash)
+		if err != nil {
+			return false
+		}
+		key := argon2.IDKey([]byte(password), parsed.salt, parsed.params.Iterations, parsed.params.Memory, parsed.params.Parallelism, uint32(len(parsed.key)))
+		return subtle.ConstantTimeCompare(key, parsed.key) == 1
+	}
+	if strings.HasPrefix(hash, "$2") {
+		if cost, err := bcrypt.Cost([]byte(hash)); err != nil || cost > bcryptMaxCost {
+			return false
+		}
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+	return verifyForeignHash(hash, password)
+}
+
+// needsRehash returns true when the hash was created with other parameters than params, or is an imported hash in a foreign format
+func needsRehash(hash string, params PasswordHashParams) bool {
+	params = params.withDefaults()
+	if strings.HasPrefix(hash, "$"+HASH_ALGORITHM_ARGON2ID+"$") {
+		parsed, err := parseArgon2idHash(hash)
+		if err != nil {
+			return true
+		}
+		return params.Algorithm != HASH_ALGORITHM_ARGON2ID || parsed.params.Memory != params.Memory ||
+			parsed.params.Iterations != params.Iterations || parsed.params.Parallelism != params.Parallelism
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+	return params.Algorithm != HASH_ALGORITHM_BCRYPT || cost != params.BcryptCost
+}
+
+func (u *UserStore) SetPasswordHashParams(params PasswordHashParams) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.hashParams = params.withDefaults()
+}
+
+func (u *UserStore) hashPassword(password string) (string, error) {
+	u.mu.RLock()
+	params := u.hashParams
+	u.mu.RUnlock()
+	return HashPasswordWithParams(password, params)
+}
+
+// rehash replaces the password hash of the user when it's outdated. Called after a successful login, as that's the only time we know the password.
+func (u *UserStore) rehash(user User, password string) error {
+	u.mu.RLock()
+	params := u.hashParams
+	u.mu.RUnlock()
+	if !needsRehash(user.Password, params) {
+		return nil
+	}
+	hashedPassword, err := HashPasswordWithParams(password, params)
+	if err != nil {
+		return err
+	}
+	return u.modify(func() error {
+		pos, ok := u.index.byID[user.ID]
+		if !ok || u.Users[pos].Password != user.Password { // deleted or password changed in the meantime
+			return nil
+		}
+		u.Users[pos].Password = hashedPassword
+		return nil
+	})
+}
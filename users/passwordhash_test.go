@@ -0,0 +1,90 @@
+package users
+
+import (
+	"strings"
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatalf("hash error: %s", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Fatalf("unexpected hash format: %s", hash)
+	}
+	if !VerifyPassword(hash, "secret") || VerifyPassword(hash, "wrong") {
+		t.Fatalf("verify mismatch")
+	}
+	bcryptHash, err := HashPasswordWithParams("secret", PasswordHashParams{Algorithm: HASH_ALGORITHM_BCRYPT, BcryptCost: bcrypt.MinCost})
+	if err != nil {
+		t.Fatalf("hash error: %s", err)
+	}
+	if !VerifyPassword(bcryptHash, "secret") || VerifyPassword(bcryptHash, "wrong") {
+		t.Fatalf("verify mismatch (bcrypt)")
+	}
+	if VerifyPassword("$argon2id$v=19$m=abc$", "secret") {
+		t.Fatalf("invalid hash shouldn't verify")
+	}
+	if err := (PasswordHashParams{Algorithm: "md5"}).Validate(); err == nil {
+		t.Fatalf("expected unknown algorithm error")
+	}
+}
+
+func TestRehashOnLogin(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	store, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	legacyParams := PasswordHashParams{Algorithm: HASH_ALGORITHM_BCRYPT, BcryptCost: bcrypt.MinCost}
+	store.SetPasswordHashParams(legacyParams)
+	_, err = store.AddUser(User{Login: "john", Password: "secret"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	storedHash := func() string {
+		store.mu.RLock()
+		defer store.mu.RUnlock()
+		user, _ := store.lookup(store.index.byLogin, "john")
+		return user.Password
+	}
+	if !strings.HasPrefix(storedHash(), "$2a$04$") {
+		t.Fatalf("expected bcrypt hash: %s", storedHash())
+	}
+
+	store.SetPasswordHashParams(PasswordHashParams{}) // defaults
+	if _, ok := store.AuthUser("john", "wrong"); ok {
+		t.Fatalf("expected auth to fail")
+	}
+	if !strings.HasPrefix(storedHash(), "$2a$04$") {
+		t.Fatalf("hash shouldn't change on failed login")
+	}
+	if _, ok := store.AuthUser("john", "secret"); !ok {
+		t.Fatalf("expected auth to succeed")
+	}
+	if !strings.HasPrefix(storedHash(), "$argon2id$") {
+		t.Fatalf("expected password to be rehashed: %s", storedHash())
+	}
+	rehashed := storedHash()
+
+	store.SetPasswordHashParams(PasswordHashParams{Iterations: 3})
+	if _, ok := store.AuthUser("john", "secret"); !ok {
+		t.Fatalf("expected auth to succeed")
+	}
+	if storedHash() == rehashed || !strings.Contains(storedHash(), "t=3") {
+		t.Fatalf("expected password to be rehashed with new parameters: %s", storedHash())
+	}
+
+	// the new hash is saved
+	store2, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	if _, ok := store2.AuthUser("john", "secret"); !ok {
+		t.Fatalf("expected auth to succeed with the saved hash")
+	}
+}
@@ -0,0 +1,214 @@
+package users
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// BREACHED_PASSWORDS_FILENAME is a local list of breached passwords, one per line.
+// Lines can be the password or the (uppercase or lowercase) hex SHA-1 of the password, optionally followed by ":count" (HIBP format).
+const BREACHED_PASSWORDS_FILENAME = "breached-passwords.txt"
+
+// PasswordPolicy is enforced every time a password is set. The empty policy only requires a non-empty password.
+type PasswordPolicy struct {
+	MinLength        int  `json:"minLength,omitempty"`
+	RequireUppercase bool `json:"requireUppercase,omitempty"`
+	RequireLowercase bool `json:"requireLowercase,omitempty"`
+	RequireDigit     bool `json:"requireDigit,omitempty"`
+	RequireSymbol    bool `json:"requireSymbol,omitempty"`
+	RejectBreached   bool `json:"rejectBreached,omitempty"` // reject the passwords in BREACHED_PASSWORDS_FILENAME
+	HistorySize      int  `json:"historySize,omitempty"`    // the last HistorySize passwords can't be reused
+	MaxAgeDays       int  `json:"maxAgeDays,omitempty"`     // 0 means passwords don't expire
+}
+
+func (p PasswordPolicy) Validate() error {
+	if p.MinLength < 0 || p.HistorySize < 0 || p.MaxAgeDays < 0 {
+		return fmt.Errorf("password policy values can't be negative")
+	}
+	if p.MinLength > 72 {
+		return fmt.Errorf("minimum length can't be more than 72 characters")
+	}
+	return nil
+}
+
+type PasswordPolicyError struct {
+	Reason string
+}
+
+func (e PasswordPolicyError) Error() string {
+	return "password doesn't meet the password policy: " + e.Reason
+}
+
+// checkPassword validates the password against the length and character class rules
+func (p PasswordPolicy) checkPassword(password string) error {
+	if password == "" {
+		return PasswordPolicyError{Reason: "password is empty"}
+	}
+	if len([]rune(password)) < p.MinLength {
+		return PasswordPolicyError{Reason: fmt.Sprintf("password must be at least %d characters", p.MinLength)}
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	for _, class := range []struct {
+		required bool
+		present  bool
+		name     string
+	}{
+		{p.RequireUppercase, upper, "an uppercase letter"},
+		{p.RequireLowercase, lower, "a lowercase letter"},
+		{p.RequireDigit, digit, "a digit"},
+		{p.RequireSymbol, symbol, "a symbol"},
+	} {
+		if class.required && !class.present {
+			return PasswordPolicyError{Reason: "password must contain " + class.name}
+		}
+	}
+	return nil
+}
+
+// passwordExpired returns true when the password is older than the maximum age.
+// Passwords without a change date (set before the policy existed) don't expire, see StartPasswordAging.
+func (p PasswordPolicy) passwordExpired(user User) bool {
+	if p.MaxAgeDays == 0 || user.Password == "" || user.PasswordChangedAt.IsZero() {
+		return false
+	}
+	return time.Time(user.PasswordChangedAt).AddDate(0, 0, p.MaxAgeDays).Before(time.Now())
+}
+
+func (u *UserStore) SetPasswordPolicy(policy PasswordPolicy) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.passwordPolicy = policy
+}
+
+func (u *UserStore) GetPasswordPolicy() PasswordPolicy {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.passwordPolicy
+}
+
+// CheckPassword validates a new password against the password policy (without the history)
+func (u *UserStore) CheckPassword(password string) error {
+	policy := u.GetPasswordPolicy()
+	err := policy.checkPassword(password)
+	if err != nil {
+		return err
+	}
+	if policy.RejectBreached {
+		breached, err := u.isBreached(password)
+		if err != nil {
+			return fmt.Errorf("breached password check error: %s", err)
+		}
+		if breached {
+			return PasswordPolicyError{Reason: "password was found in a list of breached passwords"}
+		}
+	}
+	return nil
+}
+
+// isBreached scans the breached passwords file. When the file doesn't exist, no password is breached.
+func (u *UserStore) isBreached(password string) (bool, error) {
+	filename := u.storage.ConfigPath(BREACHED_PASSWORDS_FILENAME)
+	if !u.storage.FileExists(filename) {
+		return false, nil
+	}
+	file, err := u.storage.OpenFile(filename)
+	if err != nil {
+		return false, fmt.Errorf("open %s error: %s", BREACHED_PASSWORDS_FILENAME, err)
+	}
+	defer file.Close()
+	hash := sha1.Sum([]byte(password))
+	hexHash := hex.EncodeToString(hash[:])
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == password {
+			return true, nil
+		}
+		if len(line) >= 40 && strings.EqualFold(line[:40], hexHash) && (len(line) == 40 || line[40] == ':') {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("read %s error: %s", BREACHED_PASSWORDS_FILENAME, err)
+	}
+	return false, nil
+}
+
+// StartPasswordAging sets the change date of the local passwords without one (set before the policy existed) to now,
+// so the maximum password age applies from when it's enabled, instead of expiring those passwords immediately.
+func (u *UserStore) StartPasswordAging() error {
+	u.mu.RLock()
+	missing := slices.ContainsFunc(u.Users, func(user User) bool { return user.Password != "" && user.PasswordChangedAt.IsZero() })
+	u.mu.RUnlock()
+	if !missing {
+		return nil
+	}
+	return u.modify(func() error {
+		now := TimeOrEmpty(time.Now())
+		for k := range u.Users {
+			if u.Users[k].Password != "" && u.Users[k].PasswordChangedAt.IsZero() {
+				u.Users[k].PasswordChangedAt = now
+			}
+		}
+		return nil
+	})
+}
+
+// PasswordExpired returns true when the local password of the user is older than the maximum password age of the policy
+func (u *UserStore) PasswordExpired(userID string) bool {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	user, ok := u.lookup(u.index.byID, userID)
+	if !ok {
+		return false
+	}
+	return u.passwordPolicy.passwordExpired(user)
+}
+
+// passwordReused returns true when the password matches the current password or one of the last passwords in the history
+func passwordReused(user User, password string, historySize int) bool {
+	if historySize == 0 {
+		return false
+	}
+	hashes := append([]string{user.Password}, user.PasswordHistory...)
+	if len(hashes) > historySize {
+		hashes = hashes[:historySize]
+	}
+	for _, hash := range hashes {
+		if hash != "" && VerifyPassword(hash, password) {
+			return true
+		}
+	}
+	return false
+}
+
+// addToHistory returns the history with the previous password hash added in front, limited to historySize - 1 entries
+// (the current password is the other one that can't be reused)
+func addToHistory(history []string, previous string, historySize int) []string {
+	if historySize <= 1 || previous == "" {
+		return nil
+	}
+	newHistory := append([]string{previous}, history...)
+	if len(newHistory) > historySize-1 {
+		newHistory = newHistory[:historySize-1]
+	}
+	return newHistory
+}
@@ -0,0 +1,129 @@
+package users
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestPasswordPolicyCheckPassword(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, RequireUppercase: true, RequireLowercase: true, RequireDigit: true, RequireSymbol: true}
+	for password, valid := range map[string]bool{
+		"":            false,
+		"Ab1!":        false,
+		"abcdefg1!":   false,
+		"ABCDEFG1!":   false,
+		"Abcdefgh!":   false,
+		"Abcdefgh1":   false,
+		"Abcdefgh1!":  true,
+		"Ünïcödé1!xx": true,
+	} {
+		err := policy.checkPassword(password)
+		if valid && err != nil {
+			t.Fatalf("password %q: unexpected error: %s", password, err)
+		}
+		if !valid && err == nil {
+			t.Fatalf("password %q: expected error", password)
+		}
+	}
+}
+
+func TestPasswordPolicyBreached(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	hash := sha1.Sum([]byte("hashed-password"))
+	err := storage.WriteFile(storage.ConfigPath(BREACHED_PASSWORDS_FILENAME), []byte("password123\n"+strings.ToUpper(hex.EncodeToString(hash[:]))+":42\n"))
+	if err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	store, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	store.SetPasswordPolicy(PasswordPolicy{RejectBreached: true})
+	for password, breached := range map[string]bool{"password123": true, "hashed-password": true, "not-breached": false} {
+		err := store.CheckPassword(password)
+		if breached && !errors.As(err, &PasswordPolicyError{}) {
+			t.Fatalf("password %s: expected password policy error, got: %v", password, err)
+		}
+		if !breached && err != nil {
+			t.Fatalf("password %s: unexpected error: %s", password, err)
+		}
+	}
+	if _, err := store.AddUser(User{Login: "john", Password: "password123"}); err == nil {
+		t.Fatalf("expected AddUser to enforce the password policy")
+	}
+}
+
+func TestPasswordPolicyHistory(t *testing.T) {
+	store, err := NewUserStore(&memorystorage.MockMemoryStorage{}, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	store.SetPasswordPolicy(PasswordPolicy{HistorySize: 3})
+	user, err := store.AddUser(User{Login: "john", Password: "password-1"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	for _, password := range []string{"password-2", "password-3", "password-4"} {
+		if err := store.UpdatePassword(user.ID, password); err != nil {
+			t.Fatalf("update password error: %s", err)
+		}
+	}
+	for _, password := range []string{"password-2", "password-3", "password-4"} { // last 3 passwords
+		if err := store.UpdatePassword(user.ID, password); err == nil {
+			t.Fatalf("expected password %s to be rejected", password)
+		}
+	}
+	if err := store.UpdatePassword(user.ID, "password-1"); err != nil {
+		t.Fatalf("password-1 is not in the last 3 passwords: %s", err)
+	}
+	if _, ok := store.AuthUser("john", "password-1"); !ok {
+		t.Fatalf("expected to authenticate with new password")
+	}
+	if listed := store.ListUsers(); len(listed[0].PasswordHistory) != 0 {
+		t.Fatalf("password history shouldn't be returned")
+	}
+}
+
+func TestPasswordExpired(t *testing.T) {
+	store, err := NewUserStore(&memorystorage.MockMemoryStorage{}, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	user, err := store.AddUser(User{Login: "john", Password: "password"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	oidcUser, err := store.AddUser(User{Login: "jane", OIDCID: "123"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	if store.PasswordExpired(user.ID) {
+		t.Fatalf("password can't expire without max age")
+	}
+	store.SetPasswordPolicy(PasswordPolicy{MaxAgeDays: 30})
+	if store.PasswordExpired(user.ID) || store.PasswordExpired(oidcUser.ID) {
+		t.Fatalf("password shouldn't be expired")
+	}
+	err = store.modify(func() error {
+		store.Users[store.index.byID[user.ID]].PasswordChangedAt = TimeOrEmpty(time.Now().AddDate(0, 0, -31))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("modify error: %s", err)
+	}
+	if !store.PasswordExpired(user.ID) {
+		t.Fatalf("password should be expired")
+	}
+	if err := store.UpdatePassword(user.ID, "new-password"); err != nil {
+		t.Fatalf("update password error: %s", err)
+	}
+	if store.PasswordExpired(user.ID) {
+		t.Fatalf("password shouldn't be expired after update")
+	}
+}
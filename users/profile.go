@@ -0,0 +1,44 @@
+package users
+
+import "maps"
+
+// Profile contains the profile attributes of a user, as received from the identity source (SCIM, OIDC or SAML)
+type Profile struct {
+	DisplayName string            `json:"displayName,omitempty"`
+	GivenName   string            `json:"givenName,omitempty"`
+	FamilyName  string            `json:"familyName,omitempty"`
+	Email       string            `json:"email,omitempty"`
+	Locale      string            `json:"locale,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"` // custom attributes
+}
+
+// UpdateProfile sets the fields of the profile that are not empty. Attributes are merged.
+// Returns true when the profile of the user changed.
+func (u *User) UpdateProfile(profile Profile) bool {
+	changed := false
+	for _, field := range []struct {
+		current *string
+		new     string
+	}{
+		{&u.DisplayName, profile.DisplayName},
+		{&u.GivenName, profile.GivenName},
+		{&u.FamilyName, profile.FamilyName},
+		{&u.Email, profile.Email},
+		{&u.Locale, profile.Locale},
+	} {
+		if field.new != "" && *field.current != field.new {
+			*field.current = field.new
+			changed = true
+		}
+	}
+	attributes := maps.Clone(u.Attributes) // don't modify the map of the stored user
+	if attributes == nil {
+		attributes = make(map[string]string)
+	}
+	maps.Copy(attributes, profile.Attributes)
+	if !maps.Equal(attributes, u.Attributes) {
+		u.Attributes = attributes
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,52 @@
+package users
+
+import (
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	attributes := map[string]string{"department": "engineering"}
+	user := User{Login: "john", Profile: Profile{DisplayName: "John", Email: "john@example.inv", Attributes: attributes}}
+	if user.UpdateProfile(Profile{DisplayName: "John", Attributes: map[string]string{"department": "engineering"}}) {
+		t.Fatalf("expected profile to be unchanged")
+	}
+	if !user.UpdateProfile(Profile{DisplayName: "John Doe", GivenName: "John", Attributes: map[string]string{"costCenter": "42"}}) {
+		t.Fatalf("expected profile to be changed")
+	}
+	if user.DisplayName != "John Doe" || user.GivenName != "John" {
+		t.Fatalf("profile not updated: %+v", user.Profile)
+	}
+	if user.Email != "john@example.inv" {
+		t.Fatalf("empty fields shouldn't overwrite the profile: %+v", user.Profile)
+	}
+	if user.Attributes["department"] != "engineering" || user.Attributes["costCenter"] != "42" {
+		t.Fatalf("attributes not merged: %+v", user.Attributes)
+	}
+	if len(attributes) != 1 {
+		t.Fatalf("original attributes map was modified: %+v", attributes)
+	}
+}
+
+func TestProfileIsStored(t *testing.T) {
+	store, err := NewUserStore(&memorystorage.MockMemoryStorage{}, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	_, err = store.AddUser(User{Login: "john", Profile: Profile{GivenName: "John", Locale: "en-US"}})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	store2, err := NewUserStore(store.storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	user, err := store2.GetUserByLogin("john")
+	if err != nil {
+		t.Fatalf("get user error: %s", err)
+	}
+	if user.GivenName != "John" || user.Locale != "en-US" {
+		t.Fatalf("profile not stored: %+v", user.Profile)
+	}
+}
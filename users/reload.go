@@ -0,0 +1,52 @@
+package users
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/in4it/go-devops-platform/logging"
+)
+
+// Reload reads users.json again when it was changed outside of this user store (e.g. by another instance).
+// Returns true when the users were reloaded.
+func (u *UserStore) Reload() (bool, error) {
+	u.writeMu.Lock()
+	defer u.writeMu.Unlock()
+	return u.reload()
+}
+
+// reload must be called with writeMu held
+func (u *UserStore) reload() (bool, error) {
+	if !u.storage.FileExists(u.storage.ConfigPath(USERSTORE_FILENAME)) {
+		return false, nil
+	}
+	data, err := u.storage.ReadFile(u.storage.ConfigPath(USERSTORE_FILENAME))
+	if err != nil {
+		return false, fmt.Errorf("config read error: %s", err)
+	}
+	hash := sha256.Sum256(data)
+	u.mu.RLock()
+	unchanged := hash == u.hash
+	u.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	var users []User
+	err = json.NewDecoder(bytes.NewBuffer(data)).Decode(&users)
+	if err != nil {
+		// users.json is corrupt: keep the users we have (e.g. loaded from a snapshot), so the next change overwrites the file
+		logging.ErrorLog(fmt.Errorf("could not decode %s, keeping the loaded users: %s", USERSTORE_FILENAME, err))
+		u.mu.Lock()
+		u.hash = hash
+		u.mu.Unlock()
+		return false, nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.Users = users
+	u.hash = hash
+	u.reindex()
+	return true, nil
+}
@@ -0,0 +1,90 @@
+package users
+
+import (
+	"fmt"
+	"slices"
+)
+
+type Permission string
+
+const (
+	PermissionAll           Permission = "*"
+	PermissionUsersRead     Permission = "users:read"
+	PermissionUsersWrite    Permission = "users:write"
+	PermissionGroupsRead    Permission = "groups:read"
+	PermissionGroupsWrite   Permission = "groups:write"
+	PermissionOIDCManage    Permission = "oidc:manage"
+	PermissionSAMLManage    Permission = "saml:manage"
+	PermissionSCIMManage    Permission = "scim:manage"
+	PermissionLicenseRead   Permission = "license:read"
+	PermissionLicenseManage Permission = "license:manage"
+	PermissionSetupManage   Permission = "setup:manage"
+	PermissionRolesManage   Permission = "roles:manage"
+)
+
+const ROLE_ADMIN = "admin"
+const ROLE_USER = "user"
+
+// Permissions returns all permissions (without the wildcard)
+func Permissions() []Permission {
+	return []Permission{
+		PermissionUsersRead,
+		PermissionUsersWrite,
+		PermissionGroupsRead,
+		PermissionGroupsWrite,
+		PermissionOIDCManage,
+		PermissionSAMLManage,
+		PermissionSCIMManage,
+		PermissionLicenseRead,
+		PermissionLicenseManage,
+		PermissionSetupManage,
+		PermissionRolesManage,
+	}
+}
+
+type Role struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Permissions []Permission `json:"permissions"`
+	Builtin     bool         `json:"builtin,omitempty"`
+}
+
+// BuiltinRoles returns the roles that always exist: admin has every permission, user has none (only access to its own profile)
+func BuiltinRoles() []Role {
+	return []Role{
+		{Name: ROLE_ADMIN, Description: "Full access", Permissions: []Permission{PermissionAll}, Builtin: true},
+		{Name: ROLE_USER, Description: "Access to own profile", Permissions: []Permission{}, Builtin: true},
+	}
+}
+
+func (r Role) HasPermission(permission Permission) bool {
+	return slices.Contains(r.Permissions, PermissionAll) || slices.Contains(r.Permissions, permission)
+}
+
+// FindRole returns the role with the name. Built-in roles can't be overridden by custom roles.
+func FindRole(customRoles []Role, name string) (Role, bool) {
+	for _, role := range append(BuiltinRoles(), customRoles...) {
+		if role.Name == name {
+			return role, true
+		}
+	}
+	return Role{}, false
+}
+
+// ValidateRole checks the name and permissions of a custom role
+func ValidateRole(role Role) error {
+	if role.Name == "" {
+		return fmt.Errorf("role name cannot be empty")
+	}
+	for _, builtinRole := range BuiltinRoles() {
+		if builtinRole.Name == role.Name {
+			return fmt.Errorf("role %s is a built-in role", role.Name)
+		}
+	}
+	for _, permission := range role.Permissions {
+		if permission != PermissionAll && !slices.Contains(Permissions(), permission) {
+			return fmt.Errorf("unknown permission: %s", permission)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package users
+
+import "testing"
+
+func TestRoles(t *testing.T) {
+	admin, ok := FindRole(nil, ROLE_ADMIN)
+	if !ok || !admin.HasPermission(PermissionRolesManage) {
+		t.Fatalf("expected admin to have all permissions")
+	}
+	user, ok := FindRole(nil, ROLE_USER)
+	if !ok || user.HasPermission(PermissionUsersRead) {
+		t.Fatalf("expected user to have no permissions")
+	}
+	customRoles := []Role{{Name: ROLE_ADMIN, Permissions: []Permission{}}, {Name: "auditor", Permissions: []Permission{PermissionUsersRead}}}
+	admin, _ = FindRole(customRoles, ROLE_ADMIN)
+	if !admin.Builtin {
+		t.Fatalf("built-in role can't be overridden")
+	}
+	auditor, ok := FindRole(customRoles, "auditor")
+	if !ok || !auditor.HasPermission(PermissionUsersRead) || auditor.HasPermission(PermissionUsersWrite) {
+		t.Fatalf("unexpected permissions for custom role")
+	}
+	if err := ValidateRole(Role{Name: ROLE_ADMIN}); err == nil {
+		t.Fatalf("expected error for built-in role name")
+	}
+	if err := ValidateRole(Role{Name: "x", Permissions: []Permission{"users:delete"}}); err == nil {
+		t.Fatalf("expected error for unknown permission")
+	}
+}
@@ -1,25 +1,84 @@
 package users
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"os/user"
+	"slices"
 	"sync"
+
+	"github.com/in4it/go-devops-platform/storage"
 )
 
+// Deprecated: the UserStore has its own lock. UserStoreMu isn't used anymore.
 var UserStoreMu sync.Mutex
 
 func (u *UserStore) SaveUsers() error {
-	UserStoreMu.Lock()
-	defer UserStoreMu.Unlock()
-	out, err := json.Marshal(u.Users)
+	u.writeMu.Lock()
+	defer u.writeMu.Unlock()
+	return storage.WithLock(u.storage, u.storage.ConfigPath(USERSTORE_FILENAME), func() error {
+		u.mu.RLock()
+		out, err := json.Marshal(u.Users)
+		u.mu.RUnlock()
+		if err != nil {
+			return fmt.Errorf("user store marshal error: %s", err)
+		}
+		return u.writeUsers(out)
+	})
+}
+
+// modify reloads the users (they might have been changed by another instance), runs fn and saves the users,
+// while holding the storage lock. fn runs with mu held, so it must not call methods of the UserStore that lock.
+// When fn returns an error, the changes of fn are undone.
+func (u *UserStore) modify(fn func() error) error {
+	u.writeMu.Lock()
+	defer u.writeMu.Unlock()
+	if !u.autoSave {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		return u.apply(fn)
+	}
+	return storage.WithLock(u.storage, u.storage.ConfigPath(USERSTORE_FILENAME), func() error {
+		_, err := u.reload()
+		if err != nil {
+			return fmt.Errorf("reload error: %s", err)
+		}
+		u.mu.Lock()
+		err = u.apply(fn)
+		if err != nil {
+			u.mu.Unlock()
+			return err
+		}
+		out, err := json.Marshal(u.Users)
+		u.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("user store marshal error: %s", err)
+		}
+		return u.writeUsers(out)
+	})
+}
+
+// apply runs fn and updates the index. Must be called with mu held.
+func (u *UserStore) apply(fn func() error) error {
+	previous := slices.Clone(u.Users)
+	err := fn()
 	if err != nil {
-		return fmt.Errorf("user store marshal error: %s", err)
+		u.Users = previous
 	}
-	err = u.storage.WriteFile(u.storage.ConfigPath(USERSTORE_FILENAME), out)
+	u.reindex()
+	return err
+}
+
+// writeUsers writes users.json. Must be called with writeMu held (but not mu), the file is written without blocking readers.
+func (u *UserStore) writeUsers(out []byte) error {
+	err := u.storage.WriteFile(u.storage.ConfigPath(USERSTORE_FILENAME), out)
 	if err != nil {
 		return fmt.Errorf("user store write error: %s", err)
 	}
+	u.mu.Lock()
+	u.hash = sha256.Sum256(out)
+	u.mu.Unlock()
 	// fix permissions
 	currentUser, err := user.Current()
 	if err != nil {
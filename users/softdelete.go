@@ -0,0 +1,130 @@
+package users
+
+import (
+	"fmt"
+	"time"
+)
+
+func (u User) Deleted() bool {
+	return !u.DeletedAt.IsZero()
+}
+
+// SetDeletionGracePeriod sets how long deleted users are kept (suspended) before they are purged. 0 deletes users immediately.
+func (u *UserStore) SetDeletionGracePeriod(gracePeriod time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.gracePeriod = gracePeriod
+}
+
+// DeleteUser deletes the user and dispatches the deleted event. With a deletion grace period, the user is suspended and the disabled event is dispatched instead.
+// The user is purged by PurgeDeletedUsers after the grace period, unless it is restored with RestoreUser.
+func (u *UserStore) DeleteUser(id string) error {
+	u.mu.RLock()
+	user, ok := u.lookup(u.index.byID, id)
+	gracePeriod := u.gracePeriod
+	u.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("User not found")
+	}
+	if gracePeriod <= 0 {
+		deletedUser, err := u.deleteUser(func() (int, bool) {
+			pos, ok := u.index.byID[id]
+			return pos, ok
+		})
+		if err != nil {
+			return err
+		}
+		err = u.events.Dispatch(Event{Type: EVENT_USER_DELETED, User: deletedUser})
+		if err != nil {
+			return fmt.Errorf("deleted event error for user %s: %s", id, err)
+		}
+		return nil
+	}
+	if user.Deleted() {
+		return nil
+	}
+	err := u.modify(func() error {
+		pos, ok := u.index.byID[id]
+		if !ok {
+			return fmt.Errorf("User not found")
+		}
+		u.Users[pos].DeletedAt = TimeOrEmpty(time.Now())
+		u.Users[pos].SuspendedBeforeDelete = u.Users[pos].Suspended
+		u.Users[pos].Suspended = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !user.Suspended {
+		err = u.events.Dispatch(Event{Type: EVENT_USER_DISABLED, User: user})
+		if err != nil {
+			return fmt.Errorf("disabled event error for user %s: %s", id, err)
+		}
+	}
+	return nil
+}
+
+// RestoreUser restores a soft deleted user. The suspension is restored to the state before the delete.
+func (u *UserStore) RestoreUser(id string) (User, error) {
+	var restoredUser User
+	err := u.modify(func() error {
+		pos, ok := u.index.byID[id]
+		if !ok {
+			return fmt.Errorf("User not found")
+		}
+		if !u.Users[pos].Deleted() {
+			return fmt.Errorf("user is not deleted")
+		}
+		u.Users[pos].DeletedAt = TimeOrEmpty(time.Time{})
+		u.Users[pos].Suspended = u.Users[pos].SuspendedBeforeDelete
+		u.Users[pos].SuspendedBeforeDelete = false
+		if !u.Users[pos].Suspended {
+			u.Users[pos].ReactivatedAt = TimeOrEmpty(time.Now())
+		}
+		restoredUser = withoutPassword(u.Users[pos])
+		return nil
+	})
+	if err != nil {
+		return restoredUser, err
+	}
+	if !restoredUser.Suspended {
+		err = u.events.Dispatch(Event{Type: EVENT_USER_REACTIVATED, User: restoredUser})
+		if err != nil {
+			return restoredUser, fmt.Errorf("reactivated event error for user %s: %s", id, err)
+		}
+	}
+	return restoredUser, nil
+}
+
+// PurgeDeletedUsers removes the soft deleted users of which the grace period is over, and dispatches the deleted event for the removed users
+func (u *UserStore) PurgeDeletedUsers() ([]User, error) {
+	u.mu.RLock()
+	cutoff := time.Now().Add(-u.gracePeriod)
+	toPurge := []User{}
+	for _, user := range u.Users {
+		if user.Deleted() && time.Time(user.DeletedAt).Before(cutoff) {
+			toPurge = append(toPurge, withoutPassword(user))
+		}
+	}
+	u.mu.RUnlock()
+	purged := []User{}
+	for _, user := range toPurge {
+		deletedUser, err := u.deleteUser(func() (int, bool) {
+			pos, ok := u.index.byID[user.ID]
+			return pos, ok && u.Users[pos].Deleted() // not restored in the meantime
+		})
+		if err == errUserNotFound { // restored or removed in the meantime
+			continue
+		}
+		if err != nil {
+			return purged, fmt.Errorf("purge user %s error: %s", user.ID, err)
+		}
+		purged = append(purged, user)
+		err = u.events.Dispatch(Event{Type: EVENT_USER_DELETED, User: deletedUser})
+		if err != nil {
+			return purged, fmt.Errorf("deleted event error for user %s: %s", user.ID, err)
+		}
+	}
+	return purged, nil
+}
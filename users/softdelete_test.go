@@ -0,0 +1,98 @@
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestSoftDelete(t *testing.T) {
+	calls := []string{}
+	hook := func(name string) func(storage.Iface, User) error {
+		return func(_ storage.Iface, user User) error {
+			calls = append(calls, name+":"+user.Login)
+			return nil
+		}
+	}
+	store, err := NewUserStoreWithHooks(&memorystorage.MockMemoryStorage{}, -1, UserHooks{DisableFunc: hook("disable"), ReactivateFunc: hook("reactivate"), DeleteFunc: hook("delete")})
+	if err != nil {
+		t.Fatalf("new user store error: %s", err)
+	}
+	john, err := store.AddUser(User{Login: "john"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	jane, err := store.AddUser(User{Login: "jane", Suspended: true})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	store.SetDeletionGracePeriod(time.Hour)
+	for _, id := range []string{john.ID, jane.ID} {
+		if err := store.DeleteUser(id); err != nil {
+			t.Fatalf("delete error: %s", err)
+		}
+	}
+	user, err := store.GetUserByID(john.ID)
+	if err != nil || !user.Deleted() || !user.Suspended {
+		t.Fatalf("expected user to be soft deleted: %+v (%v)", user, err)
+	}
+	purged, err := store.PurgeDeletedUsers()
+	if err != nil || len(purged) != 0 {
+		t.Fatalf("expected no users to be purged within the grace period: %v (%v)", purged, err)
+	}
+	for _, id := range []string{john.ID, jane.ID} {
+		if _, err := store.RestoreUser(id); err != nil {
+			t.Fatalf("restore error: %s", err)
+		}
+	}
+	if user, _ := store.GetUserByID(john.ID); user.Deleted() || user.Suspended {
+		t.Fatalf("expected user to be restored: %+v", user)
+	}
+	if user, _ := store.GetUserByID(jane.ID); !user.Suspended {
+		t.Fatalf("expected suspended user to stay suspended after restore")
+	}
+	if _, err := store.Events().ProcessOutbox(); err != nil { // the hooks are called by the outbox worker
+		t.Fatalf("process outbox error: %s", err)
+	}
+	if len(calls) != 2 || calls[0] != "disable:john" || calls[1] != "reactivate:john" {
+		t.Fatalf("unexpected hook calls: %v", calls)
+	}
+
+	// purge after the grace period. The deleted event is only dispatched once the user is removed.
+	calls = []string{}
+	store.Events().Subscribe("test", func(event Event) error {
+		if store.LoginExists(event.User.Login) {
+			t.Errorf("deleted event dispatched before %s was removed", event.User.Login)
+		}
+		return nil
+	}, EVENT_USER_DELETED)
+	store.SetDeletionGracePeriod(time.Millisecond)
+	if err := store.DeleteUser(john.ID); err != nil {
+		t.Fatalf("delete error: %s", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	purged, err = store.PurgeDeletedUsers()
+	if err != nil || len(purged) != 1 || purged[0].ID != john.ID {
+		t.Fatalf("expected user to be purged: %v (%v)", purged, err)
+	}
+	if store.LoginExists("john") {
+		t.Fatalf("expected user to be removed")
+	}
+
+	// without grace period, the user is deleted immediately
+	store.SetDeletionGracePeriod(0)
+	if err := store.DeleteUser(jane.ID); err != nil {
+		t.Fatalf("delete error: %s", err)
+	}
+	if store.LoginExists("jane") {
+		t.Fatalf("expected user to be removed")
+	}
+	if _, err := store.Events().ProcessOutbox(); err != nil {
+		t.Fatalf("process outbox error: %s", err)
+	}
+	if len(calls) != 3 || calls[1] != "delete:john" || calls[2] != "delete:jane" {
+		t.Fatalf("unexpected hook calls: %v", calls)
+	}
+}
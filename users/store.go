@@ -2,42 +2,57 @@ package users
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 
 	"github.com/in4it/go-devops-platform/storage"
+	encryptedstorage "github.com/in4it/go-devops-platform/storage/encrypted"
 )
 
 const USERSTORE_FILENAME = "users.json"
 
-func NewUserStoreWithHooks(storage storage.Iface, maxUsers int, hooks UserHooks) (*UserStore, error) {
-	userStore, err := NewUserStore(storage, maxUsers)
+// NewUserStoreWithHooks returns a user store with the hooks subscribed to the events
+func NewUserStoreWithHooks(storageClient storage.Iface, maxUsers int, hooks UserHooks) (*UserStore, error) {
+	userStore, err := NewUserStore(storageClient, maxUsers)
 	if err != nil {
 		return userStore, err
 	}
-	userStore.UserHooks = hooks
+	hooks.subscribe(userStore.events, storageClient)
 	return userStore, nil
 }
-func NewUserStore(storage storage.Iface, maxUsers int) (*UserStore, error) {
+func NewUserStore(storageClient storage.Iface, maxUsers int) (*UserStore, error) {
+	if err := encryptedstorage.CheckWrapped(storageClient); err != nil {
+		return nil, err
+	}
+	groupStore, err := NewGroupStore(storageClient)
+	if err != nil {
+		return nil, fmt.Errorf("groupstore initialization error: %s", err)
+	}
 	userStore := &UserStore{
 		autoSave: true,
 		maxUsers: maxUsers,
-		storage:  storage,
+		storage:  storageClient,
+		groups:   groupStore,
+		tokens:   NewTokenStore(storageClient),
+		events:   NewDispatcher(NewOutbox(storageClient)),
 	}
 
 	if !userStore.storage.FileExists(userStore.storage.ConfigPath(USERSTORE_FILENAME)) {
 		userStore.Users = []User{}
+		userStore.reindex()
 		return userStore, nil
 	}
 
-	data, err := userStore.storage.ReadFile(userStore.storage.ConfigPath(USERSTORE_FILENAME))
+	// falls back to the last good snapshot if users.json is corrupt
+	err = storage.ReadFileWithFallback(userStore.storage, userStore.storage.ConfigPath(USERSTORE_FILENAME), func(data []byte) error {
+		userStore.Users = nil
+		userStore.hash = sha256.Sum256(data)
+		return json.NewDecoder(bytes.NewBuffer(data)).Decode(&userStore.Users)
+	})
+	userStore.reindex()
 	if err != nil {
 		return userStore, fmt.Errorf("config read error: %s", err)
 	}
-	decoder := json.NewDecoder(bytes.NewBuffer(data))
-	err = decoder.Decode(&userStore.Users)
-	if err != nil {
-		return userStore, fmt.Errorf("decode input error: %s", err)
-	}
 	return userStore, nil
 }
@@ -2,9 +2,12 @@ package users
 
 import (
 	"encoding/json"
+	"os"
+	"path"
 	"testing"
 	"time"
 
+	localstorage "github.com/in4it/go-devops-platform/storage/local"
 	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
 )
 
@@ -76,3 +79,132 @@ func TestStorageEmptyLastLogin(t *testing.T) {
 		t.Fatalf("user 1 time mismatch: %s vs %s", time.Time(listUsers[1].LastLogin).Format("2006-01-02T15:04:05.999999Z07:00"), user1Time)
 	}
 }
+
+func TestModifyFromMultipleStores(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	store1, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	store2, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	_, err = store1.AddUser(User{Login: "user1"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	_, err = store2.AddUser(User{Login: "user2"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	_, err = store1.AddUser(User{Login: "user2"})
+	if err == nil {
+		t.Fatalf("expected error: user added by other store already exists")
+	}
+	store3, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	if !store3.LoginExists("user1") || !store3.LoginExists("user2") {
+		t.Fatalf("write of other store was lost: %v", store3.ListUsers())
+	}
+}
+
+func TestSaveUsersWriteError(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	store, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	_, err = store.AddUser(User{Login: "john", Password: "mypass"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	before, err := storage.ReadFile(storage.ConfigPath(USERSTORE_FILENAME))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+
+	storage.InjectFault(memorystorage.Fault{Op: memorystorage.OpWrite, Prefix: storage.ConfigPath(USERSTORE_FILENAME), Err: memorystorage.ErrInjectedFault})
+	_, err = store.AddUser(User{Login: "jane", Password: "mypass"})
+	if err == nil {
+		t.Fatalf("expected add user to fail")
+	}
+	after, err := storage.ReadFile(storage.ConfigPath(USERSTORE_FILENAME))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	if string(before) != string(after) {
+		t.Fatalf("user store changed after failed write")
+	}
+
+	// the user is saved with the next successful write
+	storage.ClearFaults()
+	err = store.SaveUsers()
+	if err != nil {
+		t.Fatalf("save error: %s", err)
+	}
+	store2, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	if !store2.LoginExists("jane") {
+		t.Fatalf("user not saved")
+	}
+}
+
+// ownedLocalStorage skips the ownership change, so the tests don't need a vpn user
+type ownedLocalStorage struct {
+	*localstorage.LocalStorage
+}
+
+func (s ownedLocalStorage) EnsureOwnership(filename, login string) error {
+	return nil
+}
+
+func TestModifyCorruptFile(t *testing.T) {
+	local, err := localstorage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("new storage error: %s", err)
+	}
+	storage := ownedLocalStorage{local}
+	err = storage.EnsurePath(storage.ConfigPath(""))
+	if err != nil {
+		t.Fatalf("ensure path error: %s", err)
+	}
+	store, err := NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	for _, login := range []string{"john", "jane"} {
+		_, err = store.AddUser(User{Login: login})
+		if err != nil {
+			t.Fatalf("add user error: %s", err)
+		}
+	}
+	err = os.WriteFile(path.Join(local.GetPath(), storage.ConfigPath(USERSTORE_FILENAME)), []byte(`[{"login":`), 0600)
+	if err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+
+	// the store is loaded from the snapshot, and changes overwrite the corrupt file
+	store, err = NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	if !store.LoginExists("john") {
+		t.Fatalf("expected users of the snapshot: %v", store.ListUsers())
+	}
+	_, err = store.AddUser(User{Login: "alice"})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	store, err = NewUserStore(storage, 99)
+	if err != nil {
+		t.Fatalf("new store error: %s", err)
+	}
+	if !store.LoginExists("john") || !store.LoginExists("alice") {
+		t.Fatalf("users not saved: %v", store.ListUsers())
+	}
+}
@@ -0,0 +1,200 @@
+package users
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/in4it/go-devops-platform/storage"
+)
+
+const TOKENSTORE_FILENAME = "tokens.json"
+
+const (
+	TOKEN_PURPOSE_PASSWORD_RESET = "password-reset"
+	TOKEN_PURPOSE_INVITATION     = "invitation"
+)
+
+var ErrTokenInvalid = fmt.Errorf("token is invalid or expired")
+
+// Token is a single-use, time-limited token. Only the hash of the token is stored.
+type Token struct {
+	Hash      string    `json:"hash"`
+	Purpose   string    `json:"purpose"`
+	UserID    string    `json:"userID"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// TokenStore keeps the one-time tokens (e.g. password reset tokens) in storage, so they work across instances
+type TokenStore struct {
+	mu      sync.Mutex
+	storage storage.Iface
+}
+
+func NewTokenStore(storageClient storage.Iface) *TokenStore {
+	return &TokenStore{storage: storageClient}
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// read returns the tokens that are not expired
+func (t *TokenStore) read() ([]Token, error) {
+	tokens := []Token{}
+	if t.storage.FileExists(t.storage.ConfigPath(TOKENSTORE_FILENAME)) {
+		data, err := t.storage.ReadFile(t.storage.ConfigPath(TOKENSTORE_FILENAME))
+		if err != nil {
+			return tokens, fmt.Errorf("token store read error: %s", err)
+		}
+		err = json.NewDecoder(bytes.NewBuffer(data)).Decode(&tokens)
+		if err != nil {
+			return tokens, fmt.Errorf("token store decode error: %s", err)
+		}
+	}
+	now := time.Now()
+	return slices.DeleteFunc(tokens, func(token Token) bool { return token.ExpiresAt.Before(now) }), nil
+}
+
+// modify reads the tokens, removes the expired ones, runs fn and writes the tokens, while holding the storage lock
+func (t *TokenStore) modify(fn func(tokens []Token) ([]Token, error)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return storage.WithLock(t.storage, t.storage.ConfigPath(TOKENSTORE_FILENAME), func() error {
+		tokens, err := t.read()
+		if err != nil {
+			return err
+		}
+		tokens, err = fn(tokens)
+		if err != nil {
+			return err
+		}
+		out, err := json.Marshal(tokens)
+		if err != nil {
+			return fmt.Errorf("token store marshal error: %s", err)
+		}
+		err = t.storage.WriteFile(t.storage.ConfigPath(TOKENSTORE_FILENAME), out)
+		if err != nil {
+			return fmt.Errorf("token store write error: %s", err)
+		}
+		return nil
+	})
+}
+
+// Issue creates a new token for the user. Other tokens for the same user and purpose are revoked.
+func (t *TokenStore) Issue(purpose, userID, createdBy string, ttl time.Duration) (string, Token, error) {
+	return t.IssueWithLimit(purpose, userID, createdBy, ttl, 1)
+}
+
+// IssueWithLimit creates a new token for the user and keeps the most recent tokens for the same user and purpose, up to limit tokens.
+// Use it when the token can be requested by someone else than the user, so a request doesn't revoke the token the user is about to use.
+func (t *TokenStore) IssueWithLimit(purpose, userID, createdBy string, ttl time.Duration, limit int) (string, Token, error) {
+	random := make([]byte, 32)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", Token{}, fmt.Errorf("could not generate token: %s", err)
+	}
+	value := base64.RawURLEncoding.EncodeToString(random)
+	token := Token{
+		Hash:      hashToken(value),
+		Purpose:   purpose,
+		UserID:    userID,
+		CreatedBy: createdBy,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	err = t.modify(func(tokens []Token) ([]Token, error) {
+		count := 0
+		for _, existing := range tokens {
+			if existing.Purpose == purpose && existing.UserID == userID {
+				count++
+			}
+		}
+		tokens = slices.DeleteFunc(tokens, func(existing Token) bool { // oldest first
+			if existing.Purpose != purpose || existing.UserID != userID || count < limit {
+				return false
+			}
+			count--
+			return true
+		})
+		return append(tokens, token), nil
+	})
+	if err != nil {
+		return "", Token{}, err
+	}
+	return value, token, nil
+}
+
+// Consume removes the token and runs fn with it. When fn fails, the token is put back and can be used again.
+// fn runs without holding the token lock, so it can change the user without nesting the locks.
+func (t *TokenStore) Consume(purpose, value string, fn func(token Token) error) error {
+	hash := hashToken(value)
+	var consumed Token
+	err := t.modify(func(tokens []Token) ([]Token, error) {
+		pos := slices.IndexFunc(tokens, func(token Token) bool { return token.Hash == hash && token.Purpose == purpose })
+		if pos == -1 {
+			return tokens, ErrTokenInvalid
+		}
+		consumed = tokens[pos]
+		return slices.Delete(tokens, pos, pos+1), nil
+	})
+	if err != nil {
+		return err
+	}
+	err = fn(consumed)
+	if err != nil {
+		restoreErr := t.modify(func(tokens []Token) ([]Token, error) {
+			return append(tokens, consumed), nil
+		})
+		if restoreErr != nil {
+			return fmt.Errorf("%w (token could not be restored: %s)", err, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Lookup returns the token without consuming it
+func (t *TokenStore) Lookup(purpose, value string) (Token, error) {
+	hash := hashToken(value)
+	tokens, err := t.List(purpose)
+	if err != nil {
+		return Token{}, err
+	}
+	pos := slices.IndexFunc(tokens, func(token Token) bool { return token.Hash == hash })
+	if pos == -1 {
+		return Token{}, ErrTokenInvalid
+	}
+	return tokens[pos], nil
+}
+
+// List returns the tokens with the purpose that are not expired
+func (t *TokenStore) List(purpose string) ([]Token, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tokens, err := t.read()
+	if err != nil {
+		return tokens, err
+	}
+	return slices.DeleteFunc(tokens, func(token Token) bool { return token.Purpose != purpose }), nil
+}
+
+// Revoke removes the tokens of the user with the purpose
+func (t *TokenStore) Revoke(purpose, userID string) error {
+	return t.modify(func(tokens []Token) ([]Token, error) {
+		return slices.DeleteFunc(tokens, func(token Token) bool { return token.Purpose == purpose && token.UserID == userID }), nil
+	})
+}
+
+// Tokens returns the one-time token store
+func (u *UserStore) Tokens() *TokenStore {
+	return u.tokens
+}
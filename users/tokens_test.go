@@ -0,0 +1,91 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestTokenStore(t *testing.T) {
+	storage := &memorystorage.MockMemoryStorage{}
+	tokens := NewTokenStore(storage)
+	token, issued, err := tokens.Issue(TOKEN_PURPOSE_PASSWORD_RESET, "user-1", "admin", time.Hour)
+	if err != nil {
+		t.Fatalf("issue error: %s", err)
+	}
+	data, err := storage.ReadFile(storage.ConfigPath(TOKENSTORE_FILENAME))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	if strings.Contains(string(data), token) || !strings.Contains(string(data), issued.Hash) {
+		t.Fatalf("only the hash of the token should be stored")
+	}
+	if err := tokens.Consume("other-purpose", token, func(Token) error { return nil }); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("expected invalid token for other purpose, got: %v", err)
+	}
+	// a failing fn keeps the token
+	if err := tokens.Consume(TOKEN_PURPOSE_PASSWORD_RESET, token, func(Token) error { return fmt.Errorf("fail") }); err == nil {
+		t.Fatalf("expected error")
+	}
+	var consumed Token
+	if err := tokens.Consume(TOKEN_PURPOSE_PASSWORD_RESET, token, func(token Token) error { consumed = token; return nil }); err != nil {
+		t.Fatalf("consume error: %s", err)
+	}
+	if consumed.UserID != "user-1" || consumed.CreatedBy != "admin" {
+		t.Fatalf("unexpected token: %+v", consumed)
+	}
+	// single use
+	if err := tokens.Consume(TOKEN_PURPOSE_PASSWORD_RESET, token, func(Token) error { return nil }); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("expected token to be used, got: %v", err)
+	}
+}
+
+func TestTokenStoreExpiryAndRevoke(t *testing.T) {
+	tokens := NewTokenStore(&memorystorage.MockMemoryStorage{})
+	expired, _, err := tokens.Issue(TOKEN_PURPOSE_PASSWORD_RESET, "user-1", "", -time.Second)
+	if err != nil {
+		t.Fatalf("issue error: %s", err)
+	}
+	if err := tokens.Consume(TOKEN_PURPOSE_PASSWORD_RESET, expired, func(Token) error { return nil }); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("expected expired token to be invalid, got: %v", err)
+	}
+	first, _, err := tokens.Issue(TOKEN_PURPOSE_PASSWORD_RESET, "user-1", "", time.Hour)
+	if err != nil {
+		t.Fatalf("issue error: %s", err)
+	}
+	second, _, err := tokens.Issue(TOKEN_PURPOSE_PASSWORD_RESET, "user-1", "", time.Hour)
+	if err != nil {
+		t.Fatalf("issue error: %s", err)
+	}
+	if err := tokens.Consume(TOKEN_PURPOSE_PASSWORD_RESET, first, func(Token) error { return nil }); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("expected first token to be revoked by the second, got: %v", err)
+	}
+	if err := tokens.Revoke(TOKEN_PURPOSE_PASSWORD_RESET, "user-1"); err != nil {
+		t.Fatalf("revoke error: %s", err)
+	}
+	if err := tokens.Consume(TOKEN_PURPOSE_PASSWORD_RESET, second, func(Token) error { return nil }); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("expected second token to be revoked, got: %v", err)
+	}
+
+	// with a limit, the most recent tokens are kept
+	issued := []string{}
+	for range 3 {
+		token, _, err := tokens.IssueWithLimit(TOKEN_PURPOSE_PASSWORD_RESET, "user-1", "user-1", time.Hour, 2)
+		if err != nil {
+			t.Fatalf("issue error: %s", err)
+		}
+		issued = append(issued, token)
+	}
+	if _, err := tokens.Lookup(TOKEN_PURPOSE_PASSWORD_RESET, issued[0]); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("expected oldest token to be revoked, got: %v", err)
+	}
+	for _, token := range issued[1:] {
+		if _, err := tokens.Lookup(TOKEN_PURPOSE_PASSWORD_RESET, token); err != nil {
+			t.Fatalf("expected token to be kept: %s", err)
+		}
+	}
+}
@@ -1,17 +1,28 @@
 package users
 
 import (
+	"crypto/sha256"
+	"sync"
 	"time"
 
 	"github.com/in4it/go-devops-platform/storage"
 )
 
 type UserStore struct {
-	Users     []User `json:"users"`
-	autoSave  bool
-	maxUsers  int
-	storage   storage.Iface
-	UserHooks UserHooks `json:"-"`
+	Users          []User `json:"users"` // use the methods of the UserStore to access the users, they take care of the locking
+	autoSave       bool
+	maxUsers       int
+	storage        storage.Iface
+	hash           [sha256.Size]byte // hash of the last loaded or saved users.json
+	groups         *GroupStore
+	tokens         *TokenStore
+	events         *Dispatcher
+	mu             sync.RWMutex // guards Users, index and hash
+	writeMu        sync.Mutex   // serializes modifications (reload, modify, save)
+	index          userIndex
+	passwordPolicy PasswordPolicy
+	gracePeriod    time.Duration // deletion grace period, 0 deletes users immediately
+	hashParams     PasswordHashParams
 }
 
 type User struct {
@@ -22,11 +33,24 @@ type User struct {
 	SAMLID                           string      `json:"samlID,omitempty"`
 	Provisioned                      bool        `json:"provisioned,omitempty"`
 	Password                         string      `json:"password,omitempty"`
+	PasswordHistory                  []string    `json:"passwordHistory,omitempty"` // previous password hashes, newest first
+	PasswordChangedAt                TimeOrEmpty `json:"passwordChangedAt"`
+	PasswordHash                     string      `json:"-"` // AddUsers: import a hash (see HashFormat) instead of hashing Password
 	Suspended                        bool        `json:"suspended"`
+	SuspendedReason                  string      `json:"suspendedReason,omitempty"`       // set when the user is suspended automatically, see InactivityPolicy
+	Invited                          bool        `json:"invited,omitempty"`               // no password yet, see AcceptInvitation
+	DeletedAt                        TimeOrEmpty `json:"deletedAt"`                       // soft deleted, purged after the deletion grace period
+	SuspendedBeforeDelete            bool        `json:"suspendedBeforeDelete,omitempty"` // restored when the user is restored
 	ConnectionsDisabledOnAuthFailure bool        `json:"connectionsDisabledOnAuthFailure"`
 	Factors                          []Factor    `json:"factors"`
 	ExternalID                       string      `json:"externalID,omitempty"`
 	LastLogin                        TimeOrEmpty `json:"lastLogin"`
+	CreatedAt                        TimeOrEmpty `json:"createdAt"`
+	ReactivatedAt                    TimeOrEmpty `json:"reactivatedAt"`           // last time the suspension was lifted
+	ExpiresAt                        TimeOrEmpty `json:"expiresAt"`               // the user is suspended after this date (e.g. contractors)
+	InactivityWarningSentAt          TimeOrEmpty `json:"inactivityWarningSentAt"` // last warning about an upcoming suspension
+	Groups                           []string    `json:"groups,omitempty"`        // group IDs
+	Profile
 }
 
 type TimeOrEmpty time.Time
@@ -62,13 +86,3 @@ type Factor struct {
 	Type   string `json:"type"`
 	Secret string `json:"secret"`
 }
-
-type DisableFunc func(storage.Iface, User) error
-type ReactivateFunc func(storage.Iface, User) error
-type DeleteFunc func(storage.Iface, User) error
-
-type UserHooks struct {
-	DisableFunc    DisableFunc    `json:"-"`
-	ReactivateFunc ReactivateFunc `json:"-"`
-	DeleteFunc     DeleteFunc     `json:"-"`
-}
@@ -0,0 +1,66 @@
+package users
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UpsertUsers adds the users, or updates the role, profile and password of the users with an existing login, in one write.
+// The password of users of an identity provider or with a pending invitation can't be set.
+// Nothing is changed when one of the users fails. The returned users are the added and updated users, without password.
+func (u *UserStore) UpsertUsers(users []User) ([]User, error) {
+	err := u.hashImportedPasswords(users)
+	if err != nil {
+		return []User{}, err
+	}
+	upsertedUsers := []User{}
+	events := []Event{}
+	err = u.modify(func() error {
+		upsertedUsers = []User{}
+		events = []Event{}
+		logins := make(map[string]bool)
+		for _, user := range users {
+			if user.Login == "" {
+				return fmt.Errorf("login cannot be empty")
+			}
+			if logins[user.Login] {
+				return fmt.Errorf("duplicate login '%s'", user.Login)
+			}
+			logins[user.Login] = true
+			pos, exists := u.index.byLogin[user.Login]
+			if !exists {
+				user.ID = uuid.NewString()
+				user.CreatedAt = TimeOrEmpty(time.Now())
+				u.Users = append(u.Users, user)
+				upsertedUsers = append(upsertedUsers, withoutPassword(user))
+				events = append(events, Event{Type: EVENT_USER_CREATED, User: user})
+				continue
+			}
+			existing := &u.Users[pos]
+			previous := *existing
+			if user.Role != "" {
+				existing.Role = user.Role
+			}
+			existing.UpdateProfile(user.Profile)
+			if user.Password != "" {
+				if existing.OIDCID != "" || existing.SAMLID != "" || existing.Invited {
+					return fmt.Errorf("user %s: password can't be set for users of an identity provider or with a pending invitation", user.Login)
+				}
+				existing.PasswordHistory = addToHistory(existing.PasswordHistory, existing.Password, u.passwordPolicy.HistorySize)
+				existing.Password = user.Password
+				existing.PasswordChangedAt = user.PasswordChangedAt
+				events = append(events, Event{Type: EVENT_USER_PASSWORD_CHANGED, User: *existing})
+			}
+			upsertedUsers = append(upsertedUsers, withoutPassword(*existing))
+			events = append(events, updateEvents(previous, *existing)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return []User{}, err
+	}
+	u.emit(events...)
+	return upsertedUsers, nil
+}
@@ -0,0 +1,55 @@
+package users
+
+import (
+	"testing"
+
+	memorystorage "github.com/in4it/go-devops-platform/storage/memory"
+)
+
+func TestUpsertUsers(t *testing.T) {
+	store, err := NewUserStore(&memorystorage.MockMemoryStorage{}, -1)
+	if err != nil {
+		t.Fatalf("new user store error: %s", err)
+	}
+	john, err := store.AddUser(User{Login: "john", Password: "oldpassword", Role: ROLE_USER, Profile: Profile{GivenName: "John"}})
+	if err != nil {
+		t.Fatalf("add user error: %s", err)
+	}
+	upserted, err := store.UpsertUsers([]User{{Login: "john", Role: ROLE_ADMIN, Profile: Profile{FamilyName: "Doe"}}, {Login: "jane", Password: "janepassword"}})
+	if err != nil {
+		t.Fatalf("upsert error: %s", err)
+	}
+	if len(upserted) != 2 || upserted[0].ID != john.ID || upserted[0].Password != "" || upserted[1].ID == "" {
+		t.Fatalf("unexpected upserted users: %+v", upserted)
+	}
+	user, ok := store.AuthUser("john", "oldpassword")
+	if !ok || user.Role != ROLE_ADMIN || user.GivenName != "John" || user.FamilyName != "Doe" {
+		t.Fatalf("unexpected updated user: %+v", user)
+	}
+	if _, ok := store.AuthUser("jane", "janepassword"); !ok {
+		t.Fatalf("expected jane to be added")
+	}
+	// nothing is changed when a user fails
+	_, err = store.UpsertUsers([]User{{Login: "john", Password: "newpassword"}, {Login: "bob"}, {Login: "bob"}})
+	if err == nil {
+		t.Fatalf("expected duplicate login error")
+	}
+	if _, ok := store.AuthUser("john", "oldpassword"); !ok || store.LoginExists("bob") {
+		t.Fatalf("expected no changes")
+	}
+	// no passwords for users of an identity provider or with a pending invitation
+	_, err = store.AddUsers([]User{{Login: "oidcuser", OIDCID: "123"}, {Login: "invited", Invited: true}})
+	if err != nil {
+		t.Fatalf("add users error: %s", err)
+	}
+	for _, login := range []string{"oidcuser", "invited"} {
+		_, err = store.UpsertUsers([]User{{Login: login, Password: "newpassword"}})
+		if err == nil {
+			t.Fatalf("expected error when setting password of %s", login)
+		}
+	}
+	invited, err := store.GetUserByLogin("invited")
+	if err != nil || !invited.Invited {
+		t.Fatalf("expected invitation to be pending: %+v (%v)", invited, err)
+	}
+}